@@ -0,0 +1,15 @@
+-- Every application status transition together with why it happened
+
+CREATE TABLE IF NOT EXISTS application_status_history (
+  id              bigserial PRIMARY KEY,
+  application_id  uuid NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+  from_status     text NOT NULL,
+  to_status       text NOT NULL,
+  reason          text NOT NULL CHECK (btrim(reason) <> ''),
+  reviewer        text NOT NULL CHECK (btrim(reviewer) <> ''),
+  via_appeal      boolean NOT NULL DEFAULT false,
+  actor           text,
+  created_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_status_history_application_id ON application_status_history(application_id, created_at);
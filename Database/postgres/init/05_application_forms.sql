@@ -0,0 +1,24 @@
+-- Versioned application form definitions; exactly one version is active at a time
+
+CREATE TABLE IF NOT EXISTS application_forms (
+  version     serial PRIMARY KEY,
+  definition  jsonb NOT NULL,
+  active      boolean NOT NULL DEFAULT false,
+  created_by  text,
+  created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_application_forms_active ON application_forms(active) WHERE active;
+
+ALTER TABLE applications ADD COLUMN IF NOT EXISTS form_version integer REFERENCES application_forms(version);
+
+-- Seed with the questions the first frontend shipped with
+INSERT INTO application_forms (definition, active, created_by)
+SELECT '{
+  "title": "Apply to TYSMP",
+  "questions": [
+    {"id": "favourite_about_minecraft", "label": "Favourite thing about Minecraft", "type": "textarea", "required": true, "max_length": 2000},
+    {"id": "server_understanding", "label": "Your understanding of this server", "type": "textarea", "required": true, "max_length": 4000}
+  ]
+}'::jsonb, true, 'seed'
+WHERE NOT EXISTS (SELECT 1 FROM application_forms);
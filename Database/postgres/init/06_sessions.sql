@@ -0,0 +1,16 @@
+-- Applicant web sessions. A login token is exchanged once for a session whose
+-- expiry slides with activity up to an absolute cap.
+
+CREATE TABLE IF NOT EXISTS sessions (
+  id              uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id         uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at      timestamptz NOT NULL DEFAULT now(),
+  last_seen_at    timestamptz NOT NULL DEFAULT now(),
+  expires_at      timestamptz NOT NULL,
+  max_expires_at  timestamptz NOT NULL,
+  revoked_at      timestamptz,
+  user_agent      text
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
@@ -0,0 +1,23 @@
+-- Service-to-service authentication: named clients with scopes and hashed API keys.
+-- Keys look like tysmp_<prefix>_<secret>; only sha256(secret) is stored.
+
+CREATE TABLE IF NOT EXISTS api_clients (
+  id           uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  name         text NOT NULL UNIQUE,
+  scopes       text[] NOT NULL DEFAULT '{}',
+  created_at   timestamptz NOT NULL DEFAULT now(),
+  disabled_at  timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+  id            uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  client_id     uuid NOT NULL REFERENCES api_clients(id) ON DELETE CASCADE,
+  prefix        text NOT NULL UNIQUE,
+  key_hash      bytea NOT NULL,
+  created_at    timestamptz NOT NULL DEFAULT now(),
+  expires_at    timestamptz,            -- set when the key is rotated out
+  revoked_at    timestamptz,
+  last_used_at  timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_client_id ON api_keys(client_id);
@@ -0,0 +1,17 @@
+-- Work-in-progress application answers, saved incrementally by the frontend
+-- and promoted into `applications` on submit.
+
+CREATE TABLE IF NOT EXISTS application_drafts (
+  user_id         uuid PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  answers         jsonb NOT NULL DEFAULT '{}'::jsonb,
+  age             smallint,
+  minecraft_name  text,
+  form_version    integer REFERENCES application_forms(version),
+  created_at      timestamptz NOT NULL DEFAULT now(),
+  updated_at      timestamptz NOT NULL DEFAULT now()
+);
+
+DROP TRIGGER IF EXISTS application_drafts_set_updated_at ON application_drafts;
+CREATE TRIGGER application_drafts_set_updated_at
+BEFORE UPDATE ON application_drafts
+FOR EACH ROW EXECUTE PROCEDURE set_updated_at();
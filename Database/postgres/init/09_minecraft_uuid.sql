@@ -0,0 +1,28 @@
+-- Bind users to their Minecraft account UUID. Names can change, the UUID cannot,
+-- so whitelist sync and duplicate checks key on minecraft_uuid.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS minecraft_uuid uuid UNIQUE;
+
+CREATE OR REPLACE FUNCTION notify_app_event() RETURNS trigger AS $$
+DECLARE
+  payload json;
+BEGIN
+  payload := json_build_object(
+    'table', TG_TABLE_NAME,
+    'action', TG_OP,
+    'row_id', COALESCE(NEW.id, OLD.id),
+    'user_id', COALESCE(NEW.user_id, OLD.user_id),
+    'status', COALESCE(NEW.status, OLD.status),
+    'minecraft_name', CASE WHEN TG_TABLE_NAME = 'users' THEN COALESCE(NEW.minecraft_name, OLD.minecraft_name) ELSE NULL END,
+    'minecraft_uuid', CASE WHEN TG_TABLE_NAME = 'users' THEN COALESCE(NEW.minecraft_uuid, OLD.minecraft_uuid) ELSE NULL END,
+    'discord_user_id', CASE WHEN TG_TABLE_NAME = 'users' THEN COALESCE(NEW.discord_user_id, OLD.discord_user_id) ELSE NULL END,
+    'at', now()
+  );
+  PERFORM pg_notify('app_events', payload::text);
+  RETURN COALESCE(NEW, OLD);
+END; $$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_notify ON users;
+CREATE TRIGGER users_notify
+AFTER INSERT OR UPDATE OF minecraft_name, minecraft_uuid ON users
+FOR EACH ROW EXECUTE PROCEDURE notify_app_event();
@@ -0,0 +1,13 @@
+-- Minecraft renames picked up for users, written by SetMinecraftName
+
+CREATE TABLE IF NOT EXISTS minecraft_name_history (
+  id              bigserial PRIMARY KEY,
+  user_id         uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  minecraft_uuid  uuid,
+  old_name        citext,
+  new_name        citext,
+  actor           text,
+  changed_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_minecraft_name_history_user_id ON minecraft_name_history(user_id, changed_at);
@@ -0,0 +1,8 @@
+-- Indexes backing the staff audit trail and per-user timeline queries
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_row ON audit_log(table_name, row_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor);
+CREATE INDEX IF NOT EXISTS idx_audit_log_application_user
+  ON audit_log ((COALESCE(after_data->>'user_id', before_data->>'user_id')))
+  WHERE table_name = 'applications';
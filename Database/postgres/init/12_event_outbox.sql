@@ -0,0 +1,68 @@
+-- Transactional outbox for app events. Triggers write every event to
+-- event_outbox in the same transaction as the change; NOTIFY on app_events only
+-- carries the new outbox id as a wake-up. Each consumer reads the outbox from
+-- its own cursor and acknowledges what it has handled, so nothing emitted
+-- while a consumer is offline is lost.
+
+CREATE TABLE IF NOT EXISTS event_outbox (
+  id          bigserial PRIMARY KEY,
+  payload     jsonb NOT NULL,
+  created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_outbox_created_at ON event_outbox(created_at);
+
+-- cursor is the highest outbox id the consumer has acked or handed to retries
+CREATE TABLE IF NOT EXISTS event_consumers (
+  name        text PRIMARY KEY,
+  cursor      bigint NOT NULL DEFAULT 0,
+  created_at  timestamptz NOT NULL DEFAULT now(),
+  updated_at  timestamptz NOT NULL DEFAULT now()
+);
+
+-- Failed deliveries waiting for another attempt; dead_at is set once a
+-- consumer gives up on an event
+CREATE TABLE IF NOT EXISTS event_retries (
+  consumer         text NOT NULL REFERENCES event_consumers(name) ON DELETE CASCADE,
+  event_id         bigint NOT NULL REFERENCES event_outbox(id) ON DELETE CASCADE,
+  attempts         int NOT NULL DEFAULT 1,
+  next_attempt_at  timestamptz NOT NULL DEFAULT now(),
+  last_error       text,
+  dead_at          timestamptz,
+  PRIMARY KEY (consumer, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_retries_due ON event_retries(consumer, next_attempt_at) WHERE dead_at IS NULL;
+
+CREATE OR REPLACE VIEW event_dead_letters AS
+  SELECT r.consumer, r.event_id, r.attempts, r.last_error, r.dead_at, e.payload, e.created_at
+  FROM event_retries r JOIN event_outbox e ON e.id = r.event_id
+  WHERE r.dead_at IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION notify_app_event() RETURNS trigger AS $$
+DECLARE
+  row_data jsonb;
+  outbox_id bigint;
+BEGIN
+  -- read optional columns through jsonb so one function serves every table
+  row_data := to_jsonb(COALESCE(NEW, OLD));
+
+  -- serialise outbox writers until commit so ids become visible in order and a
+  -- consumer can never move its cursor past an id that commits later
+  PERFORM pg_advisory_xact_lock(hashtext('event_outbox'));
+
+  INSERT INTO event_outbox (payload) VALUES (jsonb_build_object(
+    'table', TG_TABLE_NAME,
+    'action', TG_OP,
+    'row_id', row_data->'id',
+    'user_id', row_data->'user_id',
+    'status', row_data->'status',
+    'minecraft_name', row_data->'minecraft_name',
+    'minecraft_uuid', row_data->'minecraft_uuid',
+    'discord_user_id', row_data->'discord_user_id',
+    'at', now()
+  )) RETURNING id INTO outbox_id;
+
+  PERFORM pg_notify('app_events', outbox_id::text);
+  RETURN COALESCE(NEW, OLD);
+END; $$ LANGUAGE plpgsql;
@@ -0,0 +1,38 @@
+-- Outgoing webhooks. Every app event is fanned out to the enabled endpoints
+-- whose filters match it; each (endpoint, event) pair becomes one delivery
+-- that is retried with backoff until it succeeds or runs out of attempts.
+-- The secret is kept in clear because it is needed to sign payloads.
+
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+  id           uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  url          text NOT NULL,
+  description  text,
+  secret       text NOT NULL,
+  -- empty filter arrays match everything
+  tables       text[] NOT NULL DEFAULT '{}',
+  actions      text[] NOT NULL DEFAULT '{}',
+  statuses     text[] NOT NULL DEFAULT '{}',
+  created_by   text,
+  created_at   timestamptz NOT NULL DEFAULT now(),
+  disabled_at  timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id               bigserial PRIMARY KEY,
+  endpoint_id      uuid NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+  event_id         bigint,                -- outbox id; NULL for test pings
+  event_type       text NOT NULL,
+  payload          jsonb NOT NULL,
+  state            text NOT NULL DEFAULT 'pending' CHECK (state IN ('pending','delivered','failed')),
+  attempts         int NOT NULL DEFAULT 0,
+  next_attempt_at  timestamptz NOT NULL DEFAULT now(),
+  response_status  int,
+  response_body    text,
+  last_error       text,
+  created_at       timestamptz NOT NULL DEFAULT now(),
+  delivered_at     timestamptz,
+  UNIQUE (endpoint_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE state = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint ON webhook_deliveries(endpoint_id, created_at);
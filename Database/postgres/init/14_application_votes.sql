@@ -0,0 +1,23 @@
+-- Staff votes on applications. A vote belongs to the stage (status) the
+-- application was in when it was cast, so interview votes start from zero.
+-- Reviewers may change their vote; there is one row per reviewer and stage.
+
+CREATE TABLE IF NOT EXISTS application_votes (
+  id              bigserial PRIMARY KEY,
+  application_id  uuid NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+  stage           text NOT NULL,
+  reviewer        text NOT NULL CHECK (btrim(reviewer) <> ''),
+  vote            text NOT NULL CHECK (vote IN ('approve','deny','abstain')),
+  comment         text,
+  actor           text,
+  created_at      timestamptz NOT NULL DEFAULT now(),
+  updated_at      timestamptz NOT NULL DEFAULT now(),
+  UNIQUE (application_id, stage, reviewer)
+);
+
+CREATE INDEX IF NOT EXISTS idx_application_votes_application ON application_votes(application_id, stage);
+
+DROP TRIGGER IF EXISTS application_votes_set_updated_at ON application_votes;
+CREATE TRIGGER application_votes_set_updated_at
+BEFORE UPDATE ON application_votes
+FOR EACH ROW EXECUTE PROCEDURE set_updated_at();
@@ -0,0 +1,34 @@
+-- Interview scheduling. Staff publish slots; an applicant in interview_pending
+-- books one. A slot holds at most one live booking, an application at most one
+-- scheduled interview. notified_at/reminded_at are set by the bot once the
+-- booking confirmation and the reminder DMs went out.
+
+CREATE TABLE IF NOT EXISTS interview_slots (
+  id                      uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  interviewer             text NOT NULL CHECK (btrim(interviewer) <> ''),
+  interviewer_discord_id  bigint,
+  starts_at               timestamptz NOT NULL,
+  ends_at                 timestamptz NOT NULL,
+  created_at              timestamptz NOT NULL DEFAULT now(),
+  cancelled_at            timestamptz,
+  CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_interview_slots_starts_at ON interview_slots(starts_at) WHERE cancelled_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS interviews (
+  id              uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  application_id  uuid NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+  slot_id         uuid NOT NULL REFERENCES interview_slots(id) ON DELETE CASCADE,
+  state           text NOT NULL DEFAULT 'scheduled' CHECK (state IN ('scheduled','passed','failed','no_show','cancelled')),
+  notes           text,
+  recorded_by     text,
+  booked_at       timestamptz NOT NULL DEFAULT now(),
+  notified_at     timestamptz,
+  reminded_at     timestamptz,
+  finished_at     timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_interviews_slot_scheduled ON interviews(slot_id) WHERE state <> 'cancelled';
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_interviews_application_scheduled ON interviews(application_id) WHERE state = 'scheduled';
+CREATE INDEX IF NOT EXISTS idx_interviews_application ON interviews(application_id, booked_at);
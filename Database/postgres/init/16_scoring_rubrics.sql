@@ -0,0 +1,58 @@
+-- Reviewer scoring. Rubrics are versioned like application forms; exactly one
+-- is active. Each reviewer keeps one score per application, normalised to
+-- 0-100 so scores against different rubric versions stay comparable.
+-- application_score_summaries holds the aggregate staff sort and filter on,
+-- and deviation is each score's distance from that aggregate.
+
+CREATE TABLE IF NOT EXISTS scoring_rubrics (
+  version     serial PRIMARY KEY,
+  definition  jsonb NOT NULL,
+  active      boolean NOT NULL DEFAULT false,
+  created_by  text,
+  created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scoring_rubrics_active ON scoring_rubrics(active) WHERE active;
+
+CREATE TABLE IF NOT EXISTS application_scores (
+  id              bigserial PRIMARY KEY,
+  application_id  uuid NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+  rubric_version  integer NOT NULL REFERENCES scoring_rubrics(version),
+  reviewer        text NOT NULL CHECK (btrim(reviewer) <> ''),
+  scores          jsonb NOT NULL,
+  total           numeric(5,2) NOT NULL CHECK (total BETWEEN 0 AND 100),
+  deviation       numeric(5,2) NOT NULL DEFAULT 0,
+  comment         text,
+  created_at      timestamptz NOT NULL DEFAULT now(),
+  updated_at      timestamptz NOT NULL DEFAULT now(),
+  UNIQUE (application_id, reviewer)
+);
+
+CREATE INDEX IF NOT EXISTS idx_application_scores_reviewer ON application_scores(reviewer);
+
+DROP TRIGGER IF EXISTS application_scores_set_updated_at ON application_scores;
+CREATE TRIGGER application_scores_set_updated_at
+BEFORE UPDATE ON application_scores
+FOR EACH ROW EXECUTE PROCEDURE set_updated_at();
+
+CREATE TABLE IF NOT EXISTS application_score_summaries (
+  application_id  uuid PRIMARY KEY REFERENCES applications(id) ON DELETE CASCADE,
+  score           numeric(5,2) NOT NULL,
+  score_count     integer NOT NULL,
+  spread          numeric(5,2) NOT NULL,
+  updated_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_application_score_summaries_score ON application_score_summaries(score);
+
+-- Seed a rubric matching the seeded form questions
+INSERT INTO scoring_rubrics (definition, active, created_by)
+SELECT '{
+  "title": "Default rubric",
+  "criteria": [
+    {"id": "effort", "label": "Effort and detail in the answers", "weight": 1, "min": 1, "max": 5},
+    {"id": "server_fit", "label": "Understanding of the server and its rules", "weight": 2, "min": 1, "max": 5},
+    {"id": "community", "label": "Likely contribution to the community", "weight": 1, "min": 1, "max": 5}
+  ]
+}'::jsonb, true, 'seed'
+WHERE NOT EXISTS (SELECT 1 FROM scoring_rubrics);
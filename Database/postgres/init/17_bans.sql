@@ -0,0 +1,38 @@
+-- Bans with reasons, evidence and expiry, and the appeals against them. The
+-- application status still says "banned"; this is the why, who and until
+-- when. previous_status is where the application returns once the ban is
+-- lifted, by expiry or an accepted appeal.
+
+CREATE TABLE IF NOT EXISTS bans (
+  id               uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id          uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  application_id   uuid REFERENCES applications(id) ON DELETE SET NULL,
+  reason           text NOT NULL CHECK (btrim(reason) <> ''),
+  evidence         text[] NOT NULL DEFAULT '{}',
+  issued_by        text NOT NULL CHECK (btrim(issued_by) <> ''),
+  issued_at        timestamptz NOT NULL DEFAULT now(),
+  expires_at       timestamptz,
+  appealable       boolean NOT NULL DEFAULT true,
+  previous_status  text NOT NULL,
+  lifted_at        timestamptz,
+  lifted_by        text,
+  lift_reason      text,
+  CHECK (expires_at IS NULL OR expires_at > issued_at)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_bans_active_user ON bans(user_id) WHERE lifted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_bans_expiry ON bans(expires_at) WHERE lifted_at IS NULL AND expires_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS ban_appeals (
+  id            uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  ban_id        uuid NOT NULL REFERENCES bans(id) ON DELETE CASCADE,
+  message       text NOT NULL CHECK (btrim(message) <> ''),
+  state         text NOT NULL DEFAULT 'pending' CHECK (state IN ('pending','accepted','rejected','closed')),
+  submitted_at  timestamptz NOT NULL DEFAULT now(),
+  reviewed_by   text,
+  reviewed_at   timestamptz,
+  response      text
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_ban_appeals_pending ON ban_appeals(ban_id) WHERE state = 'pending';
+CREATE INDEX IF NOT EXISTS idx_ban_appeals_state ON ban_appeals(state, submitted_at);
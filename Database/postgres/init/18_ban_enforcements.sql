@@ -0,0 +1,29 @@
+-- Propagation of bans to Discord and the Minecraft server. There is one row
+-- per user and target holding the latest desired action, so staff can see
+-- which targets a ban reached and retry the ones that failed. The bot works
+-- off pending rows whose next_attempt_at has come.
+
+CREATE TABLE IF NOT EXISTS ban_enforcements (
+  id               bigserial PRIMARY KEY,
+  user_id          uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  ban_id           uuid REFERENCES bans(id) ON DELETE SET NULL,
+  target           text NOT NULL CHECK (target IN ('discord','minecraft')),
+  action           text NOT NULL CHECK (action IN ('ban','unban')),
+  state            text NOT NULL DEFAULT 'pending' CHECK (state IN ('pending','applied','failed','skipped')),
+  attempts         integer NOT NULL DEFAULT 0,
+  next_attempt_at  timestamptz NOT NULL DEFAULT now(),
+  target_ref       text,
+  detail           text,
+  last_error       text,
+  applied_at       timestamptz,
+  created_at       timestamptz NOT NULL DEFAULT now(),
+  updated_at       timestamptz NOT NULL DEFAULT now(),
+  UNIQUE (user_id, target)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ban_enforcements_due ON ban_enforcements(next_attempt_at) WHERE state = 'pending';
+
+DROP TRIGGER IF EXISTS ban_enforcements_set_updated_at ON ban_enforcements;
+CREATE TRIGGER ban_enforcements_set_updated_at
+BEFORE UPDATE ON ban_enforcements
+FOR EACH ROW EXECUTE PROCEDURE set_updated_at();
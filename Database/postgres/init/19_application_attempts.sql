@@ -0,0 +1,25 @@
+-- A user may apply again once a denial has cooled down. Each submission after
+-- a denial is a new attempt; earlier attempts stay behind, read-only, so
+-- reviewers can see what was written before.
+
+ALTER TABLE applications ADD COLUMN IF NOT EXISTS attempt integer NOT NULL DEFAULT 1 CHECK (attempt > 0);
+ALTER TABLE applications ADD COLUMN IF NOT EXISTS superseded_at timestamptz;
+
+ALTER TABLE applications DROP CONSTRAINT IF EXISTS applications_user_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_applications_user_attempt ON applications(user_id, attempt);
+-- the current attempt is the only one without superseded_at
+CREATE UNIQUE INDEX IF NOT EXISTS uq_applications_current ON applications(user_id) WHERE superseded_at IS NULL;
+
+CREATE OR REPLACE FUNCTION freeze_superseded_application() RETURNS trigger AS $$
+BEGIN
+  IF OLD.superseded_at IS NOT NULL THEN
+    RAISE EXCEPTION 'application % (attempt %) was superseded and is read-only', OLD.id, OLD.attempt
+      USING ERRCODE = 'check_violation';
+  END IF;
+  RETURN NEW;
+END; $$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS applications_freeze_superseded ON applications;
+CREATE TRIGGER applications_freeze_superseded
+BEFORE UPDATE ON applications
+FOR EACH ROW EXECUTE PROCEDURE freeze_superseded_application();
@@ -0,0 +1,53 @@
+-- "denied" is a rejection that is not a ban: the applicant may apply again
+-- after the cooldown. rejection_reasons is the catalogue staff pick from when
+-- denying; message is what the applicant is sent, with {placeholders}.
+
+ALTER TABLE applications DROP CONSTRAINT IF EXISTS applications_status_check;
+ALTER TABLE applications ADD CONSTRAINT applications_status_check
+  CHECK (status IN ('applicant','interview_pending','member','banned','denied'));
+
+CREATE TABLE IF NOT EXISTS rejection_reasons (
+  code        text PRIMARY KEY CHECK (code ~ '^[a-z0-9_]{1,40}$'),
+  label       text NOT NULL CHECK (btrim(label) <> ''),
+  message     text NOT NULL CHECK (btrim(message) <> ''),
+  active      boolean NOT NULL DEFAULT true,
+  created_by  text,
+  created_at  timestamptz NOT NULL DEFAULT now(),
+  updated_at  timestamptz NOT NULL DEFAULT now()
+);
+
+DROP TRIGGER IF EXISTS rejection_reasons_set_updated_at ON rejection_reasons;
+CREATE TRIGGER rejection_reasons_set_updated_at
+BEFORE UPDATE ON rejection_reasons
+FOR EACH ROW EXECUTE PROCEDURE set_updated_at();
+
+INSERT INTO rejection_reasons (code, label, message, created_by) VALUES
+  ('low_effort', 'Low effort answers',
+   'Hi {name}, thanks for applying to TYSMP. Your answers were too short for us to get to know you, so we could not accept this application. You are welcome to apply again with a bit more detail.', 'seed'),
+  ('underage', 'Below the minimum age',
+   'Hi {name}, thanks for applying to TYSMP. You are below the minimum age for the server, so we could not accept your application.', 'seed'),
+  ('rules', 'Did not show understanding of the rules',
+   'Hi {name}, thanks for applying to TYSMP. Your application did not show that you had read the server rules. Please read them and apply again.', 'seed')
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE application_status_history ADD COLUMN IF NOT EXISTS reason_code text REFERENCES rejection_reasons(code);
+
+-- Denials used to be recorded as bans without a bans row; move the current
+-- ones over so they are no longer enforced as bans. A deny decision only ever
+-- rejected an application under review, so only applications whose latest
+-- move was from applicant or interview_pending to banned are converted; bans
+-- of members, and statuses older than the history, stay bans.
+WITH moved AS (
+  UPDATE applications a SET status = 'denied'
+  WHERE a.status = 'banned' AND a.superseded_at IS NULL
+    AND NOT EXISTS (SELECT 1 FROM bans b WHERE b.user_id = a.user_id AND b.lifted_at IS NULL)
+    AND (
+      SELECT h.from_status IN ('applicant','interview_pending') AND h.to_status = 'banned'
+      FROM application_status_history h
+      WHERE h.application_id = a.id
+      ORDER BY h.created_at DESC, h.id DESC LIMIT 1
+    )
+  RETURNING a.id
+)
+INSERT INTO application_status_history (application_id, from_status, to_status, reason, reviewer, actor)
+SELECT id, 'banned', 'denied', 'denial recorded as its own status', 'system:migration', 'system:migration' FROM moved;
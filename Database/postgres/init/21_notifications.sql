@@ -0,0 +1,51 @@
+-- Applicant notifications. notification_templates holds one message per
+-- status with {placeholders}; a status without an enabled template sends
+-- nothing. notifications records each message rendered for an application
+-- status event and how (or whether) it reached the applicant.
+
+CREATE TABLE IF NOT EXISTS notification_templates (
+  status      text PRIMARY KEY CHECK (status IN ('applicant','interview_pending','member','banned','denied')),
+  template    text NOT NULL CHECK (btrim(template) <> ''),
+  enabled     boolean NOT NULL DEFAULT true,
+  updated_by  text,
+  created_at  timestamptz NOT NULL DEFAULT now(),
+  updated_at  timestamptz NOT NULL DEFAULT now()
+);
+
+DROP TRIGGER IF EXISTS notification_templates_set_updated_at ON notification_templates;
+CREATE TRIGGER notification_templates_set_updated_at
+BEFORE UPDATE ON notification_templates
+FOR EACH ROW EXECUTE PROCEDURE set_updated_at();
+
+INSERT INTO notification_templates (status, template, updated_by) VALUES
+  ('applicant', 'Hi {name}, we received your TYSMP application (attempt {attempt}). Staff will review it soon; use /status to check on it.', 'seed'),
+  ('interview_pending', 'Hi {name}, good news: your TYSMP application moved on to an interview. Use /apply to log in and book a slot.', 'seed'),
+  ('member', 'Welcome to TYSMP, {name}! Your application was accepted and {minecraft_name} is being added to the whitelist.', 'seed'),
+  ('denied', '{reason}', 'seed'),
+  ('banned', 'Hi {name}, you have been banned from TYSMP: {reason}. Use /apply to log in to see the details or to appeal.', 'seed')
+ON CONFLICT (status) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS notifications (
+  id              bigserial PRIMARY KEY,
+  -- the event_outbox event it was sent for; redelivered events reuse the row
+  event_id        bigint NOT NULL UNIQUE,
+  application_id  uuid NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+  user_id         uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  status          text NOT NULL,
+  message         text NOT NULL,
+  state           text NOT NULL DEFAULT 'pending' CHECK (state IN ('pending','sent','fallback','failed')),
+  via             text CHECK (via IN ('dm','channel')),
+  attempts        integer NOT NULL DEFAULT 0,
+  last_error      text,
+  sent_at         timestamptz,
+  created_at      timestamptz NOT NULL DEFAULT now(),
+  updated_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_state ON notifications(state, updated_at DESC);
+
+DROP TRIGGER IF EXISTS notifications_set_updated_at ON notifications;
+CREATE TRIGGER notifications_set_updated_at
+BEFORE UPDATE ON notifications
+FOR EACH ROW EXECUTE PROCEDURE set_updated_at();
@@ -0,0 +1,15 @@
+-- The name each Minecraft account was last whitelisted under. The console
+-- only knows names, so when a member is renamed the bot needs the old name to
+-- remove the stale entry, including across restarts.
+
+CREATE TABLE IF NOT EXISTS whitelist_entries (
+  minecraft_uuid  uuid PRIMARY KEY,
+  name            text NOT NULL,
+  created_at      timestamptz NOT NULL DEFAULT now(),
+  updated_at      timestamptz NOT NULL DEFAULT now()
+);
+
+DROP TRIGGER IF EXISTS whitelist_entries_set_updated_at ON whitelist_entries;
+CREATE TRIGGER whitelist_entries_set_updated_at
+BEFORE UPDATE ON whitelist_entries
+FOR EACH ROW EXECUTE PROCEDURE set_updated_at();
@@ -0,0 +1,61 @@
+-- Outbox ids follow commit order. Triggers now stage every event in
+-- event_outbox_pending instead of writing event_outbox under a global lock,
+-- and NOTIFY on app_events is only a wake-up. Readers move staged events into
+-- event_outbox (sequence_event_outbox) only once every transaction that could
+-- still stage one has finished, numbering them by transaction id. A cursor
+-- over the ids therefore never passes an event that commits later, and
+-- writers never wait on each other.
+
+-- events of transactions that may not have committed yet; txid orders them
+CREATE TABLE IF NOT EXISTS event_outbox_pending (
+  id          bigserial PRIMARY KEY,
+  txid        xid8 NOT NULL DEFAULT pg_current_xact_id(),
+  payload     jsonb NOT NULL,
+  created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE OR REPLACE FUNCTION notify_app_event() RETURNS trigger AS $$
+DECLARE
+  row_data jsonb;
+BEGIN
+  -- read optional columns through jsonb so one function serves every table
+  row_data := to_jsonb(COALESCE(NEW, OLD));
+
+  INSERT INTO event_outbox_pending (payload) VALUES (jsonb_build_object(
+    'table', TG_TABLE_NAME,
+    'action', TG_OP,
+    'row_id', row_data->'id',
+    'user_id', row_data->'user_id',
+    'status', row_data->'status',
+    'minecraft_name', row_data->'minecraft_name',
+    'minecraft_uuid', row_data->'minecraft_uuid',
+    'discord_user_id', row_data->'discord_user_id',
+    'at', now()
+  ));
+
+  PERFORM pg_notify('app_events', '');
+  RETURN COALESCE(NEW, OLD);
+END; $$ LANGUAGE plpgsql;
+
+-- sequence_event_outbox moves staged events whose transactions have all
+-- finished into event_outbox, in (txid, staging id) order. Readers call it
+-- before reading; while one of them holds the lock the others skip it and
+-- pick the events up on their next read.
+CREATE OR REPLACE FUNCTION sequence_event_outbox() RETURNS void AS $$
+DECLARE
+  horizon xid8;
+BEGIN
+  IF NOT pg_try_advisory_xact_lock(hashtext('event_outbox_sequencer')) THEN
+    RETURN;
+  END IF;
+  -- every transaction below the snapshot xmin has committed or rolled back
+  horizon := pg_snapshot_xmin(pg_current_snapshot());
+
+  -- ids come from the serial in insert order, which ORDER BY fixes
+  WITH ready AS (
+    DELETE FROM event_outbox_pending WHERE txid < horizon
+    RETURNING id, txid, payload, created_at
+  )
+  INSERT INTO event_outbox (payload, created_at)
+  SELECT payload, created_at FROM ready ORDER BY txid, id;
+END; $$ LANGUAGE plpgsql;
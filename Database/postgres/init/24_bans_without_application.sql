@@ -0,0 +1,5 @@
+-- Users can be banned before they ever applied. Such bans have no
+-- application, so neither application_id nor previous_status, the status the
+-- application returns to once the ban is lifted.
+
+ALTER TABLE bans ALTER COLUMN previous_status DROP NOT NULL;
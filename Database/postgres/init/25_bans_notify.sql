@@ -0,0 +1,7 @@
+-- Ban and lift events reach ban enforcement even for users who never applied,
+-- whose bans change no application status.
+
+DROP TRIGGER IF EXISTS bans_notify ON bans;
+CREATE TRIGGER bans_notify
+AFTER INSERT OR UPDATE OF lifted_at ON bans
+FOR EACH ROW EXECUTE PROCEDURE notify_app_event();
@@ -0,0 +1,779 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+// staffKeys maps a staff member's name to their admin API key.
+type staffKeys map[string]string
+
+// parseStaffKeys reads STAFF_API_KEYS in the form "alice:key1,bob:key2".
+func parseStaffKeys(raw string) staffKeys {
+	out := staffKeys{}
+	for _, pair := range strings.Split(raw, ",") {
+		name, key, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || name == "" || key == "" {
+			continue
+		}
+		out[name] = key
+	}
+	return out
+}
+
+// lookup returns the staff name owning the key, comparing in constant time.
+func (k staffKeys) lookup(key string) (string, bool) {
+	found := ""
+	for name, want := range k {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(want)) == 1 {
+			found = name
+		}
+	}
+	return found, found != ""
+}
+
+// requireStaff authenticates a request with "Authorization: Bearer <key>" and
+// passes the staff member's name to the handler.
+func requireStaff(keys staffKeys, next func(w http.ResponseWriter, r *http.Request, staff string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		staff, ok := keys.lookup(strings.TrimSpace(key))
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r, staff)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// parseApplicationFilter maps query parameters onto ds.ApplicationFilter.
+func parseApplicationFilter(q map[string][]string) (ds.ApplicationFilter, error) {
+	var f ds.ApplicationFilter
+	get := func(k string) string {
+		if v := q[k]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	if v := get("status"); v != "" {
+		s := ds.Status(v)
+		if !s.Valid() {
+			return f, errors.New("invalid status")
+		}
+		f.StatusEquals = &s
+	}
+	for _, p := range []struct {
+		key string
+		dst **int
+	}{{"min_age", &f.MinAge}, {"max_age", &f.MaxAge}} {
+		if v := get(p.key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return f, errors.New("invalid " + p.key)
+			}
+			*p.dst = &n
+		}
+	}
+	for _, p := range []struct {
+		key string
+		dst **float64
+	}{{"min_score", &f.MinScore}, {"max_score", &f.MaxScore}} {
+		if v := get(p.key); v != "" {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return f, errors.New("invalid " + p.key)
+			}
+			*p.dst = &n
+		}
+	}
+	switch get("attempts") {
+	case "", "current":
+	case "all":
+		f.AllAttempts = true
+	default:
+		return f, errors.New("invalid attempts")
+	}
+	f.Sort = ds.ApplicationSort(get("sort"))
+	if !f.Sort.Valid() {
+		return f, errors.New("invalid sort")
+	}
+	for _, p := range []struct {
+		key string
+		dst **time.Time
+	}{{"created_after", &f.CreatedAfter}, {"created_before", &f.CreatedBefore}} {
+		if v := get(p.key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, errors.New("invalid " + p.key)
+			}
+			*p.dst = &t
+		}
+	}
+	return f, nil
+}
+
+// parseAuditFilter builds a ds.AuditFilter from query params.
+func parseAuditFilter(q map[string][]string) (ds.AuditFilter, error) {
+	var f ds.AuditFilter
+	get := func(k string) string {
+		if v := q[k]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	f.Table = get("table")
+	if f.Table != "" && f.Table != ds.AuditTableUsers && f.Table != ds.AuditTableApplications {
+		return f, errors.New("invalid table")
+	}
+	f.RowID = get("row_id")
+	if f.RowID != "" && !validUUID(f.RowID) {
+		return f, errors.New("invalid row_id")
+	}
+	f.Actor = get("actor")
+	f.Action = strings.ToUpper(get("action"))
+	switch f.Action {
+	case "", "INSERT", "UPDATE", "DELETE":
+	default:
+		return f, errors.New("invalid action")
+	}
+	for _, p := range []struct {
+		key string
+		dst **time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if v := get(p.key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, errors.New("invalid " + p.key)
+			}
+			*p.dst = &t
+		}
+	}
+	return f, nil
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// validUUID reports whether s is a UUID, so ids from paths and query params
+// are rejected with 400 before Postgres fails to cast them.
+func validUUID(s string) bool {
+	return uuidPattern.MatchString(s)
+}
+
+// pageParams reads limit/offset query parameters, leaving defaults to the database layer.
+func pageParams(r *http.Request) (int, int) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	return limit, offset
+}
+
+// registerAdminRoutes wires the staff review API onto mux.
+//
+//	GET  /admin/applications                 list, filtered by query params (?sort=score_desc, ?min_score=, ?attempts=all)
+//	GET  /admin/applications/{id}            application with user and history
+//	POST /admin/applications/{id}/accept     {"reason": "..."}
+//	POST /admin/applications/{id}/deny       {"reason": "...", "reason_code": "low_effort"} (reason defaults to the code's label)
+//	POST /admin/applications/{id}/interview  {"reason": "..."}
+//	GET  /admin/applications/{id}/votes      votes with the tally of the current stage
+//	POST /admin/applications/{id}/votes      {"vote": "approve|deny|abstain", "comment": "..."}
+//	GET  /admin/applications/{id}/interviews booked interviews with the no-show count
+//	GET  /admin/applications/{id}/attempts   every attempt by the same user, oldest first
+//	GET  /admin/applications/{id}/scores     reviewer scores with the aggregate
+//	POST /admin/applications/{id}/scores     {"scores": {"criterion": 4}, "comment": "..."} against the active rubric
+//	GET  /admin/rubrics                      all rubric versions
+//	POST /admin/rubrics                      publish a new active scoring rubric
+//	GET  /admin/reviewers/deviation          how far each reviewer scores from the average
+//	GET  /admin/rejection-reasons            the rejection reason catalogue, ?all=1 to include retired ones
+//	POST /admin/rejection-reasons            {"code","label","message","active"} create or update a reason
+//	GET  /admin/forms                        all form versions
+//	POST /admin/forms                        publish a new active form definition
+//	GET  /admin/audit                        audit trail, filtered by query params
+//	GET  /admin/users/{id}/timeline          user and application changes with diffs
+//	GET  /admin/users/{id}/name-history      recorded Minecraft renames
+//	GET  /admin/outbox/dead-letters          events consumers gave up on, ?consumer= to filter
+//	POST /admin/outbox/dead-letters/retry    {"consumer": "...", "event_id": 1}
+//	POST /admin/restore/preview              dry-run diff of a point-in-time restore
+//	POST /admin/restore                      apply it, {"table","row_id","audit_id"|"at","reason"}
+func registerAdminRoutes(mux *http.ServeMux, db *ds.DB, keys staffKeys, votePolicy ds.VotePolicy) {
+	mux.HandleFunc("/admin/forms", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		switch r.Method {
+		case http.MethodGet:
+			forms, err := db.ListForms(cctx)
+			if err != nil {
+				log.Printf("admin list forms: %v", err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if forms == nil {
+				forms = []ds.Form{}
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"forms": forms})
+		case http.MethodPost:
+			var def ds.FormDefinition
+			if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			if err := def.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			form, err := db.PublishForm(cctx, "staff:"+staff, def)
+			if err != nil {
+				log.Printf("admin publish form: %v", err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusCreated, form)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/admin/rubrics", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		switch r.Method {
+		case http.MethodGet:
+			rubrics, err := db.ListRubrics(cctx)
+			if err != nil {
+				log.Printf("admin list rubrics: %v", err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if rubrics == nil {
+				rubrics = []ds.Rubric{}
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"rubrics": rubrics})
+		case http.MethodPost:
+			var def ds.RubricDefinition
+			if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			if err := def.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			rubric, err := db.PublishRubric(cctx, "staff:"+staff, def)
+			if err != nil {
+				log.Printf("admin publish rubric: %v", err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusCreated, rubric)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/admin/rejection-reasons", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		switch r.Method {
+		case http.MethodGet:
+			reasons, err := db.ListRejectionReasons(cctx, r.URL.Query().Get("all") != "")
+			if err != nil {
+				log.Printf("admin list rejection reasons: %v", err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if reasons == nil {
+				reasons = []ds.RejectionReason{}
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"reasons": reasons, "placeholders": ds.RejectionPlaceholders})
+		case http.MethodPost:
+			var body struct {
+				ds.RejectionReason
+				Active *bool `json:"active"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			reason := body.RejectionReason
+			reason.Active = body.Active == nil || *body.Active
+			saved, err := db.SaveRejectionReason(cctx, "staff:"+staff, reason)
+			if err != nil {
+				if errors.Is(err, ds.ErrInvalidRejectionReason) {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				log.Printf("admin save rejection reason: %v", err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, saved)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/admin/reviewers/deviation", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		devs, err := db.ListReviewerDeviations(cctx)
+		if err != nil {
+			log.Printf("admin reviewer deviation: %v", err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if devs == nil {
+			devs = []ds.ReviewerDeviation{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"reviewers": devs})
+	}))
+
+	mux.HandleFunc("/admin/applications", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		f, err := parseApplicationFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit, offset := pageParams(r)
+
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		apps, err := db.FindApplications(cctx, f, limit, offset)
+		if err != nil {
+			log.Printf("admin list applications: %v", err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if apps == nil {
+			apps = []ds.Application{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"applications": apps})
+	}))
+
+	mux.HandleFunc("/admin/applications/", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/applications/"), "/")
+		id, action, _ := strings.Cut(rest, "/")
+		if id == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if !validUUID(id) {
+			http.Error(w, "invalid application id", http.StatusBadRequest)
+			return
+		}
+
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		if action == "" {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			detail, err := db.GetApplicationDetail(cctx, id)
+			if err != nil {
+				log.Printf("admin get application %s: %v", id, err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if detail == nil {
+				http.NotFound(w, r)
+				return
+			}
+			writeJSON(w, http.StatusOK, detail)
+			return
+		}
+
+		if action == "votes" {
+			handleVotes(cctx, w, r, db, id, staff, votePolicy)
+			return
+		}
+		if action == "scores" {
+			handleScores(cctx, w, r, db, id, staff)
+			return
+		}
+		if action == "attempts" {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			attempts, err := db.ListApplicationAttempts(cctx, id)
+			if err != nil {
+				log.Printf("admin attempts %s: %v", id, err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if len(attempts) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"attempts": attempts})
+			return
+		}
+		if action == "interviews" {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			ivs, err := db.ListInterviews(cctx, id)
+			if err != nil {
+				log.Printf("admin interviews %s: %v", id, err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if ivs == nil {
+				ivs = []ds.Interview{}
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"interviews": ivs, "no_shows": ds.CountNoShows(ivs)})
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		decision := ds.Decision(action)
+		if _, ok := decision.Target(); !ok {
+			http.NotFound(w, r)
+			return
+		}
+		var body struct {
+			Reason     string `json:"reason"`
+			ReasonCode string `json:"reason_code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		app, err := db.ApplyDecision(cctx, "staff:"+staff, id, decision, "staff:"+staff, body.Reason, body.ReasonCode)
+		if err != nil {
+			writeStatusError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, app)
+	}))
+
+	mux.HandleFunc("/admin/audit", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		f, err := parseAuditFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit, offset := pageParams(r)
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		entries, err := db.FindAuditEntries(cctx, f, limit, offset)
+		if err != nil {
+			log.Printf("admin audit: %v", err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if entries == nil {
+			entries = []ds.AuditEntry{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"entries": entries})
+	}))
+
+	mux.HandleFunc("/admin/outbox/dead-letters", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		limit, offset := pageParams(r)
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		letters, err := db.ListDeadLetters(cctx, r.URL.Query().Get("consumer"), limit, offset)
+		if err != nil {
+			log.Printf("admin dead letters: %v", err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if letters == nil {
+			letters = []ds.DeadLetter{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"dead_letters": letters})
+	}))
+
+	mux.HandleFunc("/admin/outbox/dead-letters/retry", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			Consumer string `json:"consumer"`
+			EventID  int64  `json:"event_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Consumer == "" || body.EventID == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		if err := db.RetryDeadLetter(cctx, body.Consumer, body.EventID); err != nil {
+			if errors.Is(err, ds.ErrDeadLetterNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			log.Printf("admin retry dead letter: %v", err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("outbox: %s requeued event %d for %s", staff, body.EventID, body.Consumer)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	mux.HandleFunc("/admin/restore/preview", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req ds.RestoreRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		if !validUUID(req.RowID) {
+			writeRestoreError(w, errInvalidRowID)
+			return
+		}
+		plan, err := db.PlanRestore(cctx, req)
+		if err != nil {
+			writeRestoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, plan)
+	}))
+
+	mux.HandleFunc("/admin/restore", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req ds.RestoreRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		if !validUUID(req.RowID) {
+			writeRestoreError(w, errInvalidRowID)
+			return
+		}
+		plan, err := db.RestoreRow(cctx, "restore:"+staff, "staff:"+staff, req)
+		if err != nil {
+			writeRestoreError(w, err)
+			return
+		}
+		log.Printf("restore: %s restored %s %s from audit entry #%d", staff, plan.Table, plan.RowID, plan.AuditID)
+		writeJSON(w, http.StatusOK, plan)
+	}))
+
+	mux.HandleFunc("/admin/users/", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/users/"), "/")
+		id, sub, _ := strings.Cut(rest, "/")
+		if id == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if !validUUID(id) {
+			http.Error(w, "invalid user id", http.StatusBadRequest)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		switch sub {
+		case "timeline":
+			limit, offset := pageParams(r)
+			entries, err := db.UserTimeline(cctx, id, limit, offset)
+			if err != nil {
+				log.Printf("admin timeline %s: %v", id, err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"entries": entries})
+		case "name-history":
+			changes, err := db.ListMinecraftNameHistory(cctx, id)
+			if err != nil {
+				log.Printf("admin name history %s: %v", id, err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if changes == nil {
+				changes = []ds.MinecraftNameChange{}
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"changes": changes})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+// handleVotes serves /admin/applications/{id}/votes.
+func handleVotes(ctx context.Context, w http.ResponseWriter, r *http.Request, db *ds.DB, appID string, staff string, policy ds.VotePolicy) {
+	switch r.Method {
+	case http.MethodGet:
+		app, err := db.GetApplication(ctx, appID)
+		if err != nil {
+			log.Printf("admin votes %s: %v", appID, err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if app == nil {
+			http.NotFound(w, r)
+			return
+		}
+		votes, err := db.ListVotes(ctx, appID)
+		if err != nil {
+			log.Printf("admin votes %s: %v", appID, err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if votes == nil {
+			votes = []ds.ApplicationVote{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"stage":  app.Status,
+			"tally":  ds.TallyVotes(votes, app.Status),
+			"policy": policy,
+			"votes":  votes,
+		})
+	case http.MethodPost:
+		var body struct {
+			Vote    ds.Vote `json:"vote"`
+			Comment string  `json:"comment"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		res, err := db.CastVote(ctx, "staff:"+staff, appID, "staff:"+staff, body.Vote, body.Comment, policy)
+		if err != nil {
+			writeStatusError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleScores serves /admin/applications/{id}/scores.
+func handleScores(ctx context.Context, w http.ResponseWriter, r *http.Request, db *ds.DB, appID string, staff string) {
+	switch r.Method {
+	case http.MethodGet:
+		scores, err := db.ListScores(ctx, appID)
+		if err != nil {
+			log.Printf("admin scores %s: %v", appID, err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		summary, err := db.GetScoreSummary(ctx, appID)
+		if err != nil {
+			log.Printf("admin scores %s: %v", appID, err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if scores == nil {
+			scores = []ds.ApplicationScore{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"summary": summary, "scores": scores})
+	case http.MethodPost:
+		var body struct {
+			Scores  map[string]int `json:"scores"`
+			Comment string         `json:"comment"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		score, summary, err := db.ScoreApplication(ctx, "staff:"+staff, appID, "staff:"+staff, body.Scores, body.Comment)
+		if err != nil {
+			var serr ds.ScoreErrors
+			switch {
+			case errors.As(err, &serr):
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": serr})
+			case errors.Is(err, ds.ErrNoActiveRubric):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				writeStatusError(w, err)
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"score": score, "summary": summary})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeStatusError maps status-transition errors onto HTTP responses.
+func writeStatusError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ds.ErrApplicationNotFound):
+		http.Error(w, "application not found", http.StatusNotFound)
+	case errors.Is(err, ds.ErrIllegalTransition), errors.Is(err, ds.ErrVotingClosed), errors.Is(err, ds.ErrApplicationSuperseded):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ds.ErrReasonRequired), errors.Is(err, ds.ErrReviewerRequired), errors.Is(err, ds.ErrUnknownDecision),
+		errors.Is(err, ds.ErrUnknownVote), errors.Is(err, ds.ErrRejectionReasonNotFound), errors.Is(err, ds.ErrReasonCodeNotDenial):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("status change: %v", err)
+		http.Error(w, "server error", http.StatusInternalServerError)
+	}
+}
+
+var errInvalidRowID = errors.New("row_id must be a UUID")
+
+// writeRestoreError maps restore errors onto HTTP responses.
+func writeRestoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ds.ErrNoRestorePoint):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ds.ErrNothingToRestore), errors.Is(err, ds.ErrRestoreConflict),
+		errors.Is(err, ds.ErrRestoreBanned), errors.Is(err, ds.ErrIllegalTransition), errors.Is(err, ds.ErrApplicationSuperseded):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ds.ErrRestoreTarget), errors.Is(err, ds.ErrRestoreRowDeleted), errors.Is(err, errInvalidRowID),
+		errors.Is(err, ds.ErrReasonRequired), errors.Is(err, ds.ErrReviewerRequired):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("restore: %v", err)
+		http.Error(w, "server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+const banExpiryActor = "system:ban-expiry"
+
+// expireBans lifts bans whose time is up every interval until ctx is done.
+func expireBans(ctx context.Context, db *ds.DB, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		cctx, cancel := context.WithTimeout(ctx, time.Minute)
+		lifted, err := db.ExpireBans(cctx, banExpiryActor)
+		cancel()
+		for _, b := range lifted {
+			if b.PreviousStatus != nil {
+				log.Printf("ban expiry: lifted ban %s of user %s, back to %s", b.ID, b.UserID, *b.PreviousStatus)
+			} else {
+				log.Printf("ban expiry: lifted ban %s of user %s", b.ID, b.UserID)
+			}
+		}
+		if err != nil {
+			log.Printf("ban expiry: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// registerBanAdminRoutes wires ban management onto mux (staff only).
+//
+//	GET  /admin/bans                  ?user_id= to filter, ?active=1 for bans in force
+//	POST /admin/bans                  {"user_id","reason","evidence":[...],"duration":"72h"|"expires_at","appealable"}
+//	GET  /admin/bans/{id}             a single ban
+//	POST /admin/bans/{id}/lift        {"reason": "..."}
+//	GET  /admin/ban-enforcements      ?user_id=, ?state=pending|applied|failed|skipped
+//	POST /admin/ban-enforcements/{id}/retry   requeue a failed target
+//	GET  /admin/appeals               ?state=pending (default) or accepted|rejected|closed|all
+//	POST /admin/appeals/{id}/review   {"accept": true, "response": "..."}
+func registerBanAdminRoutes(mux *http.ServeMux, db *ds.DB, keys staffKeys) {
+	mux.HandleFunc("/admin/bans", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		switch r.Method {
+		case http.MethodGet:
+			limit, offset := pageParams(r)
+			q := r.URL.Query()
+			if v := q.Get("user_id"); v != "" && !validUUID(v) {
+				http.Error(w, "invalid user_id", http.StatusBadRequest)
+				return
+			}
+			bans, err := db.ListBans(cctx, q.Get("user_id"), q.Get("active") != "", limit, offset)
+			if err != nil {
+				log.Printf("admin list bans: %v", err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if bans == nil {
+				bans = []ds.Ban{}
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"bans": bans})
+		case http.MethodPost:
+			var body struct {
+				ds.BanRequest
+				Duration   string `json:"duration"`
+				Appealable *bool  `json:"appealable"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.UserID == "" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			if !validUUID(body.UserID) {
+				http.Error(w, "invalid user_id", http.StatusBadRequest)
+				return
+			}
+			req := body.BanRequest
+			if body.Duration != "" {
+				d, err := time.ParseDuration(body.Duration)
+				if err != nil || d <= 0 || req.ExpiresAt != nil {
+					http.Error(w, "give either a positive duration or expires_at", http.StatusBadRequest)
+					return
+				}
+				at := time.Now().Add(d)
+				req.ExpiresAt = &at
+			}
+			// bans can be appealed unless staff say otherwise
+			req.Appealable = body.Appealable == nil || *body.Appealable
+			req.IssuedBy = "staff:" + staff
+			ban, err := db.IssueBan(cctx, "staff:"+staff, req)
+			if err != nil {
+				writeBanError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, ban)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/admin/bans/", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/bans/"), "/"), "/")
+		if id == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if !validUUID(id) {
+			http.Error(w, "invalid ban id", http.StatusBadRequest)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		switch action {
+		case "":
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			ban, err := db.GetBan(cctx, id)
+			if err != nil {
+				log.Printf("admin get ban %s: %v", id, err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if ban == nil {
+				http.NotFound(w, r)
+				return
+			}
+			writeJSON(w, http.StatusOK, ban)
+		case "lift":
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			var body struct {
+				Reason string `json:"reason"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			ban, err := db.LiftBan(cctx, "staff:"+staff, id, "staff:"+staff, body.Reason)
+			if err != nil {
+				writeBanError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, ban)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	mux.HandleFunc("/admin/ban-enforcements", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		switch q.Get("state") {
+		case "", ds.EnforcementPending, ds.EnforcementApplied, ds.EnforcementFailed, ds.EnforcementSkipped:
+		default:
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		}
+		if v := q.Get("user_id"); v != "" && !validUUID(v) {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+		limit, offset := pageParams(r)
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		list, err := db.ListBanEnforcements(cctx, q.Get("user_id"), q.Get("state"), limit, offset)
+		if err != nil {
+			log.Printf("admin list ban enforcements: %v", err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if list == nil {
+			list = []ds.BanEnforcement{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"enforcements": list})
+	}))
+
+	mux.HandleFunc("/admin/ban-enforcements/", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		idStr, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/ban-enforcements/"), "/"), "/")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil || action != "retry" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		if err := db.RetryBanEnforcement(cctx, id); err != nil {
+			writeBanError(w, err)
+			return
+		}
+		log.Printf("ban enforcement %d requeued by %s", id, staff)
+		writeJSON(w, http.StatusAccepted, map[string]any{"id": id, "state": ds.EnforcementPending})
+	}))
+
+	mux.HandleFunc("/admin/appeals", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		state := r.URL.Query().Get("state")
+		switch state {
+		case "":
+			state = ds.AppealPending
+		case "all":
+			state = ""
+		case ds.AppealPending, ds.AppealAccepted, ds.AppealRejected, ds.AppealClosed:
+		default:
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		}
+		limit, offset := pageParams(r)
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		appeals, err := db.ListAppeals(cctx, state, limit, offset)
+		if err != nil {
+			log.Printf("admin list appeals: %v", err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if appeals == nil {
+			appeals = []ds.BanAppeal{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"appeals": appeals})
+	}))
+
+	mux.HandleFunc("/admin/appeals/", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/appeals/"), "/"), "/")
+		if id == "" || action != "review" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			Accept   bool   `json:"accept"`
+			Response string `json:"response"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		appeal, err := db.ReviewAppeal(cctx, "staff:"+staff, id, "staff:"+staff, body.Accept, body.Response)
+		if err != nil {
+			writeBanError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, appeal)
+	}))
+}
+
+// registerBanRoutes wires the banned applicant's side onto mux. Applicants log
+// in the usual way, through the login link the bot sends on /apply.
+//
+//	GET  /ban          the active ban (or null) and the appeals filed
+//	POST /ban/appeal   {"message": "..."}
+func registerBanRoutes(mux *http.ServeMux, db *ds.DB, sessions *sessionManager) {
+	mux.HandleFunc("/ban", sessions.require(func(w http.ResponseWriter, r *http.Request, user ds.User, _ ds.Session) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		ban, err := db.GetActiveBan(cctx, user.ID)
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		appeals, err := db.ListUserAppeals(cctx, user.ID)
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if appeals == nil {
+			appeals = []ds.BanAppeal{}
+		}
+		// evidence and staff names are for staff eyes only
+		if ban != nil {
+			ban.Evidence, ban.IssuedBy = nil, ""
+		}
+		for i := range appeals {
+			appeals[i].ReviewedBy = nil
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ban": ban, "appeals": appeals})
+	}))
+
+	mux.HandleFunc("/ban/appeal", sessions.require(func(w http.ResponseWriter, r *http.Request, user ds.User, _ ds.Session) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&body); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		appeal, err := db.SubmitAppeal(cctx, user.ID, body.Message)
+		if err != nil {
+			writeBanError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, appeal)
+	}))
+}
+
+// writeBanError maps ban and appeal errors onto HTTP responses.
+func writeBanError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ds.ErrBanNotFound), errors.Is(err, ds.ErrAppealNotFound), errors.Is(err, ds.ErrEnforcementNotFound),
+		errors.Is(err, ds.ErrBanUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ds.ErrAlreadyBanned), errors.Is(err, ds.ErrNotBanned), errors.Is(err, ds.ErrAppealNotAllowed),
+		errors.Is(err, ds.ErrAppealPending), errors.Is(err, ds.ErrAppealReviewed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ds.ErrInvalidEvidence), errors.Is(err, ds.ErrInvalidBanExpiry):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		writeStatusError(w, err)
+	}
+}
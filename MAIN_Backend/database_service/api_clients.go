@@ -0,0 +1,270 @@
+package database_service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Scopes granted to API clients.
+const (
+	ScopeLoginTokensCreate = "login_tokens:create"
+)
+
+// KnownScopes lists every scope a client can be granted.
+var KnownScopes = []string{ScopeLoginTokensCreate}
+
+// DefaultRotationGrace is how long the old keys keep working after a rotation
+// when no grace is given.
+const DefaultRotationGrace = 24 * time.Hour
+
+const apiKeyPrefix = "tysmp_"
+
+var (
+	ErrInvalidAPIKey     = errors.New("invalid api key")
+	ErrAPIClientNotFound = errors.New("api client not found")
+	ErrAPIKeyNotFound    = errors.New("api key not found or already revoked")
+	ErrAPIClientName     = errors.New("client name required")
+	ErrAPIClientExists   = errors.New("an api client with this name already exists")
+	ErrUnknownScope      = errors.New("unknown scope")
+)
+
+// HasScope reports whether the client was granted scope.
+func (c APIClient) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// newAPIKey returns a fresh raw key, its lookup prefix and the hash to store.
+func newAPIKey() (raw string, prefix string, hash []byte, err error) {
+	p := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(p); err != nil {
+		return "", "", nil, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", nil, err
+	}
+	prefix = hex.EncodeToString(p)
+	enc := base64.RawURLEncoding.EncodeToString(secret)
+	sum := sha256.Sum256([]byte(enc))
+	return apiKeyPrefix + prefix + "_" + enc, prefix, sum[:], nil
+}
+
+// splitAPIKey parses "tysmp_<prefix>_<secret>".
+func splitAPIKey(raw string) (prefix string, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(raw, apiKeyPrefix)
+	if !ok {
+		return "", "", false
+	}
+	prefix, secret, ok = strings.Cut(rest, "_")
+	return prefix, secret, ok && prefix != "" && secret != ""
+}
+
+func insertAPIKeyTx(ctx context.Context, tx pgx.Tx, clientID string) (APIKey, string, error) {
+	raw, prefix, hash, err := newAPIKey()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	var k APIKey
+	if err := tx.QueryRow(ctx, `
+        INSERT INTO api_keys (client_id, prefix, key_hash)
+        VALUES ($1, $2, $3)
+        RETURNING id, client_id, prefix, created_at, expires_at, revoked_at, last_used_at
+    `, clientID, prefix, hash).Scan(&k.ID, &k.ClientID, &k.Prefix, &k.CreatedAt, &k.ExpiresAt, &k.RevokedAt, &k.LastUsedAt); err != nil {
+		return APIKey{}, "", err
+	}
+	return k, raw, nil
+}
+
+// CreateAPIClient registers a service and issues its first key. The raw key is
+// only ever returned here and from RotateAPIKey.
+func (db *DB) CreateAPIClient(ctx context.Context, actor string, name string, scopes []string) (APIClient, string, error) {
+	if strings.TrimSpace(name) == "" {
+		return APIClient{}, "", ErrAPIClientName
+	}
+	if scopes == nil {
+		scopes = []string{}
+	}
+	for _, s := range scopes {
+		if !containsString(KnownScopes, s) {
+			return APIClient{}, "", fmt.Errorf("%w %q", ErrUnknownScope, s)
+		}
+	}
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return APIClient{}, "", err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return APIClient{}, "", err
+	}
+
+	var c APIClient
+	if err := tx.QueryRow(ctx, `
+        INSERT INTO api_clients (name, scopes) VALUES ($1, $2)
+        RETURNING id, name, scopes, created_at, disabled_at
+    `, name, scopes).Scan(&c.ID, &c.Name, &c.Scopes, &c.CreatedAt, &c.DisabledAt); err != nil {
+		if isUniqueViolation(err) {
+			return APIClient{}, "", ErrAPIClientExists
+		}
+		return APIClient{}, "", err
+	}
+	k, raw, err := insertAPIKeyTx(ctx, tx, c.ID)
+	if err != nil {
+		return APIClient{}, "", err
+	}
+	c.Keys = []APIKey{k}
+	if err := tx.Commit(ctx); err != nil {
+		return APIClient{}, "", err
+	}
+	return c, raw, nil
+}
+
+// RotateAPIKey issues a new key for a client. Keys that are still active keep
+// working for grace (DefaultRotationGrace when not positive) so the service
+// can be redeployed without downtime; revoke them to cut them off at once.
+func (db *DB) RotateAPIKey(ctx context.Context, actor string, clientID string, grace time.Duration) (APIKey, string, error) {
+	if grace <= 0 {
+		grace = DefaultRotationGrace
+	}
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return APIKey{}, "", err
+	}
+
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM api_clients WHERE id = $1 AND disabled_at IS NULL)`, clientID).Scan(&exists); err != nil {
+		return APIKey{}, "", err
+	}
+	if !exists {
+		return APIKey{}, "", ErrAPIClientNotFound
+	}
+	if _, err := tx.Exec(ctx, `
+        UPDATE api_keys
+        SET expires_at = LEAST(COALESCE(expires_at, 'infinity'), now() + make_interval(secs => $2))
+        WHERE client_id = $1 AND revoked_at IS NULL
+    `, clientID, grace.Seconds()); err != nil {
+		return APIKey{}, "", err
+	}
+	k, raw, err := insertAPIKeyTx(ctx, tx, clientID)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return APIKey{}, "", err
+	}
+	return k, raw, nil
+}
+
+// RevokeAPIKey disables a single key immediately.
+func (db *DB) RevokeAPIKey(ctx context.Context, keyID string) error {
+	tag, err := db.pool.Exec(ctx, `UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, keyID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// DisableAPIClient blocks every key of a client.
+func (db *DB) DisableAPIClient(ctx context.Context, clientID string) error {
+	tag, err := db.pool.Exec(ctx, `UPDATE api_clients SET disabled_at = now() WHERE id = $1 AND disabled_at IS NULL`, clientID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIClientNotFound
+	}
+	return nil
+}
+
+// ListAPIClients returns all clients with their keys, newest keys first.
+func (db *DB) ListAPIClients(ctx context.Context) ([]APIClient, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT c.id, c.name, c.scopes, c.created_at, c.disabled_at,
+               k.id, k.client_id, k.prefix, k.created_at, k.expires_at, k.revoked_at, k.last_used_at
+        FROM api_clients c LEFT JOIN api_keys k ON k.client_id = c.id
+        ORDER BY c.name, k.created_at DESC
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []APIClient
+	for rows.Next() {
+		var c APIClient
+		var kID, kClient, kPrefix *string
+		var kCreated *time.Time
+		var k APIKey
+		if err := rows.Scan(&c.ID, &c.Name, &c.Scopes, &c.CreatedAt, &c.DisabledAt,
+			&kID, &kClient, &kPrefix, &kCreated, &k.ExpiresAt, &k.RevokedAt, &k.LastUsedAt); err != nil {
+			return nil, err
+		}
+		if len(out) == 0 || out[len(out)-1].ID != c.ID {
+			out = append(out, c)
+		}
+		if kID != nil {
+			k.ID, k.ClientID, k.Prefix, k.CreatedAt = *kID, *kClient, *kPrefix, *kCreated
+			last := &out[len(out)-1]
+			last.Keys = append(last.Keys, k)
+		}
+	}
+	return out, rows.Err()
+}
+
+// AuthenticateAPIKey resolves a raw key to its client and records its use.
+// Unknown, revoked, expired and disabled keys all yield ErrInvalidAPIKey.
+func (db *DB) AuthenticateAPIKey(ctx context.Context, raw string) (APIClient, error) {
+	prefix, secret, ok := splitAPIKey(raw)
+	if !ok {
+		return APIClient{}, ErrInvalidAPIKey
+	}
+
+	var keyID string
+	var hash []byte
+	var c APIClient
+	err := db.pool.QueryRow(ctx, `
+        SELECT k.id, k.key_hash, c.id, c.name, c.scopes, c.created_at, c.disabled_at
+        FROM api_keys k JOIN api_clients c ON c.id = k.client_id
+        WHERE k.prefix = $1
+          AND k.revoked_at IS NULL
+          AND (k.expires_at IS NULL OR k.expires_at > now())
+          AND c.disabled_at IS NULL
+    `, prefix).Scan(&keyID, &hash, &c.ID, &c.Name, &c.Scopes, &c.CreatedAt, &c.DisabledAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return APIClient{}, ErrInvalidAPIKey
+		}
+		return APIClient{}, err
+	}
+	sum := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(sum[:], hash) != 1 {
+		return APIClient{}, ErrInvalidAPIKey
+	}
+
+	if _, err := db.pool.Exec(ctx, `UPDATE api_keys SET last_used_at = now() WHERE id = $1`, keyID); err != nil {
+		return APIClient{}, err
+	}
+	return c, nil
+}
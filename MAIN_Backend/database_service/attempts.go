@@ -0,0 +1,134 @@
+package database_service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrApplicationLocked     = errors.New("application is past review and can no longer be changed")
+	ErrApplicationSuperseded = errors.New("application was superseded by a newer attempt and is read-only")
+)
+
+// CooldownError rejects a new attempt made too soon after a denial.
+type CooldownError struct {
+	Until time.Time
+}
+
+func (e *CooldownError) Error() string {
+	return "you can apply again after " + e.Until.UTC().Format(time.RFC3339)
+}
+
+// currentAttempt is what deciding on a submission needs to know about the
+// user's current application.
+type currentAttempt struct {
+	ID      string
+	Attempt int
+	Status  Status
+	// DeniedAt is when the application was denied; nil unless Status is denied
+	DeniedAt *time.Time
+}
+
+func getCurrentAttempt(ctx context.Context, q queryer, userID string, lock bool) (*currentAttempt, error) {
+	sql := `
+        SELECT a.id, a.attempt, a.status,
+               CASE WHEN a.status = 'denied' THEN
+                   COALESCE((SELECT max(h.created_at) FROM application_status_history h
+                             WHERE h.application_id = a.id AND h.to_status = 'denied'), a.updated_at)
+               END
+        FROM applications a
+        WHERE a.user_id = $1 AND a.superseded_at IS NULL`
+	if lock {
+		sql += " FOR UPDATE OF a"
+	}
+	var c currentAttempt
+	err := q.QueryRow(ctx, sql, userID).Scan(&c.ID, &c.Attempt, &c.Status, &c.DeniedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// newAttempt reports whether submitting now starts a new attempt (true) or
+// edits the current one in place (false). Applicants may edit until a
+// reviewer acts; denied users start over once cooldown has passed since the
+// denial; anything else is closed to the applicant.
+func (c *currentAttempt) newAttempt(now time.Time, cooldown time.Duration) (bool, error) {
+	switch {
+	case c == nil:
+		return true, nil
+	case c.Status == StatusApplicant:
+		return false, nil
+	case c.Status == StatusBanned:
+		return false, ErrAlreadyBanned
+	case c.Status == StatusDenied && c.DeniedAt != nil:
+		if until := c.DeniedAt.Add(cooldown); now.Before(until) {
+			return false, &CooldownError{Until: until}
+		}
+		return true, nil
+	}
+	return false, ErrApplicationLocked
+}
+
+// CheckCanApply reports whether the user may submit an application now,
+// returning ErrApplicationLocked, ErrAlreadyBanned or a *CooldownError when
+// not. CreateOrUpdateApplication checks the same under a lock; this lets
+// callers refuse before doing other work.
+func (db *DB) CheckCanApply(ctx context.Context, userID string, cooldown time.Duration) error {
+	c, err := getCurrentAttempt(ctx, db.pool, userID, false)
+	if err != nil {
+		return err
+	}
+	if err := checkBannedApplicant(ctx, db.pool, userID, c); err != nil {
+		return err
+	}
+	_, err = c.newAttempt(time.Now(), cooldown)
+	return err
+}
+
+// checkBannedApplicant refuses users banned before they ever applied. Users
+// with an application carry the ban in its status, which newAttempt checks.
+func checkBannedApplicant(ctx context.Context, q queryer, userID string, c *currentAttempt) error {
+	if c != nil {
+		return nil
+	}
+	banned, err := hasActiveBan(ctx, q, userID)
+	if err != nil {
+		return err
+	}
+	if banned {
+		return ErrAlreadyBanned
+	}
+	return nil
+}
+
+// ListApplicationAttempts returns every attempt by the user who owns the
+// given application, oldest first. Superseded attempts are read-only.
+func (db *DB) ListApplicationAttempts(ctx context.Context, applicationID string) ([]Application, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+applicationColumns+`
+        FROM applications
+        WHERE user_id = (SELECT user_id FROM applications WHERE id = $1)
+        ORDER BY attempt
+    `, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Application
+	for rows.Next() {
+		var a Application
+		if err := scanApplication(rows, &a); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,150 @@
+package database_service
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Audited tables, as recorded in audit_log.table_name.
+const (
+	AuditTableUsers        = "users"
+	AuditTableApplications = "applications"
+)
+
+// AuditFilter narrows FindAuditEntries. Zero fields are ignored.
+type AuditFilter struct {
+	Table string
+	RowID string
+	// Actor matches exactly, or by prefix when it ends in ':' (e.g. "staff:")
+	Actor string
+	// Action is INSERT, UPDATE or DELETE, case-insensitive
+	Action string
+	Since  *time.Time
+	Until  *time.Time
+}
+
+// diffIgnored lists columns that change on every write and would only add noise.
+var diffIgnored = map[string]bool{"updated_at": true}
+
+const auditColumns = "id, table_name, row_id, action, before_data, after_data, actor, created_at"
+
+func scanAuditEntry(row pgx.Row, e *AuditEntry) error {
+	return row.Scan(&e.ID, &e.Table, &e.RowID, &e.Action, &e.Before, &e.After, &e.Actor, &e.CreatedAt)
+}
+
+// FindAuditEntries returns audit rows matching f, newest first.
+func (db *DB) FindAuditEntries(ctx context.Context, f AuditFilter, limit int, offset int) ([]AuditEntry, error) {
+	where := "WHERE 1=1"
+	args := []any{}
+
+	if f.Table != "" {
+		args = append(args, f.Table)
+		where += " AND table_name = $" + strconv.Itoa(len(args))
+	}
+	if f.RowID != "" {
+		args = append(args, f.RowID)
+		where += " AND row_id = $" + strconv.Itoa(len(args))
+	}
+	if f.Actor != "" {
+		args = append(args, f.Actor)
+		if strings.HasSuffix(f.Actor, ":") {
+			where += " AND starts_with(actor, $" + strconv.Itoa(len(args)) + ")"
+		} else {
+			where += " AND actor = $" + strconv.Itoa(len(args))
+		}
+	}
+	if f.Action != "" {
+		args = append(args, strings.ToUpper(f.Action))
+		where += " AND action = $" + strconv.Itoa(len(args))
+	}
+	if f.Since != nil {
+		args = append(args, *f.Since)
+		where += " AND created_at >= $" + strconv.Itoa(len(args))
+	}
+	if f.Until != nil {
+		args = append(args, *f.Until)
+		where += " AND created_at <= $" + strconv.Itoa(len(args))
+	}
+
+	return db.queryAudit(ctx, where, args, limit, offset)
+}
+
+// UserTimeline merges the audit rows of a user and of their applications,
+// newest first, each with field-level changes.
+func (db *DB) UserTimeline(ctx context.Context, userID string, limit int, offset int) ([]TimelineEntry, error) {
+	// applications are matched through the row data so deleted ones still show up
+	where := `WHERE (table_name = 'users' AND row_id = $1)
+           OR (table_name = 'applications' AND COALESCE(after_data->>'user_id', before_data->>'user_id') = $1::text)`
+	entries, err := db.queryAudit(ctx, where, []any{userID}, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]TimelineEntry, 0, len(entries))
+	for _, e := range entries {
+		changes := DiffAudit(e.Before, e.After)
+		if changes == nil {
+			changes = []FieldChange{}
+		}
+		out = append(out, TimelineEntry{AuditEntry: e, Changes: changes})
+	}
+	return out, nil
+}
+
+func (db *DB) queryAudit(ctx context.Context, where string, args []any, limit int, offset int) ([]AuditEntry, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	sql := "SELECT " + auditColumns + " FROM audit_log " + where + " ORDER BY created_at DESC, id DESC LIMIT $" + strconv.Itoa(len(args)+1) + " OFFSET $" + strconv.Itoa(len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := db.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := scanAuditEntry(rows, &e); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// DiffAudit lists the columns that differ between two row snapshots, sorted by
+// name. A nil before (insert) or after (delete) reports every column.
+func DiffAudit(before, after map[string]any) []FieldChange {
+	keys := map[string]bool{}
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+
+	var out []FieldChange
+	for k := range keys {
+		if diffIgnored[k] {
+			continue
+		}
+		b, a := before[k], after[k]
+		if reflect.DeepEqual(b, a) {
+			continue
+		}
+		out = append(out, FieldChange{Field: k, Before: b, After: a})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Field < out[j].Field })
+	return out
+}
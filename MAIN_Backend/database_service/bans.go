@@ -0,0 +1,480 @@
+package database_service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Appeal states.
+const (
+	AppealPending  = "pending"
+	AppealAccepted = "accepted"
+	AppealRejected = "rejected"
+	// AppealClosed marks appeals left pending when the ban ended another way
+	AppealClosed = "closed"
+)
+
+// maxEvidence caps the evidence links attached to one ban.
+const maxEvidence = 10
+
+var (
+	ErrBanNotFound      = errors.New("ban not found")
+	ErrBanUserNotFound  = errors.New("user not found")
+	ErrAlreadyBanned    = errors.New("user already has an active ban")
+	ErrInvalidEvidence  = errors.New("evidence must be up to 10 http(s) links")
+	ErrInvalidBanExpiry = errors.New("ban expiry must be in the future")
+	ErrNotBanned        = errors.New("user has no active ban")
+	ErrAppealNotAllowed = errors.New("this ban cannot be appealed")
+	ErrAppealPending    = errors.New("an appeal is already waiting for review")
+	ErrAppealNotFound   = errors.New("appeal not found")
+	ErrAppealReviewed   = errors.New("appeal was already reviewed")
+)
+
+// BanRequest describes a ban to issue. A nil ExpiresAt bans permanently.
+type BanRequest struct {
+	UserID     string     `json:"user_id"`
+	Reason     string     `json:"reason"`
+	Evidence   []string   `json:"evidence"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Appealable bool       `json:"appealable"`
+	IssuedBy   string     `json:"-"`
+}
+
+const banColumns = "b.id, b.user_id, b.application_id, b.reason, b.evidence, b.issued_by, b.issued_at, b.expires_at, b.appealable, b.previous_status, b.lifted_at, b.lifted_by, b.lift_reason"
+
+func scanBan(row pgx.Row, b *Ban) error {
+	return row.Scan(&b.ID, &b.UserID, &b.ApplicationID, &b.Reason, &b.Evidence, &b.IssuedBy, &b.IssuedAt, &b.ExpiresAt, &b.Appealable, &b.PreviousStatus, &b.LiftedAt, &b.LiftedBy, &b.LiftReason)
+}
+
+const appealColumns = "p.id, p.ban_id, b.user_id, p.message, p.state, p.submitted_at, p.reviewed_by, p.reviewed_at, p.response"
+
+func scanAppeal(row pgx.Row, a *BanAppeal) error {
+	return row.Scan(&a.ID, &a.BanID, &a.UserID, &a.Message, &a.State, &a.SubmittedAt, &a.ReviewedBy, &a.ReviewedAt, &a.Response)
+}
+
+// validEvidence trims the links and checks they are absolute http(s) URLs.
+func validEvidence(links []string) ([]string, error) {
+	out := []string{}
+	for _, l := range links {
+		if l = strings.TrimSpace(l); l == "" {
+			continue
+		}
+		u, err := url.Parse(l)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, ErrInvalidEvidence
+		}
+		out = append(out, l)
+	}
+	if len(out) > maxEvidence {
+		return nil, ErrInvalidEvidence
+	}
+	return out, nil
+}
+
+// IssueBan bans a user and moves their application, if they have one, to
+// StatusBanned in the same transaction, remembering the status it returns to
+// when the ban is lifted. Denials are StatusDenied, so an application already
+// at StatusBanned is always under an active ban. Users who never applied are
+// banned without an application and cannot apply while the ban lasts.
+func (db *DB) IssueBan(ctx context.Context, actor string, req BanRequest) (Ban, error) {
+	req.Reason = strings.TrimSpace(req.Reason)
+	req.IssuedBy = strings.TrimSpace(req.IssuedBy)
+	if req.Reason == "" {
+		return Ban{}, ErrReasonRequired
+	}
+	if req.IssuedBy == "" {
+		return Ban{}, ErrReviewerRequired
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return Ban{}, ErrInvalidBanExpiry
+	}
+	evidence, err := validEvidence(req.Evidence)
+	if err != nil {
+		return Ban{}, err
+	}
+
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Ban{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return Ban{}, err
+	}
+
+	var appID *string
+	var previous *Status
+	var id string
+	var status Status
+	err = tx.QueryRow(ctx, `SELECT id, status FROM applications WHERE user_id = $1 AND superseded_at IS NULL FOR UPDATE`, req.UserID).Scan(&id, &status)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		// no application to move; the bans row alone keeps them out
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, req.UserID).Scan(&exists); err != nil {
+			return Ban{}, err
+		}
+		if !exists {
+			return Ban{}, ErrBanUserNotFound
+		}
+	case err != nil:
+		return Ban{}, err
+	case status == StatusBanned:
+		return Ban{}, ErrAlreadyBanned
+	default:
+		if _, err := transitionTx(ctx, tx, actor, id, StatusChange{
+			To:       StatusBanned,
+			Reason:   "ban: " + req.Reason,
+			Reviewer: req.IssuedBy,
+		}); err != nil {
+			return Ban{}, err
+		}
+		appID, previous = &id, &status
+	}
+
+	if err := tx.QueryRow(ctx, `
+        INSERT INTO bans (user_id, application_id, reason, evidence, issued_by, expires_at, appealable, previous_status)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id
+    `, req.UserID, appID, req.Reason, evidence, req.IssuedBy, req.ExpiresAt, req.Appealable, previous).Scan(&id); err != nil {
+		if isUniqueViolation(err) {
+			return Ban{}, ErrAlreadyBanned
+		}
+		return Ban{}, err
+	}
+	b, err := getBanTx(ctx, tx, id)
+	if err != nil {
+		return Ban{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Ban{}, err
+	}
+	return b, nil
+}
+
+// hasActiveBan reports whether the user is under a ban that has not been lifted.
+func hasActiveBan(ctx context.Context, q queryer, userID string) (bool, error) {
+	var banned bool
+	err := q.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM bans WHERE user_id = $1 AND lifted_at IS NULL)`, userID).Scan(&banned)
+	return banned, err
+}
+
+func getBanTx(ctx context.Context, q queryer, banID string) (Ban, error) {
+	var b Ban
+	err := scanBan(q.QueryRow(ctx, `SELECT `+banColumns+` FROM bans b WHERE b.id = $1`, banID), &b)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Ban{}, ErrBanNotFound
+	}
+	return b, err
+}
+
+// GetBan returns a ban by id, or nil if it does not exist.
+func (db *DB) GetBan(ctx context.Context, banID string) (*Ban, error) {
+	b, err := getBanTx(ctx, db.pool, banID)
+	if errors.Is(err, ErrBanNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// GetActiveBan returns the user's ban that has not been lifted yet, if any.
+func (db *DB) GetActiveBan(ctx context.Context, userID string) (*Ban, error) {
+	var b Ban
+	err := scanBan(db.pool.QueryRow(ctx, `SELECT `+banColumns+` FROM bans b WHERE b.user_id = $1 AND b.lifted_at IS NULL`, userID), &b)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// ListBans returns bans newest first, optionally for one user and only those
+// still in force.
+func (db *DB) ListBans(ctx context.Context, userID string, activeOnly bool, limit int, offset int) ([]Ban, error) {
+	where := "WHERE 1=1"
+	args := []any{}
+	if userID != "" {
+		args = append(args, userID)
+		where += " AND b.user_id = $" + strconv.Itoa(len(args))
+	}
+	if activeOnly {
+		where += " AND b.lifted_at IS NULL"
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	args = append(args, limit, offset)
+
+	rows, err := db.pool.Query(ctx, `SELECT `+banColumns+` FROM bans b `+where+
+		` ORDER BY b.issued_at DESC LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Ban
+	for rows.Next() {
+		var b Ban
+		if err := scanBan(rows, &b); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// LiftBan ends a ban early and returns the application to its status from
+// before the ban.
+func (db *DB) LiftBan(ctx context.Context, actor string, banID string, liftedBy string, reason string) (Ban, error) {
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Ban{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return Ban{}, err
+	}
+
+	b, err := liftBanTx(ctx, tx, actor, banID, liftedBy, reason)
+	if err != nil {
+		return Ban{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Ban{}, err
+	}
+	return b, nil
+}
+
+// liftBanTx marks the ban lifted, closes appeals still pending against it and
+// moves the application back to the ban's previous status.
+func liftBanTx(ctx context.Context, tx pgx.Tx, actor string, banID string, liftedBy string, reason string) (Ban, error) {
+	reason = strings.TrimSpace(reason)
+	liftedBy = strings.TrimSpace(liftedBy)
+	if reason == "" {
+		return Ban{}, ErrReasonRequired
+	}
+	if liftedBy == "" {
+		return Ban{}, ErrReviewerRequired
+	}
+
+	var appID *string
+	var previous *Status
+	var liftedAt *time.Time
+	if err := tx.QueryRow(ctx, `SELECT application_id, previous_status, lifted_at FROM bans WHERE id = $1 FOR UPDATE`, banID).Scan(&appID, &previous, &liftedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Ban{}, ErrBanNotFound
+		}
+		return Ban{}, err
+	}
+	if liftedAt != nil {
+		return Ban{}, ErrNotBanned
+	}
+	if _, err := tx.Exec(ctx, `
+        UPDATE bans SET lifted_at = now(), lifted_by = $2, lift_reason = $3 WHERE id = $1
+    `, banID, liftedBy, reason); err != nil {
+		return Ban{}, err
+	}
+	if _, err := tx.Exec(ctx, `
+        UPDATE ban_appeals SET state = 'closed', reviewed_by = $2, reviewed_at = now()
+        WHERE ban_id = $1 AND state = 'pending'
+    `, banID, liftedBy); err != nil {
+		return Ban{}, err
+	}
+
+	if appID != nil && previous != nil {
+		var status Status
+		if err := tx.QueryRow(ctx, `SELECT status FROM applications WHERE id = $1`, *appID).Scan(&status); err != nil {
+			return Ban{}, err
+		}
+		// a restore may already have moved the application on
+		if status == StatusBanned {
+			if _, err := transitionTx(ctx, tx, actor, *appID, StatusChange{
+				To:       *previous,
+				Reason:   "ban lifted: " + reason,
+				Reviewer: liftedBy,
+				Appeal:   true,
+			}); err != nil {
+				return Ban{}, err
+			}
+		}
+	}
+	return getBanTx(ctx, tx, banID)
+}
+
+// ExpireBans lifts every ban whose expiry has passed and returns them. Each
+// ban is lifted in its own transaction so one failure does not hold up the rest.
+func (db *DB) ExpireBans(ctx context.Context, actor string) ([]Ban, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT id FROM bans
+        WHERE lifted_at IS NULL AND expires_at <= now()
+        ORDER BY expires_at
+    `)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []Ban
+	var errs []error
+	for _, id := range ids {
+		b, err := db.LiftBan(ctx, actor, id, actor, "ban expired")
+		if errors.Is(err, ErrNotBanned) {
+			// lifted by staff in the meantime
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		out = append(out, b)
+	}
+	return out, errors.Join(errs...)
+}
+
+// SubmitAppeal files an appeal against the user's active ban.
+func (db *DB) SubmitAppeal(ctx context.Context, userID string, message string) (BanAppeal, error) {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return BanAppeal{}, ErrReasonRequired
+	}
+	ban, err := db.GetActiveBan(ctx, userID)
+	if err != nil {
+		return BanAppeal{}, err
+	}
+	if ban == nil {
+		return BanAppeal{}, ErrNotBanned
+	}
+	if !ban.Appealable {
+		return BanAppeal{}, ErrAppealNotAllowed
+	}
+
+	var a BanAppeal
+	err = scanAppeal(db.pool.QueryRow(ctx, `
+        WITH p AS (
+            INSERT INTO ban_appeals (ban_id, message) VALUES ($1, $2)
+            RETURNING *
+        )
+        SELECT `+appealColumns+` FROM p JOIN bans b ON b.id = p.ban_id
+    `, ban.ID, message), &a)
+	if isUniqueViolation(err) {
+		return BanAppeal{}, ErrAppealPending
+	}
+	return a, err
+}
+
+// ListAppeals returns appeals in the given state (all when empty), oldest first.
+func (db *DB) ListAppeals(ctx context.Context, state string, limit int, offset int) ([]BanAppeal, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return db.queryAppeals(ctx, `WHERE $1 = '' OR p.state = $1 ORDER BY p.submitted_at LIMIT $2 OFFSET $3`, state, limit, offset)
+}
+
+// ListUserAppeals returns every appeal a user filed, newest first.
+func (db *DB) ListUserAppeals(ctx context.Context, userID string) ([]BanAppeal, error) {
+	return db.queryAppeals(ctx, `WHERE b.user_id = $1 ORDER BY p.submitted_at DESC`, userID)
+}
+
+func (db *DB) queryAppeals(ctx context.Context, where string, args ...any) ([]BanAppeal, error) {
+	rows, err := db.pool.Query(ctx, `SELECT `+appealColumns+` FROM ban_appeals p JOIN bans b ON b.id = p.ban_id `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []BanAppeal
+	for rows.Next() {
+		var a BanAppeal
+		if err := scanAppeal(rows, &a); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// ReviewAppeal records a staff decision on a pending appeal. Accepting it
+// lifts the ban in the same transaction.
+func (db *DB) ReviewAppeal(ctx context.Context, actor string, appealID string, reviewer string, accept bool, response string) (BanAppeal, error) {
+	reviewer = strings.TrimSpace(reviewer)
+	response = strings.TrimSpace(response)
+	if reviewer == "" {
+		return BanAppeal{}, ErrReviewerRequired
+	}
+	if response == "" {
+		return BanAppeal{}, ErrReasonRequired
+	}
+
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return BanAppeal{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return BanAppeal{}, err
+	}
+
+	var banID, state string
+	if err := tx.QueryRow(ctx, `SELECT ban_id, state FROM ban_appeals WHERE id = $1 FOR UPDATE`, appealID).Scan(&banID, &state); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return BanAppeal{}, ErrAppealNotFound
+		}
+		return BanAppeal{}, err
+	}
+	if state != AppealPending {
+		return BanAppeal{}, ErrAppealReviewed
+	}
+	next := AppealRejected
+	if accept {
+		next = AppealAccepted
+	}
+	if _, err := tx.Exec(ctx, `
+        UPDATE ban_appeals SET state = $2, reviewed_by = $3, reviewed_at = now(), response = $4
+        WHERE id = $1
+    `, appealID, next, reviewer, response); err != nil {
+		return BanAppeal{}, err
+	}
+	if accept {
+		if _, err := liftBanTx(ctx, tx, actor, banID, reviewer, "appeal accepted: "+response); err != nil {
+			return BanAppeal{}, err
+		}
+	}
+
+	var a BanAppeal
+	if err := scanAppeal(tx.QueryRow(ctx, `SELECT `+appealColumns+` FROM ban_appeals p JOIN bans b ON b.id = p.ban_id WHERE p.id = $1`, appealID), &a); err != nil {
+		return BanAppeal{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return BanAppeal{}, err
+	}
+	return a, nil
+}
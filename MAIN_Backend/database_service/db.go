@@ -58,6 +58,37 @@ func withActor(ctx context.Context, tx pgx.Tx, actor string) error {
 	return err
 }
 
+// userColumns is the column list scanUser expects, in order.
+const userColumns = "id, discord_user_id, discord_username, minecraft_name, minecraft_uuid, age, created_at, updated_at"
+
+// scanUser scans a row selected with userColumns.
+func scanUser(row pgx.Row, u *User) error {
+	return row.Scan(&u.ID, &u.DiscordUserID, &u.DiscordUsername, &u.MinecraftName, &u.MinecraftUUID, &u.Age, &u.CreatedAt, &u.UpdatedAt)
+}
+
+// applicationColumns is the column list scanApplication expects, in order.
+const applicationColumns = "id, user_id, attempt, answers, status, form_version, superseded_at, created_at, updated_at"
+
+// scanApplication scans a row selected with applicationColumns, followed by
+// any extra columns into extra.
+func scanApplication(row pgx.Row, a *Application, extra ...any) error {
+	var answersRaw []byte
+	if err := row.Scan(append([]any{&a.ID, &a.UserID, &a.Attempt, &answersRaw, &a.Status, &a.FormVersion, &a.SupersededAt, &a.CreatedAt, &a.UpdatedAt}, extra...)...); err != nil {
+		return err
+	}
+	return json.Unmarshal(answersRaw, &a.Answers)
+}
+
+// qualify prefixes every column of a column list with a table alias, for
+// queries that join tables sharing column names.
+func qualify(alias string, columns string) string {
+	cols := strings.Split(columns, ", ")
+	for i, c := range cols {
+		cols[i] = alias + "." + c
+	}
+	return strings.Join(cols, ", ")
+}
+
 // UpsertUser inserts or updates a user row based on Discord user id.
 func (db *DB) UpsertUser(ctx context.Context, actor string, u User) (User, error) {
 	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
@@ -78,11 +109,11 @@ func (db *DB) UpsertUser(ctx context.Context, actor string, u User) (User, error
         DO UPDATE SET discord_username = EXCLUDED.discord_username,
                       minecraft_name   = COALESCE(EXCLUDED.minecraft_name, users.minecraft_name),
                       age              = COALESCE(EXCLUDED.age, users.age)
-        RETURNING id, discord_user_id, discord_username, minecraft_name, age, created_at, updated_at
+        RETURNING `+userColumns+`
     `, u.DiscordUserID, u.DiscordUsername, u.MinecraftName, u.Age)
 
 	var out User
-	if err := row.Scan(&out.ID, &out.DiscordUserID, &out.DiscordUsername, &out.MinecraftName, &out.Age, &out.CreatedAt, &out.UpdatedAt); err != nil {
+	if err := scanUser(row, &out); err != nil {
 		return User{}, err
 	}
 	if err := tx.Commit(ctx); err != nil {
@@ -91,7 +122,8 @@ func (db *DB) UpsertUser(ctx context.Context, actor string, u User) (User, error
 	return out, nil
 }
 
-// SetMinecraftName updates minecraft_name for a user.
+// SetMinecraftName updates minecraft_name for a user and records the change
+// in minecraft_name_history when the name actually differs.
 func (db *DB) SetMinecraftName(ctx context.Context, actor string, userID string, minecraftName *string) (User, error) {
 	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
@@ -102,14 +134,26 @@ func (db *DB) SetMinecraftName(ctx context.Context, actor string, userID string,
 		return User{}, err
 	}
 
+	var oldName *string
+	if err := tx.QueryRow(ctx, `SELECT minecraft_name::text FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&oldName); err != nil {
+		return User{}, err
+	}
+
 	row := tx.QueryRow(ctx, `
         UPDATE users SET minecraft_name = $2
         WHERE id = $1
-        RETURNING id, discord_user_id, discord_username, minecraft_name, age, created_at, updated_at
+        RETURNING `+userColumns+`
     `, userID, minecraftName)
 
 	var out User
-	if err := row.Scan(&out.ID, &out.DiscordUserID, &out.DiscordUsername, &out.MinecraftName, &out.Age, &out.CreatedAt, &out.UpdatedAt); err != nil {
+	if err := scanUser(row, &out); err != nil {
+		if isUniqueViolation(err) {
+			return User{}, ErrMinecraftNameTaken
+		}
+		return User{}, err
+	}
+
+	if err := recordNameChange(ctx, tx, actor, userID, out.MinecraftUUID, oldName, minecraftName); err != nil {
 		return User{}, err
 	}
 	if err := tx.Commit(ctx); err != nil {
@@ -118,8 +162,33 @@ func (db *DB) SetMinecraftName(ctx context.Context, actor string, userID string,
 	return out, nil
 }
 
-// CreateOrUpdateApplication sets or updates an application for a user.
-func (db *DB) CreateOrUpdateApplication(ctx context.Context, actor string, app Application) (Application, error) {
+// recordNameChange adds a minecraft_name_history row when the name actually
+// differs.
+func recordNameChange(ctx context.Context, tx pgx.Tx, actor string, userID string, minecraftUUID *string, oldName *string, newName *string) error {
+	// compare as text: citext equality would hide case-only renames
+	if equalStringPtr(oldName, newName) {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+        INSERT INTO minecraft_name_history (user_id, minecraft_uuid, old_name, new_name, actor)
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+    `, userID, minecraftUUID, oldName, newName, actor)
+	return err
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// CreateOrUpdateApplication stores a submission as the user's current
+// application. An applicant still waiting for review has their answers
+// replaced in place; a denied user gets a new attempt once cooldown has
+// passed, and their previous attempt is kept read-only. See CheckCanApply for
+// the errors returned when neither applies.
+func (db *DB) CreateOrUpdateApplication(ctx context.Context, actor string, app Application, cooldown time.Duration) (Application, error) {
 	if app.UserID == "" {
 		return Application{}, errors.New("user_id required")
 	}
@@ -127,6 +196,13 @@ func (db *DB) CreateOrUpdateApplication(ctx context.Context, actor string, app A
 	if app.Answers == nil {
 		app.Answers = map[string]any{}
 	}
+	// New applications always start as applicants; later moves go through UpdateApplicationStatus.
+	if app.Status == "" {
+		app.Status = StatusApplicant
+	}
+	if app.Status != StatusApplicant {
+		return Application{}, &TransitionError{To: app.Status}
+	}
 	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return Application{}, err
@@ -136,51 +212,43 @@ func (db *DB) CreateOrUpdateApplication(ctx context.Context, actor string, app A
 		return Application{}, err
 	}
 
-	row := tx.QueryRow(ctx, `
-        INSERT INTO applications (user_id, answers, status)
-        VALUES ($1, $2, $3)
-        ON CONFLICT (user_id)
-        DO UPDATE SET answers = EXCLUDED.answers, status = EXCLUDED.status
-        RETURNING id, user_id, answers, status, created_at, updated_at
-    `, app.UserID, app.Answers, app.Status)
-
-	var out Application
-	var answersRaw []byte
-	if err := row.Scan(&out.ID, &out.UserID, &answersRaw, &out.Status, &out.CreatedAt, &out.UpdatedAt); err != nil {
-		return Application{}, err
-	}
-	if err := json.Unmarshal(answersRaw, &out.Answers); err != nil {
+	current, err := getCurrentAttempt(ctx, tx, app.UserID, true)
+	if err != nil {
 		return Application{}, err
 	}
-	if err := tx.Commit(ctx); err != nil {
+	if err := checkBannedApplicant(ctx, tx, app.UserID, current); err != nil {
 		return Application{}, err
 	}
-	return out, nil
-}
-
-// UpdateApplicationStatus updates just the status.
-func (db *DB) UpdateApplicationStatus(ctx context.Context, actor string, applicationID string, status Status) (Application, error) {
-	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	fresh, err := current.newAttempt(time.Now(), cooldown)
 	if err != nil {
 		return Application{}, err
 	}
-	defer func() { _ = tx.Rollback(ctx) }()
-	if err := withActor(ctx, tx, actor); err != nil {
-		return Application{}, err
-	}
 
-	row := tx.QueryRow(ctx, `
-        UPDATE applications SET status = $2
-        WHERE id = $1
-        RETURNING id, user_id, answers, status, created_at, updated_at
-    `, applicationID, status)
+	var row pgx.Row
+	switch {
+	case !fresh:
+		row = tx.QueryRow(ctx, `
+            UPDATE applications SET answers = $2, form_version = $3
+            WHERE id = $1
+            RETURNING `+applicationColumns+`
+        `, current.ID, app.Answers, app.FormVersion)
+	default:
+		attempt := 1
+		if current != nil {
+			if _, err := tx.Exec(ctx, `UPDATE applications SET superseded_at = now() WHERE id = $1`, current.ID); err != nil {
+				return Application{}, err
+			}
+			attempt = current.Attempt + 1
+		}
+		row = tx.QueryRow(ctx, `
+            INSERT INTO applications (user_id, attempt, answers, status, form_version)
+            VALUES ($1, $2, $3, $4, $5)
+            RETURNING `+applicationColumns+`
+        `, app.UserID, attempt, app.Answers, app.Status, app.FormVersion)
+	}
 
 	var out Application
-	var answersRaw []byte
-	if err := row.Scan(&out.ID, &out.UserID, &answersRaw, &out.Status, &out.CreatedAt, &out.UpdatedAt); err != nil {
-		return Application{}, err
-	}
-	if err := json.Unmarshal(answersRaw, &out.Answers); err != nil {
+	if err := scanApplication(row, &out); err != nil {
 		return Application{}, err
 	}
 	if err := tx.Commit(ctx); err != nil {
@@ -192,11 +260,11 @@ func (db *DB) UpdateApplicationStatus(ctx context.Context, actor string, applica
 // GetUserByDiscordID finds a user by discord_user_id.
 func (db *DB) GetUserByDiscordID(ctx context.Context, discordUserID int64) (*User, error) {
 	row := db.pool.QueryRow(ctx, `
-        SELECT id, discord_user_id, discord_username, minecraft_name, age, created_at, updated_at
+        SELECT `+userColumns+`
         FROM users WHERE discord_user_id = $1
     `, discordUserID)
 	var u User
-	if err := row.Scan(&u.ID, &u.DiscordUserID, &u.DiscordUsername, &u.MinecraftName, &u.Age, &u.CreatedAt, &u.UpdatedAt); err != nil {
+	if err := scanUser(row, &u); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
 		}
@@ -205,29 +273,43 @@ func (db *DB) GetUserByDiscordID(ctx context.Context, discordUserID int64) (*Use
 	return &u, nil
 }
 
-// GetApplicationByUser returns the application for a user if present.
-func (db *DB) GetApplicationByUser(ctx context.Context, userID string) (*Application, error) {
+// GetUserByID finds a user by primary key.
+func (db *DB) GetUserByID(ctx context.Context, userID string) (*User, error) {
 	row := db.pool.QueryRow(ctx, `
-        SELECT id, user_id, answers, status, created_at, updated_at
-        FROM applications WHERE user_id = $1
+        SELECT `+userColumns+`
+        FROM users WHERE id = $1
     `, userID)
-	var a Application
-	var answersRaw []byte
-	if err := row.Scan(&a.ID, &a.UserID, &answersRaw, &a.Status, &a.CreatedAt, &a.UpdatedAt); err != nil {
+	var u User
+	if err := scanUser(row, &u); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	if err := json.Unmarshal(answersRaw, &a.Answers); err != nil {
+	return &u, nil
+}
+
+// GetApplicationByUser returns the user's current application if present.
+func (db *DB) GetApplicationByUser(ctx context.Context, userID string) (*Application, error) {
+	row := db.pool.QueryRow(ctx, `
+        SELECT `+applicationColumns+`
+        FROM applications WHERE user_id = $1 AND superseded_at IS NULL
+    `, userID)
+	var a Application
+	if err := scanApplication(row, &a); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
 		return nil, err
 	}
 	return &a, nil
 }
 
-// ListenAppEvents subscribes to the `app_events` channel and emits decoded events.
+// ListenOutbox subscribes to the `app_events` channel and emits a wake-up for
+// each notification. Notifications carry no event: consumers read the events
+// themselves with FetchEvents, so missed notifications lose nothing.
 // Cancel the provided context to stop listening; the returned error channel will then close.
-func (db *DB) ListenAppEvents(ctx context.Context) (<-chan AppEvent, <-chan error, error) {
+func (db *DB) ListenOutbox(ctx context.Context) (<-chan struct{}, <-chan error, error) {
 	// Dedicated connection for LISTEN/NOTIFY is recommended
 	conn, err := db.pool.Acquire(ctx)
 	if err != nil {
@@ -241,11 +323,11 @@ func (db *DB) ListenAppEvents(ctx context.Context) (<-chan AppEvent, <-chan erro
 		return nil, nil, err
 	}
 
-	events := make(chan AppEvent)
+	wake := make(chan struct{}, 16)
 	errs := make(chan error, 1)
 
 	go func() {
-		defer close(events)
+		defer close(wake)
 		defer close(errs)
 		defer func() {
 			// try to unlisten, then release
@@ -262,7 +344,7 @@ func (db *DB) ListenAppEvents(ctx context.Context) (<-chan AppEvent, <-chan erro
 
 			// Wait for notification with a timeout to allow context checks
 			ctxWait, cancel := context.WithTimeout(ctx, 55*time.Second)
-			notif, err := raw.WaitForNotification(ctxWait)
+			_, err := raw.WaitForNotification(ctxWait)
 			cancel()
 			if err != nil {
 				// If deadline exceeded due to timeout, keep loop alive to re-check ctx
@@ -276,14 +358,13 @@ func (db *DB) ListenAppEvents(ctx context.Context) (<-chan AppEvent, <-chan erro
 				return
 			}
 
-			var ev AppEvent
-			if err := json.Unmarshal([]byte(notif.Payload), &ev); err != nil {
-				errs <- fmt.Errorf("decode notify payload: %w", err)
-				continue
+			select {
+			case wake <- struct{}{}:
+			default:
+				// a pending wake-up already covers this one
 			}
-			events <- ev
 		}
 	}()
 
-	return events, errs, nil
+	return wake, errs, nil
 }
@@ -0,0 +1,45 @@
+package database_service
+
+import (
+	"context"
+	"errors"
+)
+
+// Decision is a reviewer action on an application.
+type Decision string
+
+const (
+	DecisionAccept    Decision = "accept"
+	DecisionDeny      Decision = "deny"
+	DecisionInterview Decision = "interview"
+)
+
+var ErrUnknownDecision = errors.New("unknown decision")
+
+// Target returns the status a decision moves an application to.
+func (d Decision) Target() (Status, bool) {
+	switch d {
+	case DecisionAccept:
+		return StatusMember, true
+	case DecisionInterview:
+		return StatusInterviewPending, true
+	case DecisionDeny:
+		return StatusDenied, true
+	}
+	return "", false
+}
+
+// ApplyDecision translates a reviewer decision into a status change.
+// reasonCode picks a rejection reason and only applies to DecisionDeny.
+func (db *DB) ApplyDecision(ctx context.Context, actor string, applicationID string, d Decision, reviewer string, reason string, reasonCode string) (Application, error) {
+	to, ok := d.Target()
+	if !ok {
+		return Application{}, ErrUnknownDecision
+	}
+	return db.UpdateApplicationStatus(ctx, actor, applicationID, StatusChange{
+		To:         to,
+		Reason:     reason,
+		Reviewer:   reviewer,
+		ReasonCode: reasonCode,
+	})
+}
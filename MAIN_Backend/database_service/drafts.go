@@ -0,0 +1,67 @@
+package database_service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const draftColumns = "user_id, answers, age, minecraft_name, form_version, created_at, updated_at"
+
+func scanDraft(row pgx.Row, d *ApplicationDraft) error {
+	var raw []byte
+	if err := row.Scan(&d.UserID, &raw, &d.Age, &d.MinecraftName, &d.FormVersion, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, &d.Answers)
+}
+
+// SaveDraft merges a partial draft into the stored one. Answer keys present in
+// d.Answers overwrite stored keys, other keys are kept; nil profile fields
+// leave the stored values untouched.
+func (db *DB) SaveDraft(ctx context.Context, d ApplicationDraft) (ApplicationDraft, error) {
+	if d.UserID == "" {
+		return ApplicationDraft{}, errors.New("user_id required")
+	}
+	if d.Answers == nil {
+		d.Answers = map[string]any{}
+	}
+	var out ApplicationDraft
+	err := scanDraft(db.pool.QueryRow(ctx, `
+        INSERT INTO application_drafts (user_id, answers, age, minecraft_name, form_version)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (user_id)
+        DO UPDATE SET answers        = application_drafts.answers || EXCLUDED.answers,
+                      age            = COALESCE(EXCLUDED.age, application_drafts.age),
+                      minecraft_name = COALESCE(EXCLUDED.minecraft_name, application_drafts.minecraft_name),
+                      form_version   = COALESCE(EXCLUDED.form_version, application_drafts.form_version)
+        RETURNING `+draftColumns+`
+    `, d.UserID, d.Answers, d.Age, d.MinecraftName, d.FormVersion), &out)
+	if err != nil {
+		return ApplicationDraft{}, err
+	}
+	return out, nil
+}
+
+// GetDraft returns the user's draft, or nil if they have none.
+func (db *DB) GetDraft(ctx context.Context, userID string) (*ApplicationDraft, error) {
+	var d ApplicationDraft
+	err := scanDraft(db.pool.QueryRow(ctx, `
+        SELECT `+draftColumns+` FROM application_drafts WHERE user_id = $1
+    `, userID), &d)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// DeleteDraft clears the user's draft, typically after a successful submit.
+func (db *DB) DeleteDraft(ctx context.Context, userID string) error {
+	_, err := db.pool.Exec(ctx, `DELETE FROM application_drafts WHERE user_id = $1`, userID)
+	return err
+}
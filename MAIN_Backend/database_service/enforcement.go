@@ -0,0 +1,217 @@
+package database_service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Ban enforcement targets.
+const (
+	EnforceDiscord   = "discord"
+	EnforceMinecraft = "minecraft"
+)
+
+// Ban enforcement actions and states.
+const (
+	EnforceBan   = "ban"
+	EnforceUnban = "unban"
+
+	EnforcementPending = "pending"
+	EnforcementApplied = "applied"
+	EnforcementFailed  = "failed"
+	// EnforcementSkipped marks targets the user cannot be found on, such as a
+	// player who never linked a Minecraft account
+	EnforcementSkipped = "skipped"
+)
+
+var ErrEnforcementNotFound = errors.New("failed ban enforcement not found")
+
+// EnforcementResult is the outcome of applying a ban enforcement once.
+type EnforcementResult struct {
+	// Ref identifies who was acted on at the target (Discord id, player name)
+	Ref    string
+	Detail string
+	Err    error
+	// Skipped means there was nothing to act on; it is not retried
+	Skipped bool
+}
+
+const enforcementColumns = `e.id, e.user_id, e.ban_id, e.target, e.action, e.state, e.attempts, e.next_attempt_at,
+        e.target_ref, e.detail, e.last_error, e.applied_at, e.created_at, e.updated_at,
+        u.discord_user_id, u.minecraft_name, u.minecraft_uuid, b.reason, b.expires_at`
+
+func scanEnforcement(row pgx.Row, e *BanEnforcement) error {
+	return row.Scan(&e.ID, &e.UserID, &e.BanID, &e.Target, &e.Action, &e.State, &e.Attempts, &e.NextAttemptAt,
+		&e.TargetRef, &e.Detail, &e.LastError, &e.AppliedAt, &e.CreatedAt, &e.UpdatedAt,
+		&e.DiscordUserID, &e.MinecraftName, &e.MinecraftUUID, &e.BanReason, &e.BanExpiresAt)
+}
+
+const enforcementFrom = `FROM ban_enforcements e
+        JOIN users u ON u.id = e.user_id
+        LEFT JOIN bans b ON b.id = e.ban_id`
+
+// PlanBanEnforcement records what targets should do about a user after their
+// application status changed: ban them when banned is true, otherwise undo
+// any ban still applied. Replanning the action already planned is a no-op, so
+// redelivered events do not repeat work.
+func (db *DB) PlanBanEnforcement(ctx context.Context, userID string, banned bool, targets []string) error {
+	if banned {
+		_, err := db.pool.Exec(ctx, `
+            INSERT INTO ban_enforcements (user_id, ban_id, target, action)
+            SELECT $1, (SELECT id FROM bans WHERE user_id = $1 AND lifted_at IS NULL), t, 'ban'
+            FROM unnest($2::text[]) AS t
+            ON CONFLICT (user_id, target) DO UPDATE
+            SET action = 'ban', ban_id = EXCLUDED.ban_id, state = 'pending', attempts = 0,
+                next_attempt_at = now(), last_error = NULL, detail = NULL
+            WHERE ban_enforcements.action <> 'ban'
+               OR ban_enforcements.ban_id IS DISTINCT FROM EXCLUDED.ban_id
+        `, userID, targets)
+		return err
+	}
+	// only targets that were banned have anything to undo
+	_, err := db.pool.Exec(ctx, `
+        UPDATE ban_enforcements
+        SET action = 'unban', state = 'pending', attempts = 0, next_attempt_at = now(),
+            last_error = NULL, detail = NULL
+        WHERE user_id = $1 AND target = ANY($2) AND action = 'ban' AND state <> 'skipped'
+    `, userID, targets)
+	return err
+}
+
+// ListDueEnforcements returns pending enforcements whose next attempt has come,
+// with the user's identities and the ban attached.
+func (db *DB) ListDueEnforcements(ctx context.Context, limit int) ([]BanEnforcement, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	return db.queryEnforcements(ctx, `WHERE e.state = 'pending' AND e.next_attempt_at <= now() ORDER BY e.next_attempt_at LIMIT $1`, limit)
+}
+
+// ListBanEnforcements returns enforcements, most recently changed first,
+// optionally for one user and in one state.
+func (db *DB) ListBanEnforcements(ctx context.Context, userID string, state string, limit int, offset int) ([]BanEnforcement, error) {
+	where := "WHERE 1=1"
+	args := []any{}
+	if userID != "" {
+		args = append(args, userID)
+		where += " AND e.user_id = $" + strconv.Itoa(len(args))
+	}
+	if state != "" {
+		args = append(args, state)
+		where += " AND e.state = $" + strconv.Itoa(len(args))
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	args = append(args, limit, offset)
+	return db.queryEnforcements(ctx, where+" ORDER BY e.updated_at DESC LIMIT $"+strconv.Itoa(len(args)-1)+" OFFSET $"+strconv.Itoa(len(args)), args...)
+}
+
+func (db *DB) queryEnforcements(ctx context.Context, where string, args ...any) ([]BanEnforcement, error) {
+	rows, err := db.pool.Query(ctx, `SELECT `+enforcementColumns+` `+enforcementFrom+` `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []BanEnforcement
+	for rows.Next() {
+		var e BanEnforcement
+		if err := scanEnforcement(rows, &e); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// RecordEnforcement stores the outcome of an attempt at e. Failures are
+// retried with backoff under policy and marked failed once it gives up. If the
+// plan changed while the attempt ran (a ban lifted mid-way), the result is
+// dropped so the newer action still runs.
+func (db *DB) RecordEnforcement(ctx context.Context, e BanEnforcement, res EnforcementResult, policy RetryPolicy) (string, error) {
+	var ref, detail *string
+	if res.Ref != "" {
+		ref = &res.Ref
+	}
+	if res.Detail != "" {
+		detail = &res.Detail
+	}
+
+	var state string
+	var err error
+	switch {
+	case res.Err == nil:
+		state = EnforcementApplied
+		if res.Skipped {
+			state = EnforcementSkipped
+		}
+		err = db.pool.QueryRow(ctx, `
+            UPDATE ban_enforcements
+            SET state = $3, attempts = attempts + 1, target_ref = COALESCE($4, target_ref), detail = $5,
+                last_error = NULL, applied_at = now()
+            WHERE id = $1 AND action = $2 AND state = 'pending'
+            RETURNING state
+        `, e.ID, e.Action, state, ref, detail).Scan(&state)
+	default:
+		attempts := e.Attempts + 1
+		state = EnforcementPending
+		if attempts >= policy.MaxAttempts {
+			state = EnforcementFailed
+		}
+		err = db.pool.QueryRow(ctx, `
+            UPDATE ban_enforcements
+            SET state = $3, attempts = $4, target_ref = COALESCE($5, target_ref), last_error = $6,
+                next_attempt_at = now() + make_interval(secs => $7)
+            WHERE id = $1 AND action = $2 AND state = 'pending'
+            RETURNING state
+        `, e.ID, e.Action, state, attempts, ref, res.Err.Error(), policy.Backoff(attempts).Seconds()).Scan(&state)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return state, err
+}
+
+// RequeueSkippedEnforcements puts Minecraft bans that were skipped for lack of
+// a linked account back in the queue, once the user links one.
+func (db *DB) RequeueSkippedEnforcements(ctx context.Context, userID string) error {
+	_, err := db.pool.Exec(ctx, `
+        UPDATE ban_enforcements
+        SET state = 'pending', attempts = 0, next_attempt_at = now(), last_error = NULL, detail = NULL
+        WHERE user_id = $1 AND target = 'minecraft' AND action = 'ban' AND state = 'skipped'
+    `, userID)
+	return err
+}
+
+// RetryBanEnforcement puts a failed enforcement back in the queue with a
+// fresh attempt budget.
+func (db *DB) RetryBanEnforcement(ctx context.Context, id int64) error {
+	tag, err := db.pool.Exec(ctx, `
+        UPDATE ban_enforcements SET state = 'pending', attempts = 0, next_attempt_at = now()
+        WHERE id = $1 AND state = 'failed'
+    `, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrEnforcementNotFound
+	}
+	return nil
+}
+
+// TimeoutUntil returns when a Discord timeout for e should end, or false when
+// the ban is permanent or outlasts maxTimeout and needs a guild ban instead.
+func (e BanEnforcement) TimeoutUntil(now time.Time, maxTimeout time.Duration) (time.Time, bool) {
+	if e.BanExpiresAt == nil || e.BanExpiresAt.Sub(now) > maxTimeout {
+		return time.Time{}, false
+	}
+	return *e.BanExpiresAt, true
+}
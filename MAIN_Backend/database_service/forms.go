@@ -0,0 +1,219 @@
+package database_service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrNoActiveForm = errors.New("no active application form")
+
+// AnswerErrors maps question ids to what is wrong with the submitted answer.
+type AnswerErrors map[string]string
+
+func (e AnswerErrors) Error() string {
+	ids := make([]string, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, id+": "+e[id])
+	}
+	return "invalid answers: " + strings.Join(parts, "; ")
+}
+
+// Validate checks a form definition is usable before it is published.
+func (d FormDefinition) Validate() error {
+	if len(d.Questions) == 0 {
+		return errors.New("form needs at least one question")
+	}
+	seen := map[string]bool{}
+	for _, q := range d.Questions {
+		if strings.TrimSpace(q.ID) == "" || strings.TrimSpace(q.Label) == "" {
+			return errors.New("every question needs an id and label")
+		}
+		if seen[q.ID] {
+			return fmt.Errorf("duplicate question id %q", q.ID)
+		}
+		seen[q.ID] = true
+		switch q.Type {
+		case QuestionText, QuestionTextarea, QuestionNumber:
+		case QuestionChoice:
+			if len(q.Choices) == 0 {
+				return fmt.Errorf("choice question %q has no choices", q.ID)
+			}
+		default:
+			return fmt.Errorf("question %q has unknown type %q", q.ID, q.Type)
+		}
+		if q.MinLength != nil && q.MaxLength != nil && *q.MinLength > *q.MaxLength {
+			return fmt.Errorf("question %q has min_length > max_length", q.ID)
+		}
+		if (q.Min != nil || q.Max != nil) && q.Type != QuestionNumber {
+			return fmt.Errorf("question %q has min or max but is not a number question", q.ID)
+		}
+		if q.Min != nil && q.Max != nil && *q.Min > *q.Max {
+			return fmt.Errorf("question %q has min > max", q.ID)
+		}
+	}
+	return nil
+}
+
+// ValidateAnswers checks submitted answers against the form and returns a
+// cleaned copy holding only known questions, with strings trimmed.
+func (d FormDefinition) ValidateAnswers(answers map[string]any) (map[string]any, error) {
+	out := map[string]any{}
+	errs := AnswerErrors{}
+	for _, q := range d.Questions {
+		v, present := answers[q.ID]
+		if s, ok := v.(string); ok {
+			v = strings.TrimSpace(s)
+			present = present && v != ""
+		}
+		if !present || v == nil {
+			if q.Required {
+				errs[q.ID] = "required"
+			}
+			continue
+		}
+
+		switch q.Type {
+		case QuestionText, QuestionTextarea:
+			s, ok := v.(string)
+			if !ok {
+				errs[q.ID] = "must be text"
+				continue
+			}
+			n := utf8.RuneCountInString(s)
+			if q.MinLength != nil && n < *q.MinLength {
+				errs[q.ID] = fmt.Sprintf("must be at least %d characters", *q.MinLength)
+				continue
+			}
+			if q.MaxLength != nil && n > *q.MaxLength {
+				errs[q.ID] = fmt.Sprintf("must be at most %d characters", *q.MaxLength)
+				continue
+			}
+		case QuestionNumber:
+			f, ok := v.(float64)
+			if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+				errs[q.ID] = "must be a number"
+				continue
+			}
+			if q.Min != nil && f < *q.Min {
+				errs[q.ID] = fmt.Sprintf("must be at least %g", *q.Min)
+				continue
+			}
+			if q.Max != nil && f > *q.Max {
+				errs[q.ID] = fmt.Sprintf("must be at most %g", *q.Max)
+				continue
+			}
+		case QuestionChoice:
+			s, ok := v.(string)
+			if !ok || !containsString(q.Choices, s) {
+				errs[q.ID] = "must be one of the listed choices"
+				continue
+			}
+		}
+		out[q.ID] = v
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return out, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// scanForm scans version, definition, active, created_by, created_at.
+func scanForm(row pgx.Row) (Form, error) {
+	var f Form
+	var raw []byte
+	if err := row.Scan(&f.Version, &raw, &f.Active, &f.CreatedBy, &f.CreatedAt); err != nil {
+		return Form{}, err
+	}
+	if err := json.Unmarshal(raw, &f.Definition); err != nil {
+		return Form{}, err
+	}
+	return f, nil
+}
+
+// GetActiveForm returns the form new applications are validated against.
+func (db *DB) GetActiveForm(ctx context.Context) (Form, error) {
+	f, err := scanForm(db.pool.QueryRow(ctx, `
+        SELECT version, definition, active, created_by, created_at
+        FROM application_forms WHERE active
+    `))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Form{}, ErrNoActiveForm
+	}
+	return f, err
+}
+
+// ListForms returns every form version, newest first.
+func (db *DB) ListForms(ctx context.Context) ([]Form, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT version, definition, active, created_by, created_at
+        FROM application_forms ORDER BY version DESC
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Form
+	for rows.Next() {
+		f, err := scanForm(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// PublishForm stores a new form version and makes it the active one.
+// Existing applications keep pointing at the version they were submitted with.
+func (db *DB) PublishForm(ctx context.Context, actor string, def FormDefinition) (Form, error) {
+	if err := def.Validate(); err != nil {
+		return Form{}, err
+	}
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Form{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return Form{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE application_forms SET active = false WHERE active`); err != nil {
+		return Form{}, err
+	}
+	f, err := scanForm(tx.QueryRow(ctx, `
+        INSERT INTO application_forms (definition, active, created_by)
+        VALUES ($1, true, NULLIF($2, ''))
+        RETURNING version, definition, active, created_by, created_at
+    `, def, actor))
+	if err != nil {
+		return Form{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Form{}, err
+	}
+	return f, nil
+}
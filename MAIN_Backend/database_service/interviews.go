@@ -0,0 +1,348 @@
+package database_service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Interview states.
+const (
+	InterviewScheduled = "scheduled"
+	InterviewPassed    = "passed"
+	InterviewFailed    = "failed"
+	InterviewNoShow    = "no_show"
+	InterviewCancelled = "cancelled"
+)
+
+var (
+	ErrSlotNotFound         = errors.New("interview slot not found")
+	ErrSlotTaken            = errors.New("interview slot already booked")
+	ErrSlotInPast           = errors.New("interview slot has already started")
+	ErrInvalidSlot          = errors.New("interview slot must end after it starts")
+	ErrNotAwaitingInterview = errors.New("application is not waiting for an interview")
+	ErrInterviewNotFound    = errors.New("interview not found")
+	ErrInterviewFinished    = errors.New("interview already has an outcome")
+	ErrUnknownOutcome       = errors.New("unknown interview outcome")
+)
+
+// outcomeDecisions maps an interview outcome to the decision it drives. A
+// no-show keeps the applicant in interview_pending so they can book again.
+var outcomeDecisions = map[string]Decision{
+	InterviewPassed: DecisionAccept,
+	InterviewFailed: DecisionDeny,
+	InterviewNoShow: "",
+}
+
+const slotColumns = "s.id, s.interviewer, s.interviewer_discord_id, s.starts_at, s.ends_at, s.created_at, s.cancelled_at"
+
+func scanSlot(row pgx.Row, s *InterviewSlot, extra ...any) error {
+	return row.Scan(append([]any{&s.ID, &s.Interviewer, &s.InterviewerDiscordID, &s.StartsAt, &s.EndsAt, &s.CreatedAt, &s.CancelledAt}, extra...)...)
+}
+
+const interviewColumns = "i.id, i.application_id, u.discord_user_id, i.slot_id, i.state, i.notes, i.recorded_by, i.booked_at, i.notified_at, i.reminded_at, i.finished_at"
+
+func scanInterview(row pgx.Row, iv *Interview) error {
+	iv.Slot = &InterviewSlot{}
+	s := iv.Slot
+	return row.Scan(&iv.ID, &iv.ApplicationID, &iv.DiscordUserID, &iv.SlotID, &iv.State, &iv.Notes, &iv.RecordedBy, &iv.BookedAt, &iv.NotifiedAt, &iv.RemindedAt, &iv.FinishedAt,
+		&s.ID, &s.Interviewer, &s.InterviewerDiscordID, &s.StartsAt, &s.EndsAt, &s.CreatedAt, &s.CancelledAt)
+}
+
+// CreateInterviewSlot publishes an availability slot.
+func (db *DB) CreateInterviewSlot(ctx context.Context, slot InterviewSlot) (InterviewSlot, error) {
+	if !slot.EndsAt.After(slot.StartsAt) {
+		return InterviewSlot{}, ErrInvalidSlot
+	}
+	if !slot.StartsAt.After(time.Now()) {
+		return InterviewSlot{}, ErrSlotInPast
+	}
+	var out InterviewSlot
+	err := scanSlot(db.pool.QueryRow(ctx, `
+        INSERT INTO interview_slots AS s (interviewer, interviewer_discord_id, starts_at, ends_at)
+        VALUES ($1, $2, $3, $4)
+        RETURNING `+slotColumns+`
+    `, slot.Interviewer, slot.InterviewerDiscordID, slot.StartsAt, slot.EndsAt), &out)
+	return out, err
+}
+
+// ListInterviewSlots returns slots starting within [from, to), soonest first.
+// With openOnly, cancelled and booked slots are left out.
+func (db *DB) ListInterviewSlots(ctx context.Context, from time.Time, to time.Time, openOnly bool) ([]InterviewSlot, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+slotColumns+`, b.id
+        FROM interview_slots s
+        LEFT JOIN interviews b ON b.slot_id = s.id AND b.state <> 'cancelled'
+        WHERE s.starts_at >= $1 AND s.starts_at < $2
+          AND (NOT $3 OR (s.cancelled_at IS NULL AND b.id IS NULL))
+        ORDER BY s.starts_at
+    `, from, to, openOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []InterviewSlot
+	for rows.Next() {
+		var s InterviewSlot
+		if err := scanSlot(rows, &s, &s.InterviewID); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// CancelInterviewSlot withdraws a slot together with its booking, if any. The
+// applicant stays in interview_pending and can book another slot.
+func (db *DB) CancelInterviewSlot(ctx context.Context, slotID string) error {
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tag, err := tx.Exec(ctx, `UPDATE interview_slots SET cancelled_at = now() WHERE id = $1 AND cancelled_at IS NULL`, slotID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSlotNotFound
+	}
+	if _, err := tx.Exec(ctx, `
+        UPDATE interviews SET state = 'cancelled', finished_at = now()
+        WHERE slot_id = $1 AND state = 'scheduled'
+    `, slotID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// BookInterview books slotID for the user's application, replacing any
+// interview it already had scheduled.
+func (db *DB) BookInterview(ctx context.Context, actor string, userID string, slotID string) (Interview, error) {
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Interview{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return Interview{}, err
+	}
+
+	var appID string
+	var status Status
+	if err := tx.QueryRow(ctx, `SELECT id, status FROM applications WHERE user_id = $1 AND superseded_at IS NULL FOR UPDATE`, userID).Scan(&appID, &status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Interview{}, ErrApplicationNotFound
+		}
+		return Interview{}, err
+	}
+	if status != StatusInterviewPending {
+		return Interview{}, ErrNotAwaitingInterview
+	}
+
+	var startsAt time.Time
+	if err := tx.QueryRow(ctx, `SELECT starts_at FROM interview_slots WHERE id = $1 AND cancelled_at IS NULL FOR UPDATE`, slotID).Scan(&startsAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Interview{}, ErrSlotNotFound
+		}
+		return Interview{}, err
+	}
+	if !startsAt.After(time.Now()) {
+		return Interview{}, ErrSlotInPast
+	}
+
+	if _, err := tx.Exec(ctx, `
+        UPDATE interviews SET state = 'cancelled', finished_at = now()
+        WHERE application_id = $1 AND state = 'scheduled'
+    `, appID); err != nil {
+		return Interview{}, err
+	}
+	var id string
+	if err := tx.QueryRow(ctx, `INSERT INTO interviews (application_id, slot_id) VALUES ($1, $2) RETURNING id`, appID, slotID).Scan(&id); err != nil {
+		if isUniqueViolation(err) {
+			return Interview{}, ErrSlotTaken
+		}
+		return Interview{}, err
+	}
+	iv, err := getInterviewTx(ctx, tx, id)
+	if err != nil {
+		return Interview{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Interview{}, err
+	}
+	return iv, nil
+}
+
+// CancelInterviewBooking cancels the user's scheduled interview.
+func (db *DB) CancelInterviewBooking(ctx context.Context, userID string) error {
+	tag, err := db.pool.Exec(ctx, `
+        UPDATE interviews i SET state = 'cancelled', finished_at = now()
+        FROM applications a
+        WHERE a.id = i.application_id AND a.user_id = $1 AND a.superseded_at IS NULL AND i.state = 'scheduled'
+    `, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInterviewNotFound
+	}
+	return nil
+}
+
+func getInterviewTx(ctx context.Context, q queryer, interviewID string) (Interview, error) {
+	var iv Interview
+	err := scanInterview(q.QueryRow(ctx, `
+        SELECT `+interviewColumns+`, `+slotColumns+`
+        FROM interviews i
+        JOIN interview_slots s ON s.id = i.slot_id
+        JOIN applications a ON a.id = i.application_id
+        JOIN users u ON u.id = a.user_id
+        WHERE i.id = $1
+    `, interviewID), &iv)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Interview{}, ErrInterviewNotFound
+	}
+	return iv, err
+}
+
+// ListInterviews returns every interview of an application, newest first.
+func (db *DB) ListInterviews(ctx context.Context, applicationID string) ([]Interview, error) {
+	return db.queryInterviews(ctx, `WHERE i.application_id = $1 ORDER BY i.booked_at DESC`, applicationID)
+}
+
+// GetScheduledInterview returns the user's upcoming interview if one is booked.
+func (db *DB) GetScheduledInterview(ctx context.Context, userID string) (*Interview, error) {
+	ivs, err := db.queryInterviews(ctx, `WHERE a.user_id = $1 AND a.superseded_at IS NULL AND i.state = 'scheduled'`, userID)
+	if err != nil || len(ivs) == 0 {
+		return nil, err
+	}
+	return &ivs[0], nil
+}
+
+// ListInterviewsToNotify returns scheduled interviews whose booking
+// confirmation has not been sent yet.
+func (db *DB) ListInterviewsToNotify(ctx context.Context) ([]Interview, error) {
+	return db.queryInterviews(ctx, `WHERE i.state = 'scheduled' AND i.notified_at IS NULL ORDER BY i.booked_at`)
+}
+
+// ListInterviewsToRemind returns scheduled interviews starting within lead
+// that have not been reminded of yet.
+func (db *DB) ListInterviewsToRemind(ctx context.Context, lead time.Duration) ([]Interview, error) {
+	return db.queryInterviews(ctx, `
+        WHERE i.state = 'scheduled' AND i.reminded_at IS NULL
+          AND s.starts_at > now() AND s.starts_at <= now() + make_interval(secs => $1)
+        ORDER BY s.starts_at`, lead.Seconds())
+}
+
+func (db *DB) queryInterviews(ctx context.Context, where string, args ...any) ([]Interview, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+interviewColumns+`, `+slotColumns+`
+        FROM interviews i
+        JOIN interview_slots s ON s.id = i.slot_id
+        JOIN applications a ON a.id = i.application_id
+        JOIN users u ON u.id = a.user_id
+        `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Interview
+	for rows.Next() {
+		var iv Interview
+		if err := scanInterview(rows, &iv); err != nil {
+			return nil, err
+		}
+		out = append(out, iv)
+	}
+	return out, rows.Err()
+}
+
+// MarkInterviewNotified records that the booking confirmation went out.
+func (db *DB) MarkInterviewNotified(ctx context.Context, interviewID string) error {
+	_, err := db.pool.Exec(ctx, `UPDATE interviews SET notified_at = now() WHERE id = $1`, interviewID)
+	return err
+}
+
+// MarkInterviewReminded records that the reminder went out.
+func (db *DB) MarkInterviewReminded(ctx context.Context, interviewID string) error {
+	_, err := db.pool.Exec(ctx, `UPDATE interviews SET reminded_at = now() WHERE id = $1`, interviewID)
+	return err
+}
+
+// RecordInterviewOutcome closes a scheduled interview. Passed and failed
+// outcomes apply the matching decision in the same transaction; a no-show only
+// marks the interview so the applicant can book again.
+func (db *DB) RecordInterviewOutcome(ctx context.Context, actor string, interviewID string, reviewer string, outcome string, notes string) (Interview, *Application, error) {
+	decision, ok := outcomeDecisions[outcome]
+	if !ok {
+		return Interview{}, nil, ErrUnknownOutcome
+	}
+	notes = strings.TrimSpace(notes)
+
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Interview{}, nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return Interview{}, nil, err
+	}
+
+	var appID, state string
+	if err := tx.QueryRow(ctx, `SELECT application_id, state FROM interviews WHERE id = $1 FOR UPDATE`, interviewID).Scan(&appID, &state); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Interview{}, nil, ErrInterviewNotFound
+		}
+		return Interview{}, nil, err
+	}
+	if state != InterviewScheduled {
+		return Interview{}, nil, ErrInterviewFinished
+	}
+	if _, err := tx.Exec(ctx, `
+        UPDATE interviews SET state = $2, notes = NULLIF($3, ''), recorded_by = $4, finished_at = now()
+        WHERE id = $1
+    `, interviewID, outcome, notes, reviewer); err != nil {
+		return Interview{}, nil, err
+	}
+
+	var app *Application
+	if decision != "" {
+		to, _ := decision.Target()
+		reason := "interview " + outcome
+		if notes != "" {
+			reason += ": " + notes
+		}
+		a, err := transitionTx(ctx, tx, actor, appID, StatusChange{To: to, Reason: reason, Reviewer: reviewer})
+		if err != nil {
+			return Interview{}, nil, err
+		}
+		app = &a
+	}
+
+	iv, err := getInterviewTx(ctx, tx, interviewID)
+	if err != nil {
+		return Interview{}, nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Interview{}, nil, err
+	}
+	return iv, app, nil
+}
+
+// CountNoShows returns how many interviews in the list were missed.
+func CountNoShows(interviews []Interview) int {
+	n := 0
+	for _, iv := range interviews {
+		if iv.State == InterviewNoShow {
+			n++
+		}
+	}
+	return n
+}
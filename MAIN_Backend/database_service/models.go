@@ -1,6 +1,7 @@
 package database_service
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -12,6 +13,9 @@ const (
 	StatusInterviewPending Status = "interview_pending"
 	StatusMember           Status = "member"
 	StatusBanned           Status = "banned"
+	// StatusDenied is a rejection that is not a ban; the user may apply again
+	// once the re-application cooldown has passed.
+	StatusDenied Status = "denied"
 )
 
 // User is a projection of the `users` table.
@@ -20,19 +24,183 @@ type User struct {
 	DiscordUserID   int64     `json:"discord_user_id"`
 	DiscordUsername string    `json:"discord_username"`
 	MinecraftName   *string   `json:"minecraft_name,omitempty"`
+	MinecraftUUID   *string   `json:"minecraft_uuid,omitempty"`
 	Age             *int16    `json:"age,omitempty"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
 
-// Application mirrors the `applications` table.
+// MinecraftNameChange mirrors the `minecraft_name_history` table.
+type MinecraftNameChange struct {
+	ID            int64     `json:"id"`
+	UserID        string    `json:"user_id"`
+	MinecraftUUID *string   `json:"minecraft_uuid,omitempty"`
+	OldName       *string   `json:"old_name,omitempty"`
+	NewName       *string   `json:"new_name,omitempty"`
+	Actor         *string   `json:"actor,omitempty"`
+	ChangedAt     time.Time `json:"changed_at"`
+}
+
+// UserStatus pairs a user with the status of their application, if any.
+type UserStatus struct {
+	UserID        string  `json:"user_id"`
+	DiscordUserID int64   `json:"discord_user_id"`
+	MinecraftName *string `json:"minecraft_name,omitempty"`
+	MinecraftUUID *string `json:"minecraft_uuid,omitempty"`
+	Status        *Status `json:"status,omitempty"`
+}
+
+// Application mirrors the `applications` table. FormVersion is the
+// application_forms version the answers were validated against.
 type Application struct {
-	ID        string         `json:"id"`
-	UserID    string         `json:"user_id"`
-	Answers   map[string]any `json:"answers"`
-	Status    Status         `json:"status"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	// Attempt counts the user's applications from 1; SupersededAt is set once
+	// a newer attempt replaced this one, which leaves it read-only.
+	Attempt      int            `json:"attempt"`
+	Answers      map[string]any `json:"answers"`
+	Status       Status         `json:"status"`
+	FormVersion  *int           `json:"form_version,omitempty"`
+	SupersededAt *time.Time     `json:"superseded_at,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	// Score is filled in by FindApplications once reviewers scored the application.
+	Score *ScoreSummary `json:"score,omitempty"`
+}
+
+// ApplicationDetail is an application joined with its user and status history.
+type ApplicationDetail struct {
+	Application
+	User    User                 `json:"user"`
+	History []StatusHistoryEntry `json:"history"`
+}
+
+// StatusChange is a requested move of an application to another status.
+// Reason and Reviewer are mandatory and end up in application_status_history.
+type StatusChange struct {
+	To       Status `json:"to"`
+	Reason   string `json:"reason"`
+	Reviewer string `json:"reviewer"`
+	// Appeal marks the change as lifting a ban (an accepted appeal or an
+	// expired ban), which is the only way out of StatusBanned.
+	Appeal bool `json:"appeal,omitempty"`
+	// ReasonCode picks a rejection_reasons entry when denying; the applicant
+	// is sent its message. Reason may be left empty to use its label.
+	ReasonCode string `json:"reason_code,omitempty"`
+}
+
+// StatusHistoryEntry mirrors the `application_status_history` table.
+type StatusHistoryEntry struct {
+	ID            int64     `json:"id"`
+	ApplicationID string    `json:"application_id"`
+	From          Status    `json:"from_status"`
+	To            Status    `json:"to_status"`
+	Reason        string    `json:"reason"`
+	Reviewer      string    `json:"reviewer"`
+	ViaAppeal     bool      `json:"via_appeal"`
+	ReasonCode    *string   `json:"reason_code,omitempty"`
+	Actor         *string   `json:"actor,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// RejectionReason mirrors the `rejection_reasons` table. Message is the
+// applicant-facing text with {placeholders}, see RejectionPlaceholders.
+type RejectionReason struct {
+	Code      string    `json:"code"`
+	Label     string    `json:"label"`
+	Message   string    `json:"message"`
+	Active    bool      `json:"active"`
+	CreatedBy *string   `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DenialNotice is the message owed to an applicant whose application was denied.
+type DenialNotice struct {
+	ApplicationID string  `json:"application_id"`
+	DiscordUserID int64   `json:"discord_user_id"`
+	ReasonCode    *string `json:"reason_code,omitempty"`
+	Message       string  `json:"message"`
+}
+
+// NotificationTemplate mirrors the `notification_templates` table. Template
+// uses the placeholders in NotificationPlaceholders.
+type NotificationTemplate struct {
+	Status    Status    `json:"status"`
+	Template  string    `json:"template"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedBy *string   `json:"updated_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Notification mirrors the `notifications` table, with the recipient's
+// Discord id joined in.
+type Notification struct {
+	ID            int64      `json:"id"`
+	EventID       int64      `json:"event_id"`
+	ApplicationID string     `json:"application_id"`
+	UserID        string     `json:"user_id"`
+	DiscordUserID int64      `json:"discord_user_id"`
+	Status        Status     `json:"status"`
+	Message       string     `json:"message"`
+	State         string     `json:"state"`
+	Via           *string    `json:"via,omitempty"`
+	Attempts      int        `json:"attempts"`
+	LastError     *string    `json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// QuestionType is the input kind of a form question.
+type QuestionType string
+
+const (
+	QuestionText     QuestionType = "text"
+	QuestionTextarea QuestionType = "textarea"
+	QuestionNumber   QuestionType = "number"
+	QuestionChoice   QuestionType = "choice"
+)
+
+// FormQuestion is a single question of an application form.
+type FormQuestion struct {
+	ID        string       `json:"id"`
+	Label     string       `json:"label"`
+	Type      QuestionType `json:"type"`
+	Required  bool         `json:"required"`
+	MinLength *int         `json:"min_length,omitempty"`
+	MaxLength *int         `json:"max_length,omitempty"`
+	// Min and Max bound the answer to a number question
+	Min     *float64 `json:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty"`
+	Choices []string `json:"choices,omitempty"`
+}
+
+// FormDefinition is the jsonb document stored in application_forms.definition.
+type FormDefinition struct {
+	Title     string         `json:"title"`
+	Questions []FormQuestion `json:"questions"`
+}
+
+// Form mirrors the `application_forms` table.
+type Form struct {
+	Version    int            `json:"version"`
+	Definition FormDefinition `json:"definition"`
+	Active     bool           `json:"active"`
+	CreatedBy  *string        `json:"created_by,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// ApplicationDraft mirrors the `application_drafts` table.
+type ApplicationDraft struct {
+	UserID        string         `json:"user_id"`
+	Answers       map[string]any `json:"answers"`
+	Age           *int16         `json:"age,omitempty"`
+	MinecraftName *string        `json:"minecraft_username,omitempty"`
+	FormVersion   *int           `json:"form_version,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
 }
 
 // LoginToken represents a temporary token linked to a user for web login
@@ -45,15 +213,320 @@ type LoginToken struct {
 	Revoked   bool      `json:"revoked"`
 }
 
-// AppEvent is emitted by triggers via LISTEN/NOTIFY on channel `app_events`.
+// Session mirrors the `sessions` table: a server-side applicant login.
+type Session struct {
+	ID           string     `json:"id"`
+	UserID       string     `json:"user_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastSeenAt   time.Time  `json:"last_seen_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	MaxExpiresAt time.Time  `json:"max_expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	UserAgent    *string    `json:"user_agent,omitempty"`
+}
+
+// APIClient mirrors the `api_clients` table: a service allowed to call internal endpoints.
+type APIClient struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	Keys       []APIKey   `json:"keys,omitempty"`
+}
+
+// APIKey mirrors the `api_keys` table. The secret itself is never stored.
+type APIKey struct {
+	ID         string     `json:"id"`
+	ClientID   string     `json:"client_id"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// AppEvent is written by triggers to `event_outbox`; NOTIFY on channel
+// `app_events` only announces its id.
 // Only a subset of fields may be present depending on the table/action.
 type AppEvent struct {
+	// ID is the event's event_outbox id, increasing in commit order
+	ID            int64     `json:"id"`
 	Table         string    `json:"table"`
 	Action        string    `json:"action"`
 	RowID         string    `json:"row_id"`
 	UserID        *string   `json:"user_id,omitempty"`
 	Status        *Status   `json:"status,omitempty"`
 	MinecraftName *string   `json:"minecraft_name,omitempty"`
+	MinecraftUUID *string   `json:"minecraft_uuid,omitempty"`
 	DiscordUserID *int64    `json:"discord_user_id,omitempty"`
 	At            time.Time `json:"at"`
 }
+
+// AuditEntry mirrors the `audit_log` table. Before is nil for inserts and
+// After is nil for deletes.
+type AuditEntry struct {
+	ID        int64          `json:"id"`
+	Table     string         `json:"table"`
+	RowID     *string        `json:"row_id,omitempty"`
+	Action    string         `json:"action"`
+	Before    map[string]any `json:"before,omitempty"`
+	After     map[string]any `json:"after,omitempty"`
+	Actor     *string        `json:"actor,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// FieldChange is a single column that differs between two audit snapshots.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// TimelineEntry is an audit row together with its field-level diff.
+type TimelineEntry struct {
+	AuditEntry
+	Changes []FieldChange `json:"changes"`
+}
+
+// OutboxEvent is a raw `event_outbox` row as handed to a consumer. Attempts
+// counts earlier failed deliveries to that consumer.
+type OutboxEvent struct {
+	ID        int64           `json:"id"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// DeadLetter mirrors the `event_dead_letters` view.
+type DeadLetter struct {
+	Consumer  string          `json:"consumer"`
+	EventID   int64           `json:"event_id"`
+	Attempts  int             `json:"attempts"`
+	LastError *string         `json:"last_error,omitempty"`
+	DeadAt    time.Time       `json:"dead_at"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// WebhookEndpoint mirrors the `webhook_endpoints` table without its secret.
+// Empty filters match every event.
+type WebhookEndpoint struct {
+	ID          string     `json:"id"`
+	URL         string     `json:"url"`
+	Description *string    `json:"description,omitempty"`
+	Tables      []string   `json:"tables"`
+	Actions     []string   `json:"actions"`
+	Statuses    []string   `json:"statuses"`
+	CreatedBy   *string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DisabledAt  *time.Time `json:"disabled_at,omitempty"`
+}
+
+// WebhookDelivery mirrors the `webhook_deliveries` table: one event sent to
+// one endpoint, with the outcome of its latest attempt.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	EndpointID     string          `json:"endpoint_id"`
+	EventID        *int64          `json:"event_id,omitempty"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	State          string          `json:"state"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	ResponseBody   *string         `json:"response_body,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// ClaimedWebhookDelivery is a leased delivery with what is needed to send it.
+type ClaimedWebhookDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// WebhookAttempt is the outcome of sending a delivery once. Err is empty on
+// success; Status and Body are nil when no response was received.
+type WebhookAttempt struct {
+	Status *int
+	Body   *string
+	Err    string
+}
+
+// ApplicationVote mirrors the `application_votes` table.
+type ApplicationVote struct {
+	ID            int64     `json:"id"`
+	ApplicationID string    `json:"application_id"`
+	Stage         Status    `json:"stage"`
+	Reviewer      string    `json:"reviewer"`
+	Vote          Vote      `json:"vote"`
+	Comment       *string   `json:"comment,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// VoteTally counts the votes of one stage.
+type VoteTally struct {
+	Approve int `json:"approve"`
+	Deny    int `json:"deny"`
+	Abstain int `json:"abstain"`
+}
+
+// VoteResult is returned by CastVote. Decision and Application are set when
+// the vote settled the stage and the status was changed.
+type VoteResult struct {
+	Stage       Status       `json:"stage"`
+	Tally       VoteTally    `json:"tally"`
+	Decision    *Decision    `json:"decision,omitempty"`
+	Application *Application `json:"application,omitempty"`
+}
+
+// InterviewSlot mirrors the `interview_slots` table. InterviewID is set by
+// ListInterviewSlots when the slot is booked.
+type InterviewSlot struct {
+	ID                   string     `json:"id"`
+	Interviewer          string     `json:"interviewer"`
+	InterviewerDiscordID *int64     `json:"interviewer_discord_id,omitempty"`
+	StartsAt             time.Time  `json:"starts_at"`
+	EndsAt               time.Time  `json:"ends_at"`
+	CreatedAt            time.Time  `json:"created_at"`
+	CancelledAt          *time.Time `json:"cancelled_at,omitempty"`
+	InterviewID          *string    `json:"interview_id,omitempty"`
+}
+
+// Interview mirrors the `interviews` table, with its slot and the applicant's
+// Discord id attached.
+type Interview struct {
+	ID            string         `json:"id"`
+	ApplicationID string         `json:"application_id"`
+	DiscordUserID int64          `json:"discord_user_id"`
+	SlotID        string         `json:"slot_id"`
+	State         string         `json:"state"`
+	Notes         *string        `json:"notes,omitempty"`
+	RecordedBy    *string        `json:"recorded_by,omitempty"`
+	BookedAt      time.Time      `json:"booked_at"`
+	NotifiedAt    *time.Time     `json:"notified_at,omitempty"`
+	RemindedAt    *time.Time     `json:"reminded_at,omitempty"`
+	FinishedAt    *time.Time     `json:"finished_at,omitempty"`
+	Slot          *InterviewSlot `json:"slot,omitempty"`
+}
+
+// RubricCriterion is one scored aspect of an application. Reviewers give a
+// whole number between Min and Max; Weight sets its share of the total.
+type RubricCriterion struct {
+	ID          string  `json:"id"`
+	Label       string  `json:"label"`
+	Description string  `json:"description,omitempty"`
+	Weight      float64 `json:"weight"`
+	Min         int     `json:"min"`
+	Max         int     `json:"max"`
+}
+
+// RubricDefinition is the jsonb document stored in scoring_rubrics.definition.
+type RubricDefinition struct {
+	Title    string            `json:"title"`
+	Criteria []RubricCriterion `json:"criteria"`
+}
+
+// Rubric mirrors the `scoring_rubrics` table.
+type Rubric struct {
+	Version    int              `json:"version"`
+	Definition RubricDefinition `json:"definition"`
+	Active     bool             `json:"active"`
+	CreatedBy  *string          `json:"created_by,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+// ApplicationScore mirrors the `application_scores` table. Total is the
+// weighted score on a 0-100 scale; Deviation is Total minus the application's
+// average score.
+type ApplicationScore struct {
+	ID            int64          `json:"id"`
+	ApplicationID string         `json:"application_id"`
+	RubricVersion int            `json:"rubric_version"`
+	Reviewer      string         `json:"reviewer"`
+	Scores        map[string]int `json:"scores"`
+	Total         float64        `json:"total"`
+	Deviation     float64        `json:"deviation"`
+	Comment       *string        `json:"comment,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// ScoreSummary mirrors the `application_score_summaries` table. Spread is
+// the standard deviation of the reviewers' totals.
+type ScoreSummary struct {
+	Score     float64   `json:"score"`
+	Count     int       `json:"count"`
+	Spread    float64   `json:"spread"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ReviewerDeviation is how far a reviewer's scores sit from the average of
+// the applications they scored, on the 0-100 scale.
+type ReviewerDeviation struct {
+	Reviewer         string  `json:"reviewer"`
+	Scored           int     `json:"scored"`
+	MeanDeviation    float64 `json:"mean_deviation"`
+	MeanAbsDeviation float64 `json:"mean_abs_deviation"`
+}
+
+// Ban mirrors the `bans` table. A ban without ExpiresAt is permanent;
+// PreviousStatus is the application status restored when it is lifted. Both
+// it and ApplicationID are nil when the user was banned before applying.
+type Ban struct {
+	ID             string     `json:"id"`
+	UserID         string     `json:"user_id"`
+	ApplicationID  *string    `json:"application_id,omitempty"`
+	Reason         string     `json:"reason"`
+	Evidence       []string   `json:"evidence"`
+	IssuedBy       string     `json:"issued_by"`
+	IssuedAt       time.Time  `json:"issued_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	Appealable     bool       `json:"appealable"`
+	PreviousStatus *Status    `json:"previous_status,omitempty"`
+	LiftedAt       *time.Time `json:"lifted_at,omitempty"`
+	LiftedBy       *string    `json:"lifted_by,omitempty"`
+	LiftReason     *string    `json:"lift_reason,omitempty"`
+}
+
+// BanAppeal mirrors the `ban_appeals` table, with the banned user attached.
+type BanAppeal struct {
+	ID          string     `json:"id"`
+	BanID       string     `json:"ban_id"`
+	UserID      string     `json:"user_id"`
+	Message     string     `json:"message"`
+	State       string     `json:"state"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+	ReviewedBy  *string    `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+	Response    *string    `json:"response,omitempty"`
+}
+
+// BanEnforcement mirrors the `ban_enforcements` table, with the user's
+// identities and the ban it enforces (when one is recorded) attached.
+type BanEnforcement struct {
+	ID            int64      `json:"id"`
+	UserID        string     `json:"user_id"`
+	BanID         *string    `json:"ban_id,omitempty"`
+	Target        string     `json:"target"`
+	Action        string     `json:"action"`
+	State         string     `json:"state"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	TargetRef     *string    `json:"target_ref,omitempty"`
+	Detail        *string    `json:"detail,omitempty"`
+	LastError     *string    `json:"last_error,omitempty"`
+	AppliedAt     *time.Time `json:"applied_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	DiscordUserID int64      `json:"discord_user_id"`
+	MinecraftName *string    `json:"minecraft_name,omitempty"`
+	MinecraftUUID *string    `json:"minecraft_uuid,omitempty"`
+	BanReason     *string    `json:"ban_reason,omitempty"`
+	BanExpiresAt  *time.Time `json:"ban_expires_at,omitempty"`
+}
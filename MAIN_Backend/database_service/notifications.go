@@ -0,0 +1,256 @@
+package database_service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Notification delivery states and channels.
+const (
+	NotifyPending = "pending"
+	NotifySent    = "sent"
+	// NotifyFallback means DMs were closed and the applicant was mentioned in
+	// the fallback channel instead
+	NotifyFallback = "fallback"
+	NotifyFailed   = "failed"
+
+	NotifyViaDM      = "dm"
+	NotifyViaChannel = "channel"
+)
+
+var ErrInvalidTemplate = errors.New("template needs a known status and text using only known placeholders")
+
+// NotificationPlaceholders are the {placeholders} a notification template may
+// use. reason is the rejection message for denials and the ban reason for
+// bans.
+var NotificationPlaceholders = []string{"name", "minecraft_name", "status", "reason", "attempt"}
+
+var placeholderPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// knownPlaceholders reports whether text only uses placeholders from allowed.
+func knownPlaceholders(text string, allowed []string) bool {
+	for _, m := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		known := false
+		for _, p := range allowed {
+			known = known || m[1] == p
+		}
+		if !known {
+			return false
+		}
+	}
+	return true
+}
+
+// renderTemplate fills the placeholders of text from vars.
+func renderTemplate(text string, vars map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(m string) string {
+		return vars[m[1:len(m)-1]]
+	})
+}
+
+const templateColumns = "status, template, enabled, updated_by, created_at, updated_at"
+
+func scanTemplate(row pgx.Row, t *NotificationTemplate) error {
+	return row.Scan(&t.Status, &t.Template, &t.Enabled, &t.UpdatedBy, &t.CreatedAt, &t.UpdatedAt)
+}
+
+// ListNotificationTemplates returns the template of every status that has one.
+func (db *DB) ListNotificationTemplates(ctx context.Context) ([]NotificationTemplate, error) {
+	rows, err := db.pool.Query(ctx, `SELECT `+templateColumns+` FROM notification_templates ORDER BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []NotificationTemplate
+	for rows.Next() {
+		var t NotificationTemplate
+		if err := scanTemplate(rows, &t); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// SaveNotificationTemplate sets the template sent when an application reaches t.Status.
+func (db *DB) SaveNotificationTemplate(ctx context.Context, actor string, t NotificationTemplate) (NotificationTemplate, error) {
+	t.Template = strings.TrimSpace(t.Template)
+	if !t.Status.Valid() || t.Template == "" || !knownPlaceholders(t.Template, NotificationPlaceholders) {
+		return NotificationTemplate{}, ErrInvalidTemplate
+	}
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return NotificationTemplate{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return NotificationTemplate{}, err
+	}
+
+	var out NotificationTemplate
+	if err := scanTemplate(tx.QueryRow(ctx, `
+        INSERT INTO notification_templates (status, template, enabled, updated_by)
+        VALUES ($1, $2, $3, NULLIF($4, ''))
+        ON CONFLICT (status) DO UPDATE
+        SET template = EXCLUDED.template, enabled = EXCLUDED.enabled, updated_by = EXCLUDED.updated_by
+        RETURNING `+templateColumns+`
+    `, t.Status, t.Template, t.Enabled, actor), &out); err != nil {
+		return NotificationTemplate{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return NotificationTemplate{}, err
+	}
+	return out, nil
+}
+
+const notificationColumns = `n.id, n.event_id, n.application_id, n.user_id, u.discord_user_id, n.status, n.message,
+        n.state, n.via, n.attempts, n.last_error, n.sent_at, n.created_at, n.updated_at`
+
+func scanNotification(row pgx.Row, n *Notification) error {
+	return row.Scan(&n.ID, &n.EventID, &n.ApplicationID, &n.UserID, &n.DiscordUserID, &n.Status, &n.Message,
+		&n.State, &n.Via, &n.Attempts, &n.LastError, &n.SentAt, &n.CreatedAt, &n.UpdatedAt)
+}
+
+func getNotificationByEvent(ctx context.Context, q queryer, eventID int64) (*Notification, error) {
+	var n Notification
+	err := scanNotification(q.QueryRow(ctx, `
+        SELECT `+notificationColumns+`
+        FROM notifications n JOIN users u ON u.id = n.user_id
+        WHERE n.event_id = $1
+    `, eventID), &n)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// PrepareNotification renders the notification for an applications status
+// event and records it as pending. A redelivered event returns the row
+// recorded the first time, whatever its state. It returns nil when there is
+// nothing to send: not a status event, no enabled template for the status,
+// or the application is gone.
+func (db *DB) PrepareNotification(ctx context.Context, ev AppEvent) (*Notification, error) {
+	if ev.Table != "applications" || ev.Action == "DELETE" || ev.Status == nil {
+		return nil, nil
+	}
+	if n, err := getNotificationByEvent(ctx, db.pool, ev.ID); n != nil || err != nil {
+		return n, err
+	}
+
+	var userID, name string
+	var mcName, tmpl *string
+	var attempt int
+	var enabled bool
+	err := db.pool.QueryRow(ctx, `
+        SELECT a.user_id, a.attempt, u.discord_username, u.minecraft_name, t.template, COALESCE(t.enabled, false)
+        FROM applications a
+        JOIN users u ON u.id = a.user_id
+        LEFT JOIN notification_templates t ON t.status = $2
+        WHERE a.id = $1
+    `, ev.RowID, *ev.Status).Scan(&userID, &attempt, &name, &mcName, &tmpl, &enabled)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !enabled) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	vars := map[string]string{"name": name, "minecraft_name": name, "status": string(*ev.Status), "attempt": strconv.Itoa(attempt)}
+	if mcName != nil {
+		vars["minecraft_name"] = *mcName
+	}
+	switch *ev.Status {
+	case StatusDenied:
+		notice, err := db.GetDenialNotice(ctx, ev.RowID)
+		if err != nil || notice == nil {
+			return nil, err
+		}
+		vars["reason"] = notice.Message
+	case StatusBanned:
+		var reason string
+		if err := db.pool.QueryRow(ctx, `
+            SELECT reason FROM bans WHERE application_id = $1 ORDER BY issued_at DESC LIMIT 1
+        `, ev.RowID).Scan(&reason); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		vars["reason"] = reason
+	}
+
+	if _, err := db.pool.Exec(ctx, `
+        INSERT INTO notifications (event_id, application_id, user_id, status, message)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (event_id) DO NOTHING
+    `, ev.ID, ev.RowID, userID, *ev.Status, renderTemplate(*tmpl, vars)); err != nil {
+		return nil, err
+	}
+	return getNotificationByEvent(ctx, db.pool, ev.ID)
+}
+
+// RecordNotification stores the outcome of a delivery attempt. via is empty
+// when nothing reached the applicant; sendErr is kept as the last error even
+// when a fallback succeeded.
+func (db *DB) RecordNotification(ctx context.Context, id int64, state string, via string, sendErr error) error {
+	var lastError *string
+	if sendErr != nil {
+		msg := sendErr.Error()
+		lastError = &msg
+	}
+	_, err := db.pool.Exec(ctx, `
+        UPDATE notifications
+        SET state = $2, via = NULLIF($3, ''), attempts = attempts + 1, last_error = $4,
+            sent_at = CASE WHEN $2 IN ('sent', 'fallback') THEN now() END
+        WHERE id = $1
+    `, id, state, via, lastError)
+	return err
+}
+
+// ListNotifications returns notifications newest first, optionally for one
+// user and in one delivery state.
+func (db *DB) ListNotifications(ctx context.Context, userID string, state string, limit int, offset int) ([]Notification, error) {
+	where := "WHERE 1=1"
+	args := []any{}
+	if userID != "" {
+		args = append(args, userID)
+		where += " AND n.user_id = $" + strconv.Itoa(len(args))
+	}
+	if state != "" {
+		args = append(args, state)
+		where += " AND n.state = $" + strconv.Itoa(len(args))
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	args = append(args, limit, offset)
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+notificationColumns+`
+        FROM notifications n JOIN users u ON u.id = n.user_id
+        `+where+`
+        ORDER BY n.created_at DESC, n.id DESC
+        LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Notification
+	for rows.Next() {
+		var n Notification
+		if err := scanNotification(rows, &n); err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,326 @@
+package database_service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// RetryPolicy controls how often a failed event is redelivered to a consumer
+// before it is moved to the dead letters.
+type RetryPolicy struct {
+	MaxAttempts int
+	// Base is the delay after the first failure; it doubles per attempt up to Max
+	Base time.Duration
+	Max  time.Duration
+}
+
+// DefaultRetryPolicy gives up on the tenth failure, about 43 minutes after the
+// first: nine waits from 5s doubling to 1280s.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 10, Base: 5 * time.Second, Max: 30 * time.Minute}
+
+// RegisterEventConsumer creates a consumer cursor if it does not exist yet.
+// New consumers start at the current end of the outbox rather than replaying
+// its history.
+func (db *DB) RegisterEventConsumer(ctx context.Context, name string) error {
+	_, err := db.pool.Exec(ctx, `
+        INSERT INTO event_consumers (name, cursor)
+        VALUES ($1, COALESCE((SELECT max(id) FROM event_outbox), 0))
+        ON CONFLICT (name) DO NOTHING
+    `, name)
+	return err
+}
+
+// FetchEvents returns up to limit events for consumer: retries that are due
+// first, then events past its cursor, each group in outbox order. Events are
+// redelivered until they are acked or nacked, so handlers must be idempotent.
+func (db *DB) FetchEvents(ctx context.Context, consumer string, limit int) ([]OutboxEvent, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if err := db.sequenceOutbox(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := db.pool.Query(ctx, `
+        (SELECT e.id, e.payload, e.created_at, r.attempts
+         FROM event_retries r JOIN event_outbox e ON e.id = r.event_id
+         WHERE r.consumer = $1 AND r.dead_at IS NULL AND r.next_attempt_at <= now()
+         ORDER BY e.id LIMIT $2)
+        UNION ALL
+        (SELECT e.id, e.payload, e.created_at, 0
+         FROM event_outbox e JOIN event_consumers c ON e.id > c.cursor
+         WHERE c.name = $1
+         ORDER BY e.id LIMIT $2)
+    `, consumer, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		if err := rows.Scan(&e.ID, &e.Payload, &e.CreatedAt, &e.Attempts); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// ListOutboxEvents reads up to limit events after afterID in outbox order,
+// without any consumer bookkeeping. It serves readers that track their own
+// position, such as live streams replaying from a client's last seen id.
+func (db *DB) ListOutboxEvents(ctx context.Context, afterID int64, limit int) ([]OutboxEvent, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if err := db.sequenceOutbox(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := db.pool.Query(ctx, `
+        SELECT id, payload, created_at, 0 FROM event_outbox
+        WHERE id > $1 ORDER BY id LIMIT $2
+    `, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		if err := rows.Scan(&e.ID, &e.Payload, &e.CreatedAt, &e.Attempts); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// LatestOutboxID returns the id of the newest outbox event, or 0 when empty.
+func (db *DB) LatestOutboxID(ctx context.Context) (int64, error) {
+	var id int64
+	err := db.pool.QueryRow(ctx, `SELECT COALESCE(max(id), 0) FROM event_outbox`).Scan(&id)
+	return id, err
+}
+
+// sequenceOutbox moves events of finished transactions from the staging table
+// into event_outbox, so ids are handed out in commit order and a cursor never
+// passes an event that commits later.
+func (db *DB) sequenceOutbox(ctx context.Context) error {
+	_, err := db.pool.Exec(ctx, `SELECT sequence_event_outbox()`)
+	return err
+}
+
+// Decode parses the event payload, filling in its outbox id.
+func (e OutboxEvent) Decode() (AppEvent, error) {
+	var ev AppEvent
+	if err := json.Unmarshal(e.Payload, &ev); err != nil {
+		return AppEvent{}, fmt.Errorf("decode outbox event %d: %w", e.ID, err)
+	}
+	ev.ID = e.ID
+	return ev, nil
+}
+
+// AckEvent marks an event as handled by consumer. Events past the cursor must
+// be acked (or nacked) in outbox order, as FetchEvents returns them.
+func (db *DB) AckEvent(ctx context.Context, consumer string, eventID int64) error {
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, `DELETE FROM event_retries WHERE consumer = $1 AND event_id = $2`, consumer, eventID); err != nil {
+		return err
+	}
+	if err := advanceCursorTx(ctx, tx, consumer, eventID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// NackEvent records a failed delivery and schedules a retry with exponential
+// backoff. It reports whether the event was dead-lettered instead. Passing a
+// zero policy dead-letters immediately, for events that can never succeed.
+func (db *DB) NackEvent(ctx context.Context, consumer string, eventID int64, cause error, policy RetryPolicy) (bool, error) {
+	msg := "unknown error"
+	if cause != nil {
+		msg = cause.Error()
+	}
+
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var attempts int
+	if err := tx.QueryRow(ctx, `
+        INSERT INTO event_retries (consumer, event_id, attempts, last_error)
+        VALUES ($1, $2, 1, $3)
+        ON CONFLICT (consumer, event_id) DO UPDATE
+        SET attempts = event_retries.attempts + 1, last_error = EXCLUDED.last_error
+        RETURNING attempts
+    `, consumer, eventID, msg).Scan(&attempts); err != nil {
+		return false, err
+	}
+
+	dead := attempts >= policy.MaxAttempts
+	if dead {
+		_, err = tx.Exec(ctx, `UPDATE event_retries SET dead_at = now() WHERE consumer = $1 AND event_id = $2`, consumer, eventID)
+	} else {
+		_, err = tx.Exec(ctx, `
+            UPDATE event_retries SET next_attempt_at = now() + make_interval(secs => $3)
+            WHERE consumer = $1 AND event_id = $2
+        `, consumer, eventID, policy.Backoff(attempts).Seconds())
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := advanceCursorTx(ctx, tx, consumer, eventID); err != nil {
+		return false, err
+	}
+	return dead, tx.Commit(ctx)
+}
+
+// Backoff is the delay before the next try after attempts failures.
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	d := p.Base
+	for i := 1; i < attempts && d < p.Max; i++ {
+		d *= 2
+	}
+	if p.Max > 0 && d > p.Max {
+		d = p.Max
+	}
+	return d
+}
+
+func advanceCursorTx(ctx context.Context, tx pgx.Tx, consumer string, eventID int64) error {
+	_, err := tx.Exec(ctx, `
+        UPDATE event_consumers SET cursor = GREATEST(cursor, $2), updated_at = now()
+        WHERE name = $1
+    `, consumer, eventID)
+	return err
+}
+
+// DrainEvents hands every due event of consumer to handle until none are left,
+// acking successes and nacking failures under policy. Payloads that do not
+// decode are dead-lettered straight away. Handler errors are not returned;
+// the returned error is about the outbox itself.
+func (db *DB) DrainEvents(ctx context.Context, consumer string, policy RetryPolicy, handle func(ctx context.Context, ev AppEvent) error) error {
+	if err := db.RegisterEventConsumer(ctx, consumer); err != nil {
+		return err
+	}
+	for {
+		batch, err := db.FetchEvents(ctx, consumer, 100)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		for _, e := range batch {
+			ev, err := e.Decode()
+			if err != nil {
+				if _, err := db.NackEvent(ctx, consumer, e.ID, err, RetryPolicy{}); err != nil {
+					return err
+				}
+				continue
+			}
+			if herr := handle(ctx, ev); herr != nil {
+				if ctx.Err() != nil {
+					// shutting down; leave the event for the next run
+					return ctx.Err()
+				}
+				if _, err := db.NackEvent(ctx, consumer, e.ID, herr, policy); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := db.AckEvent(ctx, consumer, e.ID); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ListDeadLetters returns events consumers gave up on, newest first. An empty
+// consumer lists all of them.
+func (db *DB) ListDeadLetters(ctx context.Context, consumer string, limit int, offset int) ([]DeadLetter, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	rows, err := db.pool.Query(ctx, `
+        SELECT consumer, event_id, attempts, last_error, dead_at, payload, created_at
+        FROM event_dead_letters
+        WHERE $1 = '' OR consumer = $1
+        ORDER BY dead_at DESC
+        LIMIT $2 OFFSET $3
+    `, consumer, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []DeadLetter
+	for rows.Next() {
+		var d DeadLetter
+		if err := rows.Scan(&d.Consumer, &d.EventID, &d.Attempts, &d.LastError, &d.DeadAt, &d.Payload, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// RetryDeadLetter puts a dead-lettered event back in the consumer's queue with
+// a fresh attempt budget.
+func (db *DB) RetryDeadLetter(ctx context.Context, consumer string, eventID int64) error {
+	tag, err := db.pool.Exec(ctx, `
+        UPDATE event_retries SET dead_at = NULL, attempts = 0, next_attempt_at = now()
+        WHERE consumer = $1 AND event_id = $2 AND dead_at IS NOT NULL
+    `, consumer, eventID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+// PruneEventOutbox deletes events older than keep that every active consumer
+// has moved past. Events with pending retries or dead letters are kept.
+// Consumers idle for longer than keep no longer hold events back.
+func (db *DB) PruneEventOutbox(ctx context.Context, keep time.Duration) (int64, error) {
+	tag, err := db.pool.Exec(ctx, `
+        DELETE FROM event_outbox e
+        WHERE e.created_at < now() - make_interval(secs => $1)
+          AND e.id <= COALESCE((
+              SELECT min(cursor) FROM event_consumers
+              WHERE updated_at > now() - make_interval(secs => $1)
+          ), (SELECT max(id) FROM event_outbox))
+          AND NOT EXISTS (SELECT 1 FROM event_retries r WHERE r.event_id = e.id)
+    `, keep.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -2,11 +2,27 @@ package database_service
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"strconv"
 	"time"
+
+	"github.com/jackc/pgx/v5"
 )
 
+// ApplicationSort orders FindApplications results.
+type ApplicationSort string
+
+const (
+	SortNewest    ApplicationSort = "" // created_at descending
+	SortScoreDesc ApplicationSort = "score_desc"
+	SortScoreAsc  ApplicationSort = "score_asc"
+)
+
+// Valid reports whether s is a known sort order.
+func (s ApplicationSort) Valid() bool {
+	return s == SortNewest || s == SortScoreDesc || s == SortScoreAsc
+}
+
 // Simple filters matching the diagram needs without overengineering
 type ApplicationFilter struct {
 	// Optional
@@ -15,31 +31,51 @@ type ApplicationFilter struct {
 	CreatedAfter  *time.Time
 	CreatedBefore *time.Time
 	StatusEquals  *Status
+	// MinScore/MaxScore bound the average reviewer score (0-100); unscored
+	// applications never match either bound
+	MinScore *float64
+	MaxScore *float64
+	Sort     ApplicationSort
+	// AllAttempts includes attempts superseded by a re-application; by
+	// default only each user's current attempt is listed
+	AllAttempts bool
 }
 
-// FindApplications returns applications filtered by basic fields.
+// FindApplications returns applications filtered by basic fields, each with
+// its score summary when it has been scored.
 // This keeps the query intentionally straightforward and readable.
 func (db *DB) FindApplications(ctx context.Context, f ApplicationFilter, limit int, offset int) ([]Application, error) {
 	// Build WHERE clause in a very explicit way
 	where := "WHERE 1=1"
 	args := []any{}
 
+	if !f.AllAttempts {
+		where += " AND a.superseded_at IS NULL"
+	}
 	if f.StatusEquals != nil {
 		args = append(args, *f.StatusEquals)
-		where += " AND status = $" + strconv.Itoa(len(args))
+		where += " AND a.status = $" + strconv.Itoa(len(args))
 	}
 	if f.CreatedAfter != nil {
 		args = append(args, *f.CreatedAfter)
-		where += " AND created_at >= $" + strconv.Itoa(len(args))
+		where += " AND a.created_at >= $" + strconv.Itoa(len(args))
 	}
 	if f.CreatedBefore != nil {
 		args = append(args, *f.CreatedBefore)
-		where += " AND created_at <= $" + strconv.Itoa(len(args))
+		where += " AND a.created_at <= $" + strconv.Itoa(len(args))
+	}
+	if f.MinScore != nil {
+		args = append(args, *f.MinScore)
+		where += " AND s.score >= $" + strconv.Itoa(len(args))
+	}
+	if f.MaxScore != nil {
+		args = append(args, *f.MaxScore)
+		where += " AND s.score <= $" + strconv.Itoa(len(args))
 	}
 	// Age filter requires join with users
-	join := ""
+	join := " LEFT JOIN application_score_summaries s ON s.application_id = a.id"
 	if f.MinAge != nil || f.MaxAge != nil {
-		join = " JOIN users u ON u.id = a.user_id"
+		join += " JOIN users u ON u.id = a.user_id"
 		if f.MinAge != nil {
 			args = append(args, *f.MinAge)
 			where += " AND u.age >= $" + strconv.Itoa(len(args))
@@ -50,6 +86,14 @@ func (db *DB) FindApplications(ctx context.Context, f ApplicationFilter, limit i
 		}
 	}
 
+	order := "a.created_at DESC"
+	switch f.Sort {
+	case SortScoreDesc:
+		order = "s.score DESC NULLS LAST, a.created_at DESC"
+	case SortScoreAsc:
+		order = "s.score ASC NULLS LAST, a.created_at DESC"
+	}
+
 	if limit <= 0 {
 		limit = 100
 	}
@@ -57,7 +101,7 @@ func (db *DB) FindApplications(ctx context.Context, f ApplicationFilter, limit i
 		offset = 0
 	}
 
-	sql := "SELECT a.id, a.user_id, a.answers, a.status, a.created_at, a.updated_at FROM applications a" + join + " " + where + " ORDER BY a.created_at DESC LIMIT $" + strconv.Itoa(len(args)+1) + " OFFSET $" + strconv.Itoa(len(args)+2)
+	sql := "SELECT " + qualify("a", applicationColumns) + ", s.score, s.score_count, s.spread, s.updated_at FROM applications a" + join + " " + where + " ORDER BY " + order + " LIMIT $" + strconv.Itoa(len(args)+1) + " OFFSET $" + strconv.Itoa(len(args)+2)
 	args = append(args, limit, offset)
 
 	rows, err := db.pool.Query(ctx, sql, args...)
@@ -69,14 +113,83 @@ func (db *DB) FindApplications(ctx context.Context, f ApplicationFilter, limit i
 	var out []Application
 	for rows.Next() {
 		var a Application
-		var raw []byte
-		if err := rows.Scan(&a.ID, &a.UserID, &raw, &a.Status, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		var score, spread *float64
+		var count *int
+		var scoredAt *time.Time
+		if err := scanApplication(rows, &a, &score, &count, &spread, &scoredAt); err != nil {
 			return nil, err
 		}
-		if err := json.Unmarshal(raw, &a.Answers); err != nil {
-			return nil, err
+		if score != nil {
+			a.Score = &ScoreSummary{Score: *score, Count: *count, Spread: *spread, UpdatedAt: *scoredAt}
 		}
 		out = append(out, a)
 	}
 	return out, rows.Err()
 }
+
+// GetApplication returns an application by id, or nil if it does not exist.
+func (db *DB) GetApplication(ctx context.Context, applicationID string) (*Application, error) {
+	row := db.pool.QueryRow(ctx, `
+        SELECT `+applicationColumns+`
+        FROM applications WHERE id = $1
+    `, applicationID)
+	var a Application
+	if err := scanApplication(row, &a); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetApplicationDetail returns an application joined with its user and status history.
+func (db *DB) GetApplicationDetail(ctx context.Context, applicationID string) (*ApplicationDetail, error) {
+	row := db.pool.QueryRow(ctx, `
+        SELECT `+qualify("a", applicationColumns)+`, `+qualify("u", userColumns)+`
+        FROM applications a JOIN users u ON u.id = a.user_id
+        WHERE a.id = $1
+    `, applicationID)
+	var d ApplicationDetail
+	u := &d.User
+	if err := scanApplication(row, &d.Application,
+		&u.ID, &u.DiscordUserID, &u.DiscordUsername, &u.MinecraftName, &u.MinecraftUUID, &u.Age, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	history, err := db.ListStatusHistory(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	d.History = history
+	if d.Score, err = db.GetScoreSummary(ctx, applicationID); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// ListUserStatuses returns every user with their current application status (nil when
+// they never applied). Sync workers use it to reconcile external systems.
+func (db *DB) ListUserStatuses(ctx context.Context) ([]UserStatus, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT u.id, u.discord_user_id, u.minecraft_name, u.minecraft_uuid, a.status
+        FROM users u LEFT JOIN applications a ON a.user_id = u.id AND a.superseded_at IS NULL
+        ORDER BY u.created_at
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []UserStatus
+	for rows.Next() {
+		var s UserStatus
+		if err := rows.Scan(&s.UserID, &s.DiscordUserID, &s.MinecraftName, &s.MinecraftUUID, &s.Status); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,154 @@
+package database_service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrRejectionReasonNotFound = errors.New("rejection reason not found or inactive")
+	ErrInvalidRejectionReason  = errors.New("rejection reason needs a code (a-z, 0-9, _), a label and a message using only known placeholders")
+	ErrReasonCodeNotDenial     = errors.New("a rejection reason only applies when denying")
+)
+
+// RejectionPlaceholders are the {placeholders} a rejection message may use.
+var RejectionPlaceholders = []string{"name", "minecraft_name", "attempt"}
+
+// defaultDenialMessage is sent when a denial did not pick a rejection reason.
+const defaultDenialMessage = "Hi {name}, thanks for applying to TYSMP. Unfortunately we could not accept your application this time."
+
+var rejectionCodePattern = regexp.MustCompile(`^[a-z0-9_]{1,40}$`)
+
+// Validate checks a reason before it is saved.
+func (r RejectionReason) Validate() error {
+	if !rejectionCodePattern.MatchString(r.Code) || strings.TrimSpace(r.Label) == "" || strings.TrimSpace(r.Message) == "" ||
+		!knownPlaceholders(r.Message, RejectionPlaceholders) {
+		return ErrInvalidRejectionReason
+	}
+	return nil
+}
+
+const rejectionColumns = "code, label, message, active, created_by, created_at, updated_at"
+
+func scanRejectionReason(row pgx.Row, r *RejectionReason) error {
+	return row.Scan(&r.Code, &r.Label, &r.Message, &r.Active, &r.CreatedBy, &r.CreatedAt, &r.UpdatedAt)
+}
+
+func getRejectionReason(ctx context.Context, q queryer, code string) (*RejectionReason, error) {
+	var r RejectionReason
+	err := scanRejectionReason(q.QueryRow(ctx, `SELECT `+rejectionColumns+` FROM rejection_reasons WHERE code = $1`, code), &r)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListRejectionReasons returns the catalogue ordered by code, leaving out
+// retired reasons unless includeInactive is set.
+func (db *DB) ListRejectionReasons(ctx context.Context, includeInactive bool) ([]RejectionReason, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+rejectionColumns+`
+        FROM rejection_reasons
+        WHERE active OR $1
+        ORDER BY code
+    `, includeInactive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []RejectionReason
+	for rows.Next() {
+		var r RejectionReason
+		if err := scanRejectionReason(rows, &r); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// SaveRejectionReason creates a reason or replaces the label, message and
+// active flag of an existing one. Reasons are retired rather than deleted so
+// past denials keep pointing at them.
+func (db *DB) SaveRejectionReason(ctx context.Context, actor string, r RejectionReason) (RejectionReason, error) {
+	if err := r.Validate(); err != nil {
+		return RejectionReason{}, err
+	}
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return RejectionReason{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return RejectionReason{}, err
+	}
+
+	var out RejectionReason
+	if err := scanRejectionReason(tx.QueryRow(ctx, `
+        INSERT INTO rejection_reasons (code, label, message, active, created_by)
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+        ON CONFLICT (code) DO UPDATE
+        SET label = EXCLUDED.label, message = EXCLUDED.message, active = EXCLUDED.active
+        RETURNING `+rejectionColumns+`
+    `, r.Code, strings.TrimSpace(r.Label), strings.TrimSpace(r.Message), r.Active, actor), &out); err != nil {
+		return RejectionReason{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return RejectionReason{}, err
+	}
+	return out, nil
+}
+
+// GetDenialNotice renders the message for a denied application from the
+// reason picked when it was denied. It returns nil when the application is
+// not (or no longer) denied, and for denials carried over from the time they
+// were recorded as bans, which the applicant was already told about.
+func (db *DB) GetDenialNotice(ctx context.Context, applicationID string) (*DenialNotice, error) {
+	var n DenialNotice
+	var name string
+	var mcName *string
+	var attempt int
+	var message *string
+	var from *Status
+	err := db.pool.QueryRow(ctx, `
+        SELECT a.id, u.discord_user_id, u.discord_username, u.minecraft_name, a.attempt, h.from_status, h.reason_code, r.message
+        FROM applications a
+        JOIN users u ON u.id = a.user_id
+        LEFT JOIN LATERAL (
+            SELECT from_status, reason_code FROM application_status_history
+            WHERE application_id = a.id AND to_status = 'denied'
+            ORDER BY created_at DESC, id DESC LIMIT 1
+        ) h ON true
+        LEFT JOIN rejection_reasons r ON r.code = h.reason_code
+        WHERE a.id = $1 AND a.status = 'denied'
+    `, applicationID).Scan(&n.ApplicationID, &n.DiscordUserID, &name, &mcName, &attempt, &from, &n.ReasonCode, &message)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if from != nil && *from == StatusBanned {
+		return nil, nil
+	}
+
+	vars := map[string]string{"name": name, "minecraft_name": name, "attempt": strconv.Itoa(attempt)}
+	if mcName != nil {
+		vars["minecraft_name"] = *mcName
+	}
+	tmpl := defaultDenialMessage
+	if message != nil {
+		tmpl = *message
+	}
+	n.Message = renderTemplate(tmpl, vars)
+	return &n, nil
+}
@@ -0,0 +1,273 @@
+package database_service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrRestoreTarget     = errors.New("restore needs a table, row id and either an audit id or a timestamp")
+	ErrNoRestorePoint    = errors.New("no audit snapshot for that row at the requested point")
+	ErrNothingToRestore  = errors.New("row already matches the snapshot")
+	ErrRestoreRowDeleted = errors.New("the row was deleted at the requested point")
+	ErrRestoreConflict   = errors.New("restored values conflict with another row")
+	ErrRestoreBanned     = errors.New("bans are issued and lifted through the bans endpoints, not restored")
+)
+
+// restorableColumns are the columns a restore may write back on an existing
+// row. Identity columns (id, discord_user_id, user_id) and timestamps are
+// left alone; they are only written when a deleted row is re-created.
+var restorableColumns = map[string][]string{
+	AuditTableUsers:        {"discord_username", "minecraft_name", "minecraft_uuid", "age"},
+	AuditTableApplications: {"answers", "status", "form_version"},
+}
+
+// RestoreRequest selects the row to rebuild and the point to rebuild it from:
+// the state right after audit entry AuditID, or the last state at or before At.
+type RestoreRequest struct {
+	Table   string     `json:"table"`
+	RowID   string     `json:"row_id"`
+	AuditID *int64     `json:"audit_id,omitempty"`
+	At      *time.Time `json:"at,omitempty"`
+	Reason  string     `json:"reason"`
+}
+
+// RestorePlan is the dry-run result of a restore: the snapshot that would be
+// written, the row as it is now (nil when deleted) and the resulting diff.
+type RestorePlan struct {
+	Table    string         `json:"table"`
+	RowID    string         `json:"row_id"`
+	AuditID  int64          `json:"audit_id"`
+	Snapshot map[string]any `json:"snapshot"`
+	Current  map[string]any `json:"current"`
+	Recreate bool           `json:"recreate"`
+	Changes  []FieldChange  `json:"changes"`
+}
+
+// queryer is satisfied by both the pool and a transaction.
+type queryer interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// PlanRestore computes what RestoreRow would change without writing anything.
+func (db *DB) PlanRestore(ctx context.Context, req RestoreRequest) (RestorePlan, error) {
+	return planRestore(ctx, db.pool, req, false)
+}
+
+func planRestore(ctx context.Context, q queryer, req RestoreRequest, lock bool) (RestorePlan, error) {
+	cols, ok := restorableColumns[req.Table]
+	if !ok || req.RowID == "" || (req.AuditID == nil) == (req.At == nil) {
+		return RestorePlan{}, ErrRestoreTarget
+	}
+
+	plan := RestorePlan{Table: req.Table, RowID: req.RowID}
+	var action string
+	sql := `SELECT id, action, after_data FROM audit_log WHERE table_name = $1 AND row_id = $2`
+	args := []any{req.Table, req.RowID}
+	if req.AuditID != nil {
+		sql += ` AND id = $3`
+		args = append(args, *req.AuditID)
+	} else {
+		sql += ` AND created_at <= $3 ORDER BY created_at DESC, id DESC LIMIT 1`
+		args = append(args, *req.At)
+	}
+	if err := q.QueryRow(ctx, sql, args...).Scan(&plan.AuditID, &action, &plan.Snapshot); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return RestorePlan{}, ErrNoRestorePoint
+		}
+		return RestorePlan{}, err
+	}
+	if action == "DELETE" || plan.Snapshot == nil {
+		return RestorePlan{}, ErrRestoreRowDeleted
+	}
+
+	// the table name comes from restorableColumns, never from the caller
+	current := `SELECT to_jsonb(t) FROM ` + req.Table + ` t WHERE id = $1`
+	if lock {
+		current += ` FOR UPDATE`
+	}
+	if err := q.QueryRow(ctx, current, req.RowID).Scan(&plan.Current); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return RestorePlan{}, err
+	}
+
+	if plan.Current == nil {
+		plan.Recreate = true
+		plan.Changes = DiffAudit(nil, plan.Snapshot)
+		if err := checkRestoredStatus(plan); err != nil {
+			return RestorePlan{}, err
+		}
+		return plan, nil
+	}
+	if req.Table == AuditTableApplications && plan.Current["superseded_at"] != nil {
+		// older attempts are frozen; restoring onto one would only trip the trigger
+		return RestorePlan{}, ErrApplicationSuperseded
+	}
+	// only compare what a restore would actually write back
+	before, after := map[string]any{}, map[string]any{}
+	for _, c := range cols {
+		if v, ok := plan.Snapshot[c]; ok {
+			before[c], after[c] = plan.Current[c], v
+		}
+	}
+	plan.Changes = DiffAudit(before, after)
+	if plan.Changes == nil {
+		plan.Changes = []FieldChange{}
+	}
+	if err := checkRestoredStatus(plan); err != nil {
+		return RestorePlan{}, err
+	}
+	return plan, nil
+}
+
+// checkRestoredStatus holds a restored application status to the lifecycle.
+// Moving into or out of banned is refused outright: a ban needs its bans row
+// and enforcement, which only IssueBan and LiftBan maintain.
+func checkRestoredStatus(plan RestorePlan) error {
+	if plan.Table != AuditTableApplications {
+		return nil
+	}
+	s, _ := plan.Snapshot["status"].(string)
+	to := Status(s)
+	if plan.Recreate {
+		if to == StatusBanned {
+			return ErrRestoreBanned
+		}
+		if !to.Valid() {
+			return &TransitionError{To: to}
+		}
+		return nil
+	}
+	s, _ = plan.Current["status"].(string)
+	from := Status(s)
+	if to == from {
+		return nil
+	}
+	if from == StatusBanned || to == StatusBanned {
+		return ErrRestoreBanned
+	}
+	return checkTransition(from, to, false)
+}
+
+// RestoreRow rebuilds a users or applications row from the audit log inside one
+// transaction. The plan is recomputed under a row lock so it reflects exactly
+// what was written. Status changes are recorded in application_status_history
+// with reviewer as the reviewer and must be moves checkTransition allows.
+func (db *DB) RestoreRow(ctx context.Context, actor string, reviewer string, req RestoreRequest) (RestorePlan, error) {
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return RestorePlan{}, ErrReasonRequired
+	}
+	if strings.TrimSpace(reviewer) == "" {
+		return RestorePlan{}, ErrReviewerRequired
+	}
+
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return RestorePlan{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return RestorePlan{}, err
+	}
+
+	plan, err := planRestore(ctx, tx, req, true)
+	if err != nil {
+		return RestorePlan{}, err
+	}
+	if len(plan.Changes) == 0 {
+		return plan, ErrNothingToRestore
+	}
+
+	if plan.Recreate {
+		snapshot := `$1::jsonb`
+		if req.Table == AuditTableApplications {
+			// snapshots from before attempts were kept have no attempt number
+			snapshot += ` || jsonb_build_object('attempt', COALESCE(($1::jsonb->>'attempt')::int,
+                (SELECT COALESCE(max(attempt), 0) + 1 FROM applications WHERE user_id = ($1::jsonb->>'user_id')::uuid)))`
+		}
+		// jsonb_populate_record casts every column back to its real type
+		if _, err := tx.Exec(ctx, `
+            INSERT INTO `+req.Table+`
+            SELECT * FROM jsonb_populate_record(NULL::`+req.Table+`, `+snapshot+`)
+        `, plan.Snapshot); err != nil {
+			if isUniqueViolation(err) {
+				return RestorePlan{}, ErrRestoreConflict
+			}
+			return RestorePlan{}, err
+		}
+	} else {
+		var cols []string
+		for _, c := range plan.Changes {
+			cols = append(cols, c.Field)
+		}
+		list := strings.Join(cols, ", ")
+		if _, err := tx.Exec(ctx, `
+            UPDATE `+req.Table+` SET (`+list+`) = (
+                SELECT `+list+` FROM jsonb_populate_record(NULL::`+req.Table+`, $2)
+            ) WHERE id = $1
+        `, req.RowID, plan.Snapshot); err != nil {
+			if isUniqueViolation(err) {
+				return RestorePlan{}, ErrRestoreConflict
+			}
+			return RestorePlan{}, err
+		}
+	}
+
+	switch req.Table {
+	case AuditTableApplications:
+		if err := recordRestoredStatus(ctx, tx, actor, reviewer, plan, req.Reason); err != nil {
+			return RestorePlan{}, err
+		}
+	case AuditTableUsers:
+		// the same record SetMinecraftName keeps
+		if err := recordNameChange(ctx, tx, actor, plan.RowID, restoredString(plan, "minecraft_uuid"),
+			stringField(plan.Current, "minecraft_name"), restoredString(plan, "minecraft_name")); err != nil {
+			return RestorePlan{}, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return RestorePlan{}, err
+	}
+	return plan, nil
+}
+
+// recordRestoredStatus keeps application_status_history complete when a
+// restore changes the status.
+func recordRestoredStatus(ctx context.Context, tx pgx.Tx, actor, reviewer string, plan RestorePlan, reason string) error {
+	to, _ := plan.Snapshot["status"].(string)
+	from, _ := plan.Current["status"].(string)
+	if to == "" || to == from {
+		return nil
+	}
+	if from == "" {
+		// a re-created application has no previous status of its own
+		from = to
+	}
+	_, err := tx.Exec(ctx, `
+        INSERT INTO application_status_history (application_id, from_status, to_status, reason, reviewer, actor)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+    `, plan.RowID, from, to, "restored from audit entry #"+strconv.FormatInt(plan.AuditID, 10)+": "+reason, reviewer, actor)
+	return err
+}
+
+// restoredString is a text column of the row as the restore leaves it: the
+// snapshot's value where the snapshot has the column, else the current one.
+func restoredString(plan RestorePlan, column string) *string {
+	if _, ok := plan.Snapshot[column]; ok {
+		return stringField(plan.Snapshot, column)
+	}
+	return stringField(plan.Current, column)
+}
+
+func stringField(row map[string]any, column string) *string {
+	if s, ok := row[column].(string); ok {
+		return &s
+	}
+	return nil
+}
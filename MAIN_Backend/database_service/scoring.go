@@ -0,0 +1,341 @@
+package database_service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrNoActiveRubric = errors.New("no active scoring rubric")
+
+// ScoreErrors maps criterion ids to what is wrong with the submitted score.
+type ScoreErrors map[string]string
+
+func (e ScoreErrors) Error() string {
+	ids := make([]string, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, id+": "+e[id])
+	}
+	return "invalid scores: " + strings.Join(parts, "; ")
+}
+
+// Validate checks a rubric is usable before it is published.
+func (d RubricDefinition) Validate() error {
+	if len(d.Criteria) == 0 {
+		return errors.New("rubric needs at least one criterion")
+	}
+	seen := map[string]bool{}
+	for _, c := range d.Criteria {
+		if strings.TrimSpace(c.ID) == "" || strings.TrimSpace(c.Label) == "" {
+			return errors.New("every criterion needs an id and label")
+		}
+		if seen[c.ID] {
+			return fmt.Errorf("duplicate criterion id %q", c.ID)
+		}
+		seen[c.ID] = true
+		if c.Weight <= 0 || math.IsInf(c.Weight, 0) || math.IsNaN(c.Weight) {
+			return fmt.Errorf("criterion %q needs a positive weight", c.ID)
+		}
+		if c.Max <= c.Min {
+			return fmt.Errorf("criterion %q needs max > min", c.ID)
+		}
+	}
+	return nil
+}
+
+// Total checks scores against the rubric and returns the weighted total,
+// normalised to 0-100. Every criterion must be scored.
+func (d RubricDefinition) Total(scores map[string]int) (float64, error) {
+	errs := ScoreErrors{}
+	var sum, weights float64
+	for _, c := range d.Criteria {
+		v, ok := scores[c.ID]
+		switch {
+		case !ok:
+			errs[c.ID] = "required"
+		case v < c.Min || v > c.Max:
+			errs[c.ID] = fmt.Sprintf("must be between %d and %d", c.Min, c.Max)
+		default:
+			sum += c.Weight * float64(v-c.Min) / float64(c.Max-c.Min)
+			weights += c.Weight
+		}
+	}
+	for id := range scores {
+		if _, ok := errs[id]; !ok && !d.hasCriterion(id) {
+			errs[id] = "not part of the rubric"
+		}
+	}
+	if len(errs) > 0 {
+		return 0, errs
+	}
+	return math.Round(sum/weights*10000) / 100, nil
+}
+
+func (d RubricDefinition) hasCriterion(id string) bool {
+	for _, c := range d.Criteria {
+		if c.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// scanRubric scans version, definition, active, created_by, created_at.
+func scanRubric(row pgx.Row) (Rubric, error) {
+	var r Rubric
+	var raw []byte
+	if err := row.Scan(&r.Version, &raw, &r.Active, &r.CreatedBy, &r.CreatedAt); err != nil {
+		return Rubric{}, err
+	}
+	if err := json.Unmarshal(raw, &r.Definition); err != nil {
+		return Rubric{}, err
+	}
+	return r, nil
+}
+
+// GetActiveRubric returns the rubric new scores are given against.
+func (db *DB) GetActiveRubric(ctx context.Context) (Rubric, error) {
+	return activeRubricTx(ctx, db.pool)
+}
+
+func activeRubricTx(ctx context.Context, q queryer) (Rubric, error) {
+	r, err := scanRubric(q.QueryRow(ctx, `
+        SELECT version, definition, active, created_by, created_at
+        FROM scoring_rubrics WHERE active
+    `))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Rubric{}, ErrNoActiveRubric
+	}
+	return r, err
+}
+
+// ListRubrics returns every rubric version, newest first.
+func (db *DB) ListRubrics(ctx context.Context) ([]Rubric, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT version, definition, active, created_by, created_at
+        FROM scoring_rubrics ORDER BY version DESC
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Rubric
+	for rows.Next() {
+		r, err := scanRubric(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// PublishRubric stores a new rubric version and makes it the active one.
+// Scores already given keep pointing at the version they were given against.
+func (db *DB) PublishRubric(ctx context.Context, actor string, def RubricDefinition) (Rubric, error) {
+	if err := def.Validate(); err != nil {
+		return Rubric{}, err
+	}
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Rubric{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return Rubric{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE scoring_rubrics SET active = false WHERE active`); err != nil {
+		return Rubric{}, err
+	}
+	r, err := scanRubric(tx.QueryRow(ctx, `
+        INSERT INTO scoring_rubrics (definition, active, created_by)
+        VALUES ($1, true, NULLIF($2, ''))
+        RETURNING version, definition, active, created_by, created_at
+    `, def, actor))
+	if err != nil {
+		return Rubric{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Rubric{}, err
+	}
+	return r, nil
+}
+
+const scoreColumns = "id, application_id, rubric_version, reviewer, scores, total, deviation, comment, created_at, updated_at"
+
+func scanScore(row pgx.Row, s *ApplicationScore) error {
+	var raw []byte
+	if err := row.Scan(&s.ID, &s.ApplicationID, &s.RubricVersion, &s.Reviewer, &raw, &s.Total, &s.Deviation, &s.Comment, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, &s.Scores)
+}
+
+// ScoreApplication records (or replaces) reviewer's score of an application
+// against the active rubric, then refreshes the application's aggregate and
+// every reviewer's deviation from it.
+func (db *DB) ScoreApplication(ctx context.Context, actor string, applicationID string, reviewer string, scores map[string]int, comment string) (ApplicationScore, ScoreSummary, error) {
+	reviewer = strings.TrimSpace(reviewer)
+	if reviewer == "" {
+		return ApplicationScore{}, ScoreSummary{}, ErrReviewerRequired
+	}
+
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return ApplicationScore{}, ScoreSummary{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return ApplicationScore{}, ScoreSummary{}, err
+	}
+
+	// lock the application so concurrent scores aggregate one at a time
+	var superseded bool
+	if err := tx.QueryRow(ctx, `SELECT superseded_at IS NOT NULL FROM applications WHERE id = $1 FOR UPDATE`, applicationID).Scan(&superseded); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ApplicationScore{}, ScoreSummary{}, ErrApplicationNotFound
+		}
+		return ApplicationScore{}, ScoreSummary{}, err
+	}
+	if superseded {
+		return ApplicationScore{}, ScoreSummary{}, ErrApplicationSuperseded
+	}
+	rubric, err := activeRubricTx(ctx, tx)
+	if err != nil {
+		return ApplicationScore{}, ScoreSummary{}, err
+	}
+	total, err := rubric.Definition.Total(scores)
+	if err != nil {
+		return ApplicationScore{}, ScoreSummary{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+        INSERT INTO application_scores (application_id, rubric_version, reviewer, scores, total, comment)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+        ON CONFLICT (application_id, reviewer) DO UPDATE
+        SET rubric_version = EXCLUDED.rubric_version, scores = EXCLUDED.scores,
+            total = EXCLUDED.total, comment = EXCLUDED.comment
+    `, applicationID, rubric.Version, reviewer, scores, total, strings.TrimSpace(comment)); err != nil {
+		return ApplicationScore{}, ScoreSummary{}, err
+	}
+	summary, err := refreshScoreSummaryTx(ctx, tx, applicationID)
+	if err != nil {
+		return ApplicationScore{}, ScoreSummary{}, err
+	}
+
+	var out ApplicationScore
+	if err := scanScore(tx.QueryRow(ctx, `
+        SELECT `+scoreColumns+` FROM application_scores
+        WHERE application_id = $1 AND reviewer = $2
+    `, applicationID, reviewer), &out); err != nil {
+		return ApplicationScore{}, ScoreSummary{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return ApplicationScore{}, ScoreSummary{}, err
+	}
+	return out, summary, nil
+}
+
+// refreshScoreSummaryTx recomputes the aggregate of an application's scores
+// and each score's deviation from it.
+func refreshScoreSummaryTx(ctx context.Context, tx pgx.Tx, applicationID string) (ScoreSummary, error) {
+	var s ScoreSummary
+	if err := tx.QueryRow(ctx, `
+        INSERT INTO application_score_summaries (application_id, score, score_count, spread)
+        SELECT application_id, round(avg(total), 2), count(*), round(stddev_pop(total), 2)
+        FROM application_scores WHERE application_id = $1
+        GROUP BY application_id
+        ON CONFLICT (application_id) DO UPDATE
+        SET score = EXCLUDED.score, score_count = EXCLUDED.score_count,
+            spread = EXCLUDED.spread, updated_at = now()
+        RETURNING score, score_count, spread, updated_at
+    `, applicationID).Scan(&s.Score, &s.Count, &s.Spread, &s.UpdatedAt); err != nil {
+		return ScoreSummary{}, err
+	}
+	_, err := tx.Exec(ctx, `
+        UPDATE application_scores SET deviation = total - $2
+        WHERE application_id = $1 AND deviation <> total - $2
+    `, applicationID, s.Score)
+	return s, err
+}
+
+// ListScores returns every reviewer's score of an application, oldest first.
+func (db *DB) ListScores(ctx context.Context, applicationID string) ([]ApplicationScore, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+scoreColumns+` FROM application_scores
+        WHERE application_id = $1
+        ORDER BY created_at, id
+    `, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ApplicationScore
+	for rows.Next() {
+		var s ApplicationScore
+		if err := scanScore(rows, &s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// GetScoreSummary returns the aggregate score of an application, or nil when
+// nobody scored it yet.
+func (db *DB) GetScoreSummary(ctx context.Context, applicationID string) (*ScoreSummary, error) {
+	var s ScoreSummary
+	err := db.pool.QueryRow(ctx, `
+        SELECT score, score_count, spread, updated_at
+        FROM application_score_summaries WHERE application_id = $1
+    `, applicationID).Scan(&s.Score, &s.Count, &s.Spread, &s.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListReviewerDeviations summarises how each reviewer's scores compare with
+// their colleagues'. Only applications scored by at least two reviewers count,
+// since a lone score cannot deviate.
+func (db *DB) ListReviewerDeviations(ctx context.Context) ([]ReviewerDeviation, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT sc.reviewer, count(*), round(avg(sc.deviation), 2), round(avg(abs(sc.deviation)), 2)
+        FROM application_scores sc
+        JOIN application_score_summaries s ON s.application_id = sc.application_id
+        WHERE s.score_count > 1
+        GROUP BY sc.reviewer
+        ORDER BY avg(abs(sc.deviation)) DESC, sc.reviewer
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ReviewerDeviation
+	for rows.Next() {
+		var d ReviewerDeviation
+		if err := rows.Scan(&d.Reviewer, &d.Scored, &d.MeanDeviation, &d.MeanAbsDeviation); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
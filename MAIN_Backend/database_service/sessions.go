@@ -0,0 +1,134 @@
+package database_service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrSessionInvalid = errors.New("session invalid or expired")
+
+// SessionTTL configures sliding sessions: every authenticated request pushes
+// the expiry out by Idle, but never past Absolute from creation.
+type SessionTTL struct {
+	Idle     time.Duration
+	Absolute time.Duration
+}
+
+const sessionColumns = "id, user_id, created_at, last_seen_at, expires_at, max_expires_at, revoked_at, user_agent"
+
+func scanSession(row pgx.Row, s *Session) error {
+	return row.Scan(&s.ID, &s.UserID, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.MaxExpiresAt, &s.RevokedAt, &s.UserAgent)
+}
+
+func createSessionTx(ctx context.Context, tx pgx.Tx, userID string, ttl SessionTTL, userAgent string) (Session, error) {
+	var s Session
+	err := scanSession(tx.QueryRow(ctx, `
+        INSERT INTO sessions (user_id, expires_at, max_expires_at, user_agent)
+        VALUES ($1, now() + make_interval(secs => $2), now() + make_interval(secs => $3), NULLIF($4, ''))
+        RETURNING `+sessionColumns+`
+    `, userID, ttl.Idle.Seconds(), ttl.Absolute.Seconds(), userAgent), &s)
+	return s, err
+}
+
+// CreateSession starts a session for a user who has already been authenticated
+// by other means (e.g. OAuth).
+func (db *DB) CreateSession(ctx context.Context, actor string, userID string, ttl SessionTTL, userAgent string) (Session, error) {
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Session{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return Session{}, err
+	}
+	s, err := createSessionTx(ctx, tx, userID, ttl, userAgent)
+	if err != nil {
+		return Session{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Session{}, err
+	}
+	return s, nil
+}
+
+// ExchangeTokenForSession burns a login token and starts a session for its user
+// in the same transaction, so a token can never yield two sessions.
+func (db *DB) ExchangeTokenForSession(ctx context.Context, actor string, token string, ttl SessionTTL, userAgent string) (User, Session, error) {
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return User{}, Session{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return User{}, Session{}, err
+	}
+
+	userID, err := consumeTokenTx(ctx, tx, token)
+	if err != nil {
+		return User{}, Session{}, err
+	}
+	s, err := createSessionTx(ctx, tx, userID, ttl, userAgent)
+	if err != nil {
+		return User{}, Session{}, err
+	}
+
+	var u User
+	if err := scanUser(tx.QueryRow(ctx, `
+        SELECT `+userColumns+`
+        FROM users WHERE id = $1
+    `, userID), &u); err != nil {
+		return User{}, Session{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return User{}, Session{}, err
+	}
+	return u, s, nil
+}
+
+// TouchSession validates a session and slides its expiry forward by idle,
+// capped at max_expires_at. Returns ErrSessionInvalid for unknown, revoked or
+// expired sessions.
+func (db *DB) TouchSession(ctx context.Context, sessionID string, idle time.Duration) (Session, User, error) {
+	var s Session
+	err := scanSession(db.pool.QueryRow(ctx, `
+        UPDATE sessions
+        SET last_seen_at = now(),
+            expires_at   = LEAST(now() + make_interval(secs => $2), max_expires_at)
+        WHERE id = $1 AND revoked_at IS NULL AND expires_at > now()
+        RETURNING `+sessionColumns+`
+    `, sessionID, idle.Seconds()), &s)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Session{}, User{}, ErrSessionInvalid
+		}
+		return Session{}, User{}, err
+	}
+
+	u, err := db.GetUserByID(ctx, s.UserID)
+	if err != nil {
+		return Session{}, User{}, err
+	}
+	if u == nil {
+		return Session{}, User{}, ErrSessionInvalid
+	}
+	return s, *u, nil
+}
+
+// RevokeSession ends a single session (logout).
+func (db *DB) RevokeSession(ctx context.Context, sessionID string) error {
+	_, err := db.pool.Exec(ctx, `UPDATE sessions SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, sessionID)
+	return err
+}
+
+// RevokeUserSessions ends every active session of a user, e.g. when they are banned.
+func (db *DB) RevokeUserSessions(ctx context.Context, userID string) (int64, error) {
+	tag, err := db.pool.Exec(ctx, `UPDATE sessions SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,220 @@
+package database_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrIllegalTransition   = errors.New("illegal status transition")
+	ErrReasonRequired      = errors.New("status change requires a reason")
+	ErrReviewerRequired    = errors.New("status change requires a reviewer")
+	ErrApplicationNotFound = errors.New("application not found")
+)
+
+// TransitionError reports a status change the lifecycle does not allow.
+// It matches ErrIllegalTransition with errors.Is.
+type TransitionError struct {
+	From   Status
+	To     Status
+	Appeal bool
+}
+
+func (e *TransitionError) Error() string {
+	if e.Appeal {
+		return fmt.Sprintf("illegal status transition %q -> %q (appeal)", e.From, e.To)
+	}
+	return fmt.Sprintf("illegal status transition %q -> %q", e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool { return target == ErrIllegalTransition }
+
+// transitions lists the forward moves allowed from each status.
+// Banning is allowed from anywhere and leaving a ban needs it to be lifted,
+// both are handled in checkTransition rather than here.
+var transitions = map[Status][]Status{
+	StatusApplicant:        {StatusInterviewPending, StatusDenied},
+	StatusInterviewPending: {StatusMember, StatusDenied},
+}
+
+// Valid reports whether s is one of the known statuses.
+func (s Status) Valid() bool {
+	switch s {
+	case StatusApplicant, StatusInterviewPending, StatusMember, StatusBanned, StatusDenied:
+		return true
+	}
+	return false
+}
+
+// checkTransition validates moving an application from one status to another.
+//
+//	applicant -> interview_pending -> member
+//	applicant, interview_pending -> denied
+//	any       -> banned
+//	banned    -> applicant or the ban's previous status (appeal or lifted ban only)
+//
+// It only sees the two statuses, so it lets an appeal leave a ban for any
+// status; callers check the target against the ban with checkAppealTarget.
+func checkTransition(from, to Status, appeal bool) error {
+	if !from.Valid() || !to.Valid() || from == to {
+		return &TransitionError{From: from, To: to, Appeal: appeal}
+	}
+	if from == StatusBanned {
+		// a lifted ban returns the application to where it was before
+		if appeal {
+			return nil
+		}
+		return &TransitionError{From: from, To: to, Appeal: appeal}
+	}
+	if appeal {
+		// appeals only ever lift a ban
+		return &TransitionError{From: from, To: to, Appeal: appeal}
+	}
+	if to == StatusBanned {
+		return nil
+	}
+	for _, next := range transitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return &TransitionError{From: from, To: to}
+}
+
+// UpdateApplicationStatus moves an application to a new status if the lifecycle
+// allows it, recording the reason and reviewer in application_status_history.
+func (db *DB) UpdateApplicationStatus(ctx context.Context, actor string, applicationID string, change StatusChange) (Application, error) {
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Application{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return Application{}, err
+	}
+
+	out, err := transitionTx(ctx, tx, actor, applicationID, change)
+	if err != nil {
+		return Application{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Application{}, err
+	}
+	return out, nil
+}
+
+// transitionTx performs a validated status change inside an existing transaction.
+func transitionTx(ctx context.Context, tx pgx.Tx, actor string, applicationID string, change StatusChange) (Application, error) {
+	change.Reason = strings.TrimSpace(change.Reason)
+	change.Reviewer = strings.TrimSpace(change.Reviewer)
+	if change.ReasonCode != "" {
+		if change.To != StatusDenied {
+			return Application{}, ErrReasonCodeNotDenial
+		}
+		reason, err := getRejectionReason(ctx, tx, change.ReasonCode)
+		if err != nil {
+			return Application{}, err
+		}
+		if reason == nil || !reason.Active {
+			return Application{}, ErrRejectionReasonNotFound
+		}
+		if change.Reason == "" {
+			change.Reason = reason.Label
+		}
+	}
+	if change.Reason == "" {
+		return Application{}, ErrReasonRequired
+	}
+	if change.Reviewer == "" {
+		return Application{}, ErrReviewerRequired
+	}
+
+	// lock the row so concurrent reviewers cannot race each other
+	var from Status
+	var superseded bool
+	err := tx.QueryRow(ctx, `SELECT status, superseded_at IS NOT NULL FROM applications WHERE id = $1 FOR UPDATE`, applicationID).Scan(&from, &superseded)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Application{}, ErrApplicationNotFound
+		}
+		return Application{}, err
+	}
+	if superseded {
+		return Application{}, ErrApplicationSuperseded
+	}
+	if err := checkTransition(from, change.To, change.Appeal); err != nil {
+		return Application{}, err
+	}
+	if change.Appeal {
+		if err := checkAppealTarget(ctx, tx, applicationID, change.To); err != nil {
+			return Application{}, err
+		}
+	}
+
+	row := tx.QueryRow(ctx, `
+        UPDATE applications SET status = $2
+        WHERE id = $1
+        RETURNING `+applicationColumns+`
+    `, applicationID, change.To)
+
+	var out Application
+	if err := scanApplication(row, &out); err != nil {
+		return Application{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+        INSERT INTO application_status_history (application_id, from_status, to_status, reason, reviewer, via_appeal, reason_code, actor)
+        VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''))
+    `, applicationID, from, change.To, change.Reason, change.Reviewer, change.Appeal, change.ReasonCode, actor); err != nil {
+		return Application{}, err
+	}
+	return out, nil
+}
+
+// checkAppealTarget lets a lifted ban return the application either to
+// applicant, for a fresh review, or to the status it had when it was banned.
+func checkAppealTarget(ctx context.Context, tx pgx.Tx, applicationID string, to Status) error {
+	if to == StatusApplicant {
+		return nil
+	}
+	var previous *Status
+	err := tx.QueryRow(ctx, `
+        SELECT previous_status FROM bans WHERE application_id = $1
+        ORDER BY issued_at DESC LIMIT 1
+    `, applicationID).Scan(&previous)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if previous == nil || *previous != to {
+		return &TransitionError{From: StatusBanned, To: to, Appeal: true}
+	}
+	return nil
+}
+
+// ListStatusHistory returns the transitions of an application, oldest first.
+func (db *DB) ListStatusHistory(ctx context.Context, applicationID string) ([]StatusHistoryEntry, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT id, application_id, from_status, to_status, reason, reviewer, via_appeal, reason_code, actor, created_at
+        FROM application_status_history
+        WHERE application_id = $1
+        ORDER BY created_at, id
+    `, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []StatusHistoryEntry
+	for rows.Next() {
+		var h StatusHistoryEntry
+		if err := rows.Scan(&h.ID, &h.ApplicationID, &h.From, &h.To, &h.Reason, &h.Reviewer, &h.ViaAppeal, &h.ReasonCode, &h.Actor, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
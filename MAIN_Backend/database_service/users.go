@@ -2,12 +2,24 @@ package database_service
 
 import (
 	"context"
+	"errors"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrMinecraftAccountTaken = errors.New("minecraft account already linked to another user")
+	ErrMinecraftNameTaken    = errors.New("minecraft name already used by another user")
 )
 
 // UpdateUserProfile updates basic user fields required by the application form.
-func (db *DB) UpdateUserProfile(ctx context.Context, actor string, userID string, age *int16, minecraftName *string) (User, error) {
+// When minecraftUUID is given it is the identity that counts: another user
+// holding the same UUID is ErrMinecraftAccountTaken. Another user still
+// holding the name is ErrMinecraftNameTaken; if their account was renamed,
+// the name reconciler frees the name once it notices.
+func (db *DB) UpdateUserProfile(ctx context.Context, actor string, userID string, age *int16, minecraftName *string, minecraftUUID *string) (User, error) {
 	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return User{}, err
@@ -16,14 +28,33 @@ func (db *DB) UpdateUserProfile(ctx context.Context, actor string, userID string
 	if err := withActor(ctx, tx, actor); err != nil {
 		return User{}, err
 	}
+
+	if minecraftUUID != nil {
+		var taken bool
+		if err := tx.QueryRow(ctx, `
+            SELECT EXISTS (SELECT 1 FROM users WHERE minecraft_uuid = $1 AND id <> $2)
+        `, *minecraftUUID, userID).Scan(&taken); err != nil {
+			return User{}, err
+		}
+		if taken {
+			return User{}, ErrMinecraftAccountTaken
+		}
+	}
+
 	row := tx.QueryRow(ctx, `
-        UPDATE users SET age = COALESCE($2, age), minecraft_name = $3
+        UPDATE users SET age = COALESCE($2, age), minecraft_name = $3, minecraft_uuid = COALESCE($4, minecraft_uuid)
         WHERE id = $1
-        RETURNING id, discord_user_id, discord_username, minecraft_name, age, created_at, updated_at
-    `, userID, age, minecraftName)
+        RETURNING `+userColumns+`
+    `, userID, age, minecraftName, minecraftUUID)
 
 	var out User
-	if err := row.Scan(&out.ID, &out.DiscordUserID, &out.DiscordUsername, &out.MinecraftName, &out.Age, &out.CreatedAt, &out.UpdatedAt); err != nil {
+	if err := scanUser(row, &out); err != nil {
+		if isUniqueViolationOn(err, "minecraft_name") {
+			return User{}, ErrMinecraftNameTaken
+		}
+		if isUniqueViolation(err) {
+			return User{}, ErrMinecraftAccountTaken
+		}
 		return User{}, err
 	}
 	if err := tx.Commit(ctx); err != nil {
@@ -31,3 +62,90 @@ func (db *DB) UpdateUserProfile(ctx context.Context, actor string, userID string
 	}
 	return out, nil
 }
+
+// GetUserByMinecraftUUID finds the user bound to a Minecraft account.
+func (db *DB) GetUserByMinecraftUUID(ctx context.Context, minecraftUUID string) (*User, error) {
+	row := db.pool.QueryRow(ctx, `
+        SELECT `+userColumns+`
+        FROM users WHERE minecraft_uuid = $1
+    `, minecraftUUID)
+	var u User
+	if err := scanUser(row, &u); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// isUniqueViolationOn reports a unique violation on a constraint named after
+// column, e.g. users_minecraft_name_key.
+func isUniqueViolationOn(err error, column string) bool {
+	var pgErr *pgconn.PgError
+	return isUniqueViolation(err) && errors.As(err, &pgErr) && strings.Contains(pgErr.ConstraintName, column)
+}
+
+// ListMemberMinecraftAccounts pages through members bound to a Minecraft UUID,
+// ordered by user id. Pass the last id of the previous page as afterUserID
+// (empty for the first page).
+func (db *DB) ListMemberMinecraftAccounts(ctx context.Context, afterUserID string, limit int) ([]User, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	after := any(nil)
+	if afterUserID != "" {
+		after = afterUserID
+	}
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+qualify("u", userColumns)+`
+        FROM users u JOIN applications a ON a.user_id = u.id AND a.superseded_at IS NULL
+        WHERE a.status = $1 AND u.minecraft_uuid IS NOT NULL
+          AND ($2::uuid IS NULL OR u.id > $2::uuid)
+        ORDER BY u.id
+        LIMIT $3
+    `, StatusMember, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []User
+	for rows.Next() {
+		var u User
+		if err := scanUser(rows, &u); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// ListMinecraftNameHistory returns a user's recorded renames, newest first.
+func (db *DB) ListMinecraftNameHistory(ctx context.Context, userID string) ([]MinecraftNameChange, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT id, user_id, minecraft_uuid, old_name::text, new_name::text, actor, changed_at
+        FROM minecraft_name_history
+        WHERE user_id = $1
+        ORDER BY changed_at DESC, id DESC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []MinecraftNameChange
+	for rows.Next() {
+		var c MinecraftNameChange
+		if err := rows.Scan(&c.ID, &c.UserID, &c.MinecraftUUID, &c.OldName, &c.NewName, &c.Actor, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,213 @@
+package database_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Vote is a reviewer's position on an application at its current stage.
+type Vote string
+
+const (
+	VoteApprove Vote = "approve"
+	VoteDeny    Vote = "deny"
+	VoteAbstain Vote = "abstain"
+)
+
+// Valid reports whether v is a known vote.
+func (v Vote) Valid() bool {
+	return v == VoteApprove || v == VoteDeny || v == VoteAbstain
+}
+
+var (
+	ErrUnknownVote  = errors.New("unknown vote")
+	ErrVotingClosed = errors.New("application is not open for voting")
+)
+
+// votingStages maps each status that is decided by vote to the decision an
+// approving vote leads to.
+var votingStages = map[Status]Decision{
+	StatusApplicant:        DecisionInterview,
+	StatusInterviewPending: DecisionAccept,
+}
+
+// VotePolicy decides when votes settle an application. Once at least Quorum
+// approve or deny votes are in, the application moves on if the approving
+// share of them reaches Threshold, and is denied if the denying share does.
+// Abstentions are recorded but never count toward the quorum.
+type VotePolicy struct {
+	Quorum    int     `json:"quorum"`
+	Threshold float64 `json:"threshold"`
+}
+
+// DefaultVotePolicy needs three votes and a two-thirds majority.
+var DefaultVotePolicy = VotePolicy{Quorum: 3, Threshold: 2.0 / 3.0}
+
+// ParseVotePolicy reads a policy from its string form (e.g. environment
+// variables); empty values keep the defaults.
+func ParseVotePolicy(quorum string, threshold string) (VotePolicy, error) {
+	p := DefaultVotePolicy
+	if quorum != "" {
+		n, err := strconv.Atoi(quorum)
+		if err != nil || n < 1 {
+			return p, fmt.Errorf("invalid vote quorum %q", quorum)
+		}
+		p.Quorum = n
+	}
+	if threshold != "" {
+		t, err := strconv.ParseFloat(threshold, 64)
+		// a threshold of 0.5 or less could both approve and deny at once
+		if err != nil || t <= 0.5 || t > 1 {
+			return p, fmt.Errorf("invalid vote threshold %q (want 0.5 < t <= 1)", threshold)
+		}
+		p.Threshold = t
+	}
+	return p, nil
+}
+
+// Outcome returns the decision t settles on for an application whose approval
+// leads to next, or false while the vote is still open.
+func (p VotePolicy) Outcome(t VoteTally, next Decision) (Decision, bool) {
+	if t.Approve+t.Deny < p.Quorum || t.Approve+t.Deny == 0 {
+		return "", false
+	}
+	decided := float64(t.Approve + t.Deny)
+	switch {
+	case float64(t.Approve)/decided >= p.Threshold:
+		return next, true
+	case float64(t.Deny)/decided >= p.Threshold:
+		return DecisionDeny, true
+	}
+	return "", false
+}
+
+// CastVote records (or changes) reviewer's vote on the application's current
+// stage. When the tally then satisfies policy, the resulting decision is
+// applied in the same transaction and returned in the result.
+func (db *DB) CastVote(ctx context.Context, actor string, applicationID string, reviewer string, v Vote, comment string, policy VotePolicy) (VoteResult, error) {
+	if !v.Valid() {
+		return VoteResult{}, ErrUnknownVote
+	}
+	reviewer = strings.TrimSpace(reviewer)
+	if reviewer == "" {
+		return VoteResult{}, ErrReviewerRequired
+	}
+
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return VoteResult{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := withActor(ctx, tx, actor); err != nil {
+		return VoteResult{}, err
+	}
+
+	// lock the application so concurrent votes are tallied one at a time
+	var stage Status
+	var superseded bool
+	if err := tx.QueryRow(ctx, `SELECT status, superseded_at IS NOT NULL FROM applications WHERE id = $1 FOR UPDATE`, applicationID).Scan(&stage, &superseded); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return VoteResult{}, ErrApplicationNotFound
+		}
+		return VoteResult{}, err
+	}
+	if superseded {
+		return VoteResult{}, ErrApplicationSuperseded
+	}
+	next, ok := votingStages[stage]
+	if !ok {
+		return VoteResult{}, ErrVotingClosed
+	}
+
+	if _, err := tx.Exec(ctx, `
+        INSERT INTO application_votes (application_id, stage, reviewer, vote, comment, actor)
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''))
+        ON CONFLICT (application_id, stage, reviewer) DO UPDATE
+        SET vote = EXCLUDED.vote, comment = EXCLUDED.comment, actor = EXCLUDED.actor
+    `, applicationID, stage, reviewer, v, strings.TrimSpace(comment), actor); err != nil {
+		return VoteResult{}, err
+	}
+
+	res := VoteResult{Stage: stage}
+	if res.Tally, err = tallyTx(ctx, tx, applicationID, stage); err != nil {
+		return VoteResult{}, err
+	}
+	if d, ok := policy.Outcome(res.Tally, next); ok {
+		to, _ := d.Target()
+		app, err := transitionTx(ctx, tx, actor, applicationID, StatusChange{
+			To:       to,
+			Reason:   fmt.Sprintf("staff vote: %d approve, %d deny, %d abstain", res.Tally.Approve, res.Tally.Deny, res.Tally.Abstain),
+			Reviewer: reviewer,
+		})
+		if err != nil {
+			return VoteResult{}, err
+		}
+		res.Decision = &d
+		res.Application = &app
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return VoteResult{}, err
+	}
+	return res, nil
+}
+
+func tallyTx(ctx context.Context, tx pgx.Tx, applicationID string, stage Status) (VoteTally, error) {
+	var t VoteTally
+	err := tx.QueryRow(ctx, `
+        SELECT count(*) FILTER (WHERE vote = 'approve'),
+               count(*) FILTER (WHERE vote = 'deny'),
+               count(*) FILTER (WHERE vote = 'abstain')
+        FROM application_votes WHERE application_id = $1 AND stage = $2
+    `, applicationID, stage).Scan(&t.Approve, &t.Deny, &t.Abstain)
+	return t, err
+}
+
+// ListVotes returns every vote cast on an application, grouped by stage in
+// the order they were cast.
+func (db *DB) ListVotes(ctx context.Context, applicationID string) ([]ApplicationVote, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT id, application_id, stage, reviewer, vote, comment, created_at, updated_at
+        FROM application_votes
+        WHERE application_id = $1
+        ORDER BY stage, created_at, id
+    `, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ApplicationVote
+	for rows.Next() {
+		var v ApplicationVote
+		if err := rows.Scan(&v.ID, &v.ApplicationID, &v.Stage, &v.Reviewer, &v.Vote, &v.Comment, &v.CreatedAt, &v.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// TallyVotes counts the votes for a stage of an application.
+func TallyVotes(votes []ApplicationVote, stage Status) VoteTally {
+	var t VoteTally
+	for _, v := range votes {
+		if v.Stage != stage {
+			continue
+		}
+		switch v.Vote {
+		case VoteApprove:
+			t.Approve++
+		case VoteDeny:
+			t.Deny++
+		case VoteAbstain:
+			t.Abstain++
+		}
+	}
+	return t
+}
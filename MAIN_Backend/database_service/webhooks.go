@@ -0,0 +1,293 @@
+package database_service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Webhook delivery states.
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook endpoint not found")
+	ErrInvalidWebhook  = errors.New("webhook url must be an absolute http(s) url")
+	ErrInvalidFilter   = errors.New("invalid webhook filter")
+)
+
+const webhookColumns = "id, url, description, tables, actions, statuses, created_by, created_at, disabled_at"
+
+func scanWebhook(row pgx.Row, w *WebhookEndpoint) error {
+	return row.Scan(&w.ID, &w.URL, &w.Description, &w.Tables, &w.Actions, &w.Statuses, &w.CreatedBy, &w.CreatedAt, &w.DisabledAt)
+}
+
+const webhookDeliveryColumns = "id, endpoint_id, event_id, event_type, payload, state, attempts, next_attempt_at, response_status, response_body, last_error, created_at, delivered_at"
+
+func scanWebhookDelivery(row pgx.Row, d *WebhookDelivery) error {
+	return row.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &d.Payload, &d.State, &d.Attempts, &d.NextAttemptAt,
+		&d.ResponseStatus, &d.ResponseBody, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+}
+
+// CreateWebhook registers an endpoint and returns its signing secret, which is
+// only ever returned here.
+func (db *DB) CreateWebhook(ctx context.Context, actor string, w WebhookEndpoint) (WebhookEndpoint, string, error) {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return WebhookEndpoint{}, "", ErrInvalidWebhook
+	}
+	for i, a := range w.Actions {
+		w.Actions[i] = strings.ToUpper(a)
+	}
+	for _, s := range w.Statuses {
+		if !Status(s).Valid() {
+			return WebhookEndpoint{}, "", fmt.Errorf("%w: unknown status %q", ErrInvalidFilter, s)
+		}
+	}
+	for _, p := range []*[]string{&w.Tables, &w.Actions, &w.Statuses} {
+		if *p == nil {
+			*p = []string{}
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return WebhookEndpoint{}, "", err
+	}
+	secret := "whsec_" + base64.RawURLEncoding.EncodeToString(b)
+
+	var out WebhookEndpoint
+	err = scanWebhook(db.pool.QueryRow(ctx, `
+        INSERT INTO webhook_endpoints (url, description, secret, tables, actions, statuses, created_by)
+        VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+        RETURNING `+webhookColumns+`
+    `, w.URL, w.Description, secret, w.Tables, w.Actions, w.Statuses, actor), &out)
+	if err != nil {
+		return WebhookEndpoint{}, "", err
+	}
+	return out, secret, nil
+}
+
+// ListWebhooks returns every endpoint, including disabled ones.
+func (db *DB) ListWebhooks(ctx context.Context) ([]WebhookEndpoint, error) {
+	rows, err := db.pool.Query(ctx, `SELECT `+webhookColumns+` FROM webhook_endpoints ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []WebhookEndpoint
+	for rows.Next() {
+		var w WebhookEndpoint
+		if err := scanWebhook(rows, &w); err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+// DisableWebhook stops all future deliveries to an endpoint, including
+// pending retries.
+func (db *DB) DisableWebhook(ctx context.Context, id string) error {
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tag, err := tx.Exec(ctx, `UPDATE webhook_endpoints SET disabled_at = now() WHERE id = $1 AND disabled_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWebhookNotFound
+	}
+	if _, err := tx.Exec(ctx, `
+        UPDATE webhook_deliveries SET state = 'failed', last_error = 'endpoint disabled'
+        WHERE endpoint_id = $1 AND state = 'pending'
+    `, id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// EnqueueWebhookEvent creates a pending delivery of payload for every enabled
+// endpoint whose filters match ev. Enqueuing the same event twice is a no-op,
+// so it is safe under at-least-once outbox delivery.
+func (db *DB) EnqueueWebhookEvent(ctx context.Context, ev AppEvent, eventType string, payload []byte) (int64, error) {
+	status := ""
+	if ev.Status != nil {
+		status = string(*ev.Status)
+	}
+	tag, err := db.pool.Exec(ctx, `
+        INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload)
+        SELECT id, $1, $2, $3 FROM webhook_endpoints
+        WHERE disabled_at IS NULL
+          AND (cardinality(tables) = 0 OR $4 = ANY(tables))
+          AND (cardinality(actions) = 0 OR $5 = ANY(actions))
+          AND (cardinality(statuses) = 0 OR $6 = ANY(statuses))
+        ON CONFLICT (endpoint_id, event_id) DO NOTHING
+    `, ev.ID, eventType, payload, ev.Table, ev.Action, status)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// EnqueueWebhookTest creates a delivery of payload to a single endpoint,
+// regardless of its filters. It is created already leased so the caller can
+// send it right away without the dispatcher picking it up too.
+func (db *DB) EnqueueWebhookTest(ctx context.Context, endpointID string, eventType string, payload []byte) (WebhookDelivery, error) {
+	var d WebhookDelivery
+	err := scanWebhookDelivery(db.pool.QueryRow(ctx, `
+        INSERT INTO webhook_deliveries (endpoint_id, event_type, payload, next_attempt_at)
+        SELECT id, $2, $3, now() + interval '5 minutes' FROM webhook_endpoints WHERE id = $1 AND disabled_at IS NULL
+        RETURNING `+webhookDeliveryColumns+`
+    `, endpointID, eventType, payload), &d)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return WebhookDelivery{}, ErrWebhookNotFound
+		}
+		return WebhookDelivery{}, err
+	}
+	return d, nil
+}
+
+// ClaimWebhookDeliveries leases up to limit due deliveries together with their
+// endpoint. A claimed delivery is hidden from other claimers for lease, so
+// several API replicas never send the same attempt twice.
+func (db *DB) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]ClaimedWebhookDelivery, error) {
+	rows, err := db.pool.Query(ctx, `
+        WITH due AS (
+            SELECT d.id FROM webhook_deliveries d
+            WHERE d.state = 'pending' AND d.next_attempt_at <= now()
+            ORDER BY d.next_attempt_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        ), claimed AS (
+            UPDATE webhook_deliveries d SET next_attempt_at = now() + make_interval(secs => $2)
+            FROM due WHERE d.id = due.id
+            RETURNING d.*
+        )
+        SELECT c.id, c.endpoint_id, c.event_id, c.event_type, c.payload, c.state, c.attempts, c.next_attempt_at,
+               c.response_status, c.response_body, c.last_error, c.created_at, c.delivered_at,
+               e.url, e.secret
+        FROM claimed c JOIN webhook_endpoints e ON e.id = c.endpoint_id
+        ORDER BY c.id
+    `, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ClaimedWebhookDelivery
+	for rows.Next() {
+		var c ClaimedWebhookDelivery
+		d := &c.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &d.Payload, &d.State, &d.Attempts, &d.NextAttemptAt,
+			&d.ResponseStatus, &d.ResponseBody, &d.LastError, &d.CreatedAt, &d.DeliveredAt, &c.URL, &c.Secret); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// GetWebhookSecret returns the signing secret and url of an enabled endpoint.
+func (db *DB) GetWebhookSecret(ctx context.Context, endpointID string) (target string, secret string, err error) {
+	err = db.pool.QueryRow(ctx, `SELECT url, secret FROM webhook_endpoints WHERE id = $1 AND disabled_at IS NULL`, endpointID).Scan(&target, &secret)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", ErrWebhookNotFound
+	}
+	return target, secret, err
+}
+
+// RecordWebhookAttempt stores the outcome of one delivery attempt. Failures
+// are rescheduled with policy's backoff until MaxAttempts, then marked failed.
+func (db *DB) RecordWebhookAttempt(ctx context.Context, deliveryID int64, a WebhookAttempt, policy RetryPolicy) (WebhookDelivery, error) {
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var attempts int
+	if err := tx.QueryRow(ctx, `SELECT attempts + 1 FROM webhook_deliveries WHERE id = $1 FOR UPDATE`, deliveryID).Scan(&attempts); err != nil {
+		return WebhookDelivery{}, err
+	}
+
+	state, next := WebhookDelivered, time.Duration(0)
+	if a.Err != "" {
+		state = WebhookFailed
+		if attempts < policy.MaxAttempts {
+			state, next = WebhookPending, policy.Backoff(attempts)
+		}
+	}
+
+	var d WebhookDelivery
+	err = scanWebhookDelivery(tx.QueryRow(ctx, `
+        UPDATE webhook_deliveries
+        SET attempts = $2, state = $3,
+            next_attempt_at = now() + make_interval(secs => $4),
+            response_status = $5, response_body = $6, last_error = NULLIF($7, ''),
+            delivered_at = CASE WHEN $3 = 'delivered' THEN now() END
+        WHERE id = $1
+        RETURNING `+webhookDeliveryColumns+`
+    `, deliveryID, attempts, state, next.Seconds(), a.Status, a.Body, a.Err), &d)
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return WebhookDelivery{}, err
+	}
+	return d, nil
+}
+
+// ListWebhookDeliveries returns an endpoint's delivery log, newest first, or
+// ErrWebhookNotFound when there is no such endpoint.
+func (db *DB) ListWebhookDeliveries(ctx context.Context, endpointID string, limit int, offset int) ([]WebhookDelivery, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	var exists bool
+	if err := db.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM webhook_endpoints WHERE id = $1)`, endpointID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrWebhookNotFound
+	}
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+webhookDeliveryColumns+`
+        FROM webhook_deliveries WHERE endpoint_id = $1
+        ORDER BY created_at DESC, id DESC
+        LIMIT $2 OFFSET $3
+    `, endpointID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := scanWebhookDelivery(rows, &d); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
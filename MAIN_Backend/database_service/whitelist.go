@@ -0,0 +1,34 @@
+package database_service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GetWhitelistedName returns the name the Minecraft account was last
+// whitelisted under, or "" when it is not on the whitelist.
+func (db *DB) GetWhitelistedName(ctx context.Context, minecraftUUID string) (string, error) {
+	var name string
+	err := db.pool.QueryRow(ctx, `SELECT name FROM whitelist_entries WHERE minecraft_uuid = $1`, minecraftUUID).Scan(&name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return name, err
+}
+
+// SetWhitelistedName records that the account is whitelisted as name; an
+// empty name records that it is no longer whitelisted.
+func (db *DB) SetWhitelistedName(ctx context.Context, minecraftUUID string, name string) error {
+	if name == "" {
+		_, err := db.pool.Exec(ctx, `DELETE FROM whitelist_entries WHERE minecraft_uuid = $1`, minecraftUUID)
+		return err
+	}
+	_, err := db.pool.Exec(ctx, `
+        INSERT INTO whitelist_entries (minecraft_uuid, name) VALUES ($1, $2)
+        ON CONFLICT (minecraft_uuid) DO UPDATE SET name = EXCLUDED.name
+        WHERE whitelist_entries.name IS DISTINCT FROM EXCLUDED.name
+    `, minecraftUUID, name)
+	return err
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+// botStore is the slice of the database layer used by command handlers.
+type botStore interface {
+	CreateOrRotateLoginToken(ctx context.Context, actor string, discordUserID int64, discordUsername string) (ds.User, ds.LoginToken, error)
+	GetUserByDiscordID(ctx context.Context, discordUserID int64) (*ds.User, error)
+	GetApplicationByUser(ctx context.Context, userID string) (*ds.Application, error)
+	ApplyDecision(ctx context.Context, actor string, applicationID string, d ds.Decision, reviewer string, reason string, reasonCode string) (ds.Application, error)
+	CastVote(ctx context.Context, actor string, applicationID string, reviewer string, v ds.Vote, comment string, policy ds.VotePolicy) (ds.VoteResult, error)
+}
+
+// bot handles slash command interactions for a single guild.
+type bot struct {
+	session    botSession
+	store      botStore
+	guildID    string
+	formURL    string
+	staffRoles map[string]bool
+	// staffNames maps staff Discord user ids to the names they use with the
+	// admin API, so both record the same reviewer
+	staffNames map[string]string
+	votePolicy ds.VotePolicy
+}
+
+var staffPermission int64 = discordgo.PermissionManageServer
+
+// commandDefinitions returns the guild slash commands registered on startup.
+func commandDefinitions() []*discordgo.ApplicationCommand {
+	target := func(verb string) []*discordgo.ApplicationCommandOption {
+		return []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionUser, Name: "user", Description: "Applicant to " + verb, Required: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "reason", Description: "Why (kept in the status history)", Required: true},
+		}
+	}
+	return []*discordgo.ApplicationCommand{
+		{Name: "apply", Description: "Get a link to the application form in your DMs"},
+		{Name: "status", Description: "Show the status of your application"},
+		{Name: "accept", Description: "Accept an applicant as member", DefaultMemberPermissions: &staffPermission, Options: target("accept")},
+		{Name: "deny", Description: "Deny an application", DefaultMemberPermissions: &staffPermission, Options: append(target("deny"),
+			&discordgo.ApplicationCommandOption{Type: discordgo.ApplicationCommandOptionString, Name: "reason_code", Description: "Rejection reason to DM the applicant, e.g. low_effort"},
+		)},
+		{Name: "interview", Description: "Move an applicant to interview", DefaultMemberPermissions: &staffPermission, Options: target("invite to interview")},
+		{Name: "vote", Description: "Vote on an application at its current stage", DefaultMemberPermissions: &staffPermission, Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionUser, Name: "user", Description: "Applicant to vote on", Required: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "vote", Description: "Your vote", Required: true, Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "approve", Value: string(ds.VoteApprove)},
+				{Name: "deny", Value: string(ds.VoteDeny)},
+				{Name: "abstain", Value: string(ds.VoteAbstain)},
+			}},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "comment", Description: "Optional comment for other reviewers"},
+		}},
+	}
+}
+
+// registerCommands overwrites the guild's commands with commandDefinitions.
+func (b *bot) registerCommands(appID string) error {
+	_, err := b.session.ApplicationCommandBulkOverwrite(appID, b.guildID, commandDefinitions())
+	return err
+}
+
+// handleInteraction dispatches a slash command. The response is deferred first
+// so database calls are not bound by Discord's three second reply window.
+func (b *bot) handleInteraction(i *discordgo.Interaction) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+	if err := b.session.InteractionRespond(i, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	}); err != nil {
+		log.Printf("interaction defer: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var msg string
+	data := i.ApplicationCommandData()
+	switch data.Name {
+	case "apply":
+		msg = b.handleApply(ctx, i)
+	case "status":
+		msg = b.handleStatus(ctx, i)
+	case "accept":
+		msg = b.handleDecision(ctx, i, ds.DecisionAccept)
+	case "deny":
+		msg = b.handleDecision(ctx, i, ds.DecisionDeny)
+	case "interview":
+		msg = b.handleDecision(ctx, i, ds.DecisionInterview)
+	case "vote":
+		msg = b.handleVote(ctx, i)
+	default:
+		msg = "Unknown command."
+	}
+	if _, err := b.session.InteractionResponseEdit(i, &discordgo.WebhookEdit{Content: &msg}); err != nil {
+		log.Printf("interaction reply (%s): %v", data.Name, err)
+	}
+}
+
+// interactionUser returns the invoking user for guild and DM interactions alike.
+func interactionUser(i *discordgo.Interaction) *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+func (b *bot) handleApply(ctx context.Context, i *discordgo.Interaction) string {
+	u := interactionUser(i)
+	id, err := strconv.ParseInt(u.ID, 10, 64)
+	if err != nil {
+		return "Could not read your Discord id."
+	}
+	_, tok, err := b.store.CreateOrRotateLoginToken(ctx, "discordbot:apply", id, u.Username)
+	if err != nil {
+		log.Printf("/apply token for %s: %v", u.ID, err)
+		return "Something went wrong, please try again later."
+	}
+	link, err := formLink(b.formURL, tok.Token)
+	if err != nil {
+		log.Printf("/apply form link: %v", err)
+		return "The application form is not configured yet."
+	}
+	dm := fmt.Sprintf("Here is your application link (valid until <t:%d:t>):\n%s", tok.ExpiresAt.Unix(), link)
+	if err := sendDM(b.session, u.ID, dm); err != nil {
+		log.Printf("/apply DM to %s: %v", u.ID, err)
+		return "I could not DM you. Please allow direct messages from server members and try again."
+	}
+	return "Check your DMs for the application link."
+}
+
+// formLink appends the login token to the configured form URL.
+func formLink(base string, token string) (string, error) {
+	if base == "" {
+		return "", errors.New("APPLY_FORM_URL not set")
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (b *bot) handleStatus(ctx context.Context, i *discordgo.Interaction) string {
+	u := interactionUser(i)
+	app, err := b.applicationFor(ctx, u.ID)
+	if err != nil {
+		log.Printf("/status for %s: %v", u.ID, err)
+		return "Something went wrong, please try again later."
+	}
+	if app == nil {
+		return "You have not applied yet. Use /apply to get started."
+	}
+	return fmt.Sprintf("Your application is **%s** (last updated <t:%d:R>).", app.Status, app.UpdatedAt.Unix())
+}
+
+func (b *bot) handleDecision(ctx context.Context, i *discordgo.Interaction, d ds.Decision) string {
+	if !b.isStaff(i) {
+		return "Only staff can do that."
+	}
+	var target *discordgo.User
+	var reason, reasonCode string
+	data := i.ApplicationCommandData()
+	for _, opt := range data.Options {
+		switch opt.Name {
+		case "user":
+			target = opt.UserValue(nil)
+		case "reason":
+			reason = opt.StringValue()
+		case "reason_code":
+			reasonCode = strings.TrimSpace(opt.StringValue())
+		}
+	}
+	if target == nil {
+		return "Missing user."
+	}
+	app, err := b.applicationFor(ctx, target.ID)
+	if err != nil {
+		log.Printf("/%s lookup %s: %v", d, target.ID, err)
+		return "Something went wrong, please try again later."
+	}
+	if app == nil {
+		return fmt.Sprintf("<@%s> has no application.", target.ID)
+	}
+
+	reviewer, _ := b.reviewer(i)
+	updated, err := b.store.ApplyDecision(ctx, "discordbot:"+string(d), app.ID, d, reviewer, reason, reasonCode)
+	if err != nil {
+		if errors.Is(err, ds.ErrIllegalTransition) || errors.Is(err, ds.ErrReasonRequired) || errors.Is(err, ds.ErrRejectionReasonNotFound) {
+			return fmt.Sprintf("Cannot %s <@%s>: %v", d, target.ID, err)
+		}
+		log.Printf("/%s apply %s: %v", d, app.ID, err)
+		return "Something went wrong, please try again later."
+	}
+	return fmt.Sprintf("<@%s> is now **%s**.", target.ID, updated.Status)
+}
+
+func (b *bot) handleVote(ctx context.Context, i *discordgo.Interaction) string {
+	if !b.isStaff(i) {
+		return "Only staff can do that."
+	}
+	var target *discordgo.User
+	var vote ds.Vote
+	var comment string
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "user":
+			target = opt.UserValue(nil)
+		case "vote":
+			vote = ds.Vote(opt.StringValue())
+		case "comment":
+			comment = opt.StringValue()
+		}
+	}
+	if target == nil {
+		return "Missing user."
+	}
+	app, err := b.applicationFor(ctx, target.ID)
+	if err != nil {
+		log.Printf("/vote lookup %s: %v", target.ID, err)
+		return "Something went wrong, please try again later."
+	}
+	if app == nil {
+		return fmt.Sprintf("<@%s> has no application.", target.ID)
+	}
+
+	reviewer, ok := b.reviewer(i)
+	if !ok {
+		// an unmapped Discord id would count as a second voter next to the
+		// same person's admin API votes
+		return "Your Discord account is not linked to a staff name (STAFF_DISCORD_IDS), so your vote cannot be counted."
+	}
+	res, err := b.store.CastVote(ctx, "discordbot:vote", app.ID, reviewer, vote, comment, b.votePolicy)
+	if err != nil {
+		if errors.Is(err, ds.ErrVotingClosed) || errors.Is(err, ds.ErrUnknownVote) || errors.Is(err, ds.ErrIllegalTransition) {
+			return fmt.Sprintf("Cannot vote on <@%s>: %v", target.ID, err)
+		}
+		log.Printf("/vote %s: %v", app.ID, err)
+		return "Something went wrong, please try again later."
+	}
+	msg := fmt.Sprintf("Vote recorded for <@%s> (%s): %d approve, %d deny, %d abstain.",
+		target.ID, res.Stage, res.Tally.Approve, res.Tally.Deny, res.Tally.Abstain)
+	if res.Application != nil {
+		msg += fmt.Sprintf(" The vote passed: <@%s> is now **%s**.", target.ID, res.Application.Status)
+	}
+	return msg
+}
+
+// reviewer returns the canonical reviewer identity of the invoking staff
+// member: "staff:<name>" when their Discord id is in STAFF_DISCORD_IDS, the
+// same identity the admin API records. Otherwise it falls back to
+// "discord:<id>" and reports false.
+func (b *bot) reviewer(i *discordgo.Interaction) (string, bool) {
+	id := interactionUser(i).ID
+	if name, ok := b.staffNames[id]; ok {
+		return "staff:" + name, true
+	}
+	return "discord:" + id, false
+}
+
+// applicationFor resolves a Discord user id to their application, if any.
+func (b *bot) applicationFor(ctx context.Context, discordID string) (*ds.Application, error) {
+	id, err := strconv.ParseInt(discordID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	user, err := b.store.GetUserByDiscordID(ctx, id)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	return b.store.GetApplicationByUser(ctx, user.ID)
+}
+
+// isStaff checks the invoking member against STAFF_ROLE_IDS, falling back to
+// the Manage Server permission when no staff roles are configured.
+func (b *bot) isStaff(i *discordgo.Interaction) bool {
+	if i.Member == nil {
+		return false
+	}
+	if len(b.staffRoles) == 0 {
+		return i.Member.Permissions&discordgo.PermissionManageServer != 0
+	}
+	for _, r := range i.Member.Roles {
+		if b.staffRoles[r] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+// fakeStore is an in-memory botStore.
+type fakeStore struct {
+	users map[int64]*ds.User
+	apps  map[string]*ds.Application
+	// err is returned by ApplyDecision and CastVote when set
+	err error
+
+	decisions []recordedDecision
+	votes     []recordedVote
+	vote      ds.VoteResult
+}
+
+type recordedDecision struct {
+	appID, reviewer, reason, reasonCode string
+	decision                            ds.Decision
+}
+
+type recordedVote struct {
+	appID, reviewer, comment string
+	vote                     ds.Vote
+}
+
+var _ botStore = (*fakeStore)(nil)
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{users: map[int64]*ds.User{}, apps: map[string]*ds.Application{}}
+}
+
+// addApplicant registers a user with an application in the given status.
+func (f *fakeStore) addApplicant(discordID int64, status ds.Status) *ds.Application {
+	id := strconv.FormatInt(discordID, 10)
+	f.users[discordID] = &ds.User{ID: "user-" + id, DiscordUserID: discordID, DiscordUsername: "u" + id}
+	app := &ds.Application{ID: "app-" + id, UserID: "user-" + id, Attempt: 1, Status: status, UpdatedAt: time.Unix(1700000000, 0)}
+	f.apps["user-"+id] = app
+	return app
+}
+
+func (f *fakeStore) CreateOrRotateLoginToken(ctx context.Context, actor string, discordUserID int64, discordUsername string) (ds.User, ds.LoginToken, error) {
+	u := ds.User{ID: "user-" + strconv.FormatInt(discordUserID, 10), DiscordUserID: discordUserID, DiscordUsername: discordUsername}
+	return u, ds.LoginToken{UserID: u.ID, Token: "tok-123", ExpiresAt: time.Unix(1700000600, 0)}, nil
+}
+
+func (f *fakeStore) GetUserByDiscordID(ctx context.Context, discordUserID int64) (*ds.User, error) {
+	return f.users[discordUserID], nil
+}
+
+func (f *fakeStore) GetApplicationByUser(ctx context.Context, userID string) (*ds.Application, error) {
+	return f.apps[userID], nil
+}
+
+func (f *fakeStore) ApplyDecision(ctx context.Context, actor string, applicationID string, d ds.Decision, reviewer string, reason string, reasonCode string) (ds.Application, error) {
+	if f.err != nil {
+		return ds.Application{}, f.err
+	}
+	to, ok := d.Target()
+	if !ok {
+		return ds.Application{}, ds.ErrUnknownDecision
+	}
+	f.decisions = append(f.decisions, recordedDecision{appID: applicationID, reviewer: reviewer, reason: reason, reasonCode: reasonCode, decision: d})
+	for _, app := range f.apps {
+		if app.ID == applicationID {
+			app.Status = to
+			return *app, nil
+		}
+	}
+	return ds.Application{}, ds.ErrApplicationNotFound
+}
+
+func (f *fakeStore) CastVote(ctx context.Context, actor string, applicationID string, reviewer string, v ds.Vote, comment string, policy ds.VotePolicy) (ds.VoteResult, error) {
+	if f.err != nil {
+		return ds.VoteResult{}, f.err
+	}
+	f.votes = append(f.votes, recordedVote{appID: applicationID, reviewer: reviewer, comment: comment, vote: v})
+	return f.vote, nil
+}
+
+const (
+	staffRole   = "900"
+	staffID     = "100"
+	applicantID = "200"
+)
+
+func newTestBot() (*bot, *fakeSession, *fakeStore) {
+	s := newFakeSession()
+	st := newFakeStore()
+	return &bot{
+		session:    s,
+		store:      st,
+		guildID:    "1",
+		formURL:    "https://apply.example/form",
+		staffRoles: map[string]bool{staffRole: true},
+		staffNames: map[string]string{staffID: "mod"},
+		votePolicy: ds.DefaultVotePolicy,
+	}, s, st
+}
+
+func staffMember() *discordgo.Member {
+	return &discordgo.Member{User: &discordgo.User{ID: staffID, Username: "mod"}, Roles: []string{staffRole}}
+}
+
+func plainMember(id string) *discordgo.Member {
+	return &discordgo.Member{User: &discordgo.User{ID: id, Username: "u" + id}}
+}
+
+func command(name string, invoker *discordgo.Member, opts ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.Interaction {
+	return &discordgo.Interaction{
+		ID:     "i-" + name,
+		Type:   discordgo.InteractionApplicationCommand,
+		Member: invoker,
+		Data:   discordgo.ApplicationCommandInteractionData{Name: name, Options: opts},
+	}
+}
+
+func userOpt(id string) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: "user", Type: discordgo.ApplicationCommandOptionUser, Value: id}
+}
+
+func stringOpt(name string, value string) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionString, Value: value}
+}
+
+func TestRegisterCommands(t *testing.T) {
+	b, s, _ := newTestBot()
+	if err := b.registerCommands("app"); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range s.commands {
+		names = append(names, c.Name)
+	}
+	if got := strings.Join(names, ","); got != "apply,status,accept,deny,interview,vote" {
+		t.Fatalf("registered %s", got)
+	}
+}
+
+func TestInteractionDefersEphemeral(t *testing.T) {
+	b, s, _ := newTestBot()
+	b.handleInteraction(command("status", plainMember(applicantID)))
+	if len(s.responses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(s.responses))
+	}
+	r := s.responses[0]
+	if r.Type != discordgo.InteractionResponseDeferredChannelMessageWithSource || r.Data.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		t.Fatalf("response %+v is not an ephemeral defer", r)
+	}
+}
+
+func TestApplyDMsFormLink(t *testing.T) {
+	b, s, _ := newTestBot()
+	b.handleInteraction(command("apply", plainMember(applicantID)))
+	if got := s.reply(); got != "Check your DMs for the application link." {
+		t.Fatalf("reply %q", got)
+	}
+	dms := s.dms[applicantID]
+	if len(dms) != 1 || !strings.Contains(dms[0], "https://apply.example/form?token=tok-123") {
+		t.Fatalf("DMs %q", dms)
+	}
+}
+
+func TestApplyDMsClosed(t *testing.T) {
+	b, s, _ := newTestBot()
+	s.dmErr[applicantID] = restError(http.StatusForbidden)
+	b.handleInteraction(command("apply", plainMember(applicantID)))
+	if got := s.reply(); !strings.Contains(got, "could not DM you") {
+		t.Fatalf("reply %q", got)
+	}
+}
+
+func TestApplyWithoutFormURL(t *testing.T) {
+	b, s, _ := newTestBot()
+	b.formURL = ""
+	b.handleInteraction(command("apply", plainMember(applicantID)))
+	if got := s.reply(); got != "The application form is not configured yet." {
+		t.Fatalf("reply %q", got)
+	}
+	if len(s.dms) != 0 {
+		t.Fatalf("sent DMs %v", s.dms)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	b, s, st := newTestBot()
+	b.handleInteraction(command("status", plainMember(applicantID)))
+	if got := s.reply(); !strings.HasPrefix(got, "You have not applied yet") {
+		t.Fatalf("reply %q", got)
+	}
+
+	st.addApplicant(200, ds.StatusInterviewPending)
+	b.handleInteraction(command("status", plainMember(applicantID)))
+	if got := s.reply(); got != "Your application is **interview_pending** (last updated <t:1700000000:R>)." {
+		t.Fatalf("reply %q", got)
+	}
+}
+
+func TestDecisions(t *testing.T) {
+	cases := []struct {
+		command string
+		want    ds.Status
+	}{
+		{"accept", ds.StatusMember},
+		{"deny", ds.StatusDenied},
+		{"interview", ds.StatusInterviewPending},
+	}
+	for _, c := range cases {
+		t.Run(c.command, func(t *testing.T) {
+			b, s, st := newTestBot()
+			st.addApplicant(200, ds.StatusApplicant)
+			b.handleInteraction(command(c.command, staffMember(), userOpt(applicantID), stringOpt("reason", "looks good")))
+
+			if want := "<@200> is now **" + string(c.want) + "**."; s.reply() != want {
+				t.Fatalf("reply %q, want %q", s.reply(), want)
+			}
+			if len(st.decisions) != 1 {
+				t.Fatalf("got %d decisions", len(st.decisions))
+			}
+			d := st.decisions[0]
+			if d.appID != "app-200" || d.reason != "looks good" || d.reviewer != "staff:mod" || d.reasonCode != "" {
+				t.Fatalf("decision %+v", d)
+			}
+		})
+	}
+}
+
+func TestDenyPassesReasonCode(t *testing.T) {
+	b, _, st := newTestBot()
+	st.addApplicant(200, ds.StatusApplicant)
+	b.handleInteraction(command("deny", staffMember(), userOpt(applicantID), stringOpt("reason", "too short"), stringOpt("reason_code", " low_effort ")))
+	if len(st.decisions) != 1 || st.decisions[0].reasonCode != "low_effort" || st.decisions[0].decision != ds.DecisionDeny {
+		t.Fatalf("decisions %+v", st.decisions)
+	}
+}
+
+func TestDecisionRequiresStaff(t *testing.T) {
+	b, s, st := newTestBot()
+	st.addApplicant(200, ds.StatusApplicant)
+	b.handleInteraction(command("accept", plainMember("300"), userOpt(applicantID), stringOpt("reason", "friend")))
+	if got := s.reply(); got != "Only staff can do that." {
+		t.Fatalf("reply %q", got)
+	}
+	if len(st.decisions) != 0 {
+		t.Fatalf("recorded %+v", st.decisions)
+	}
+}
+
+func TestDecisionWithoutApplication(t *testing.T) {
+	b, s, st := newTestBot()
+	b.handleInteraction(command("accept", staffMember(), userOpt(applicantID), stringOpt("reason", "ok")))
+	if got := s.reply(); got != "<@200> has no application." {
+		t.Fatalf("reply %q", got)
+	}
+	if len(st.decisions) != 0 {
+		t.Fatalf("recorded %+v", st.decisions)
+	}
+}
+
+func TestDecisionRefused(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"illegal transition", &ds.TransitionError{From: ds.StatusMember, To: ds.StatusInterviewPending}, "Cannot interview <@200>: "},
+		{"unknown reason code", ds.ErrRejectionReasonNotFound, "Cannot interview <@200>: rejection reason not found"},
+		{"database error", errors.New("connection reset"), "Something went wrong, please try again later."},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b, s, st := newTestBot()
+			st.addApplicant(200, ds.StatusMember)
+			st.err = c.err
+			b.handleInteraction(command("interview", staffMember(), userOpt(applicantID), stringOpt("reason", "ok")))
+			if got := s.reply(); !strings.HasPrefix(got, c.want) {
+				t.Fatalf("reply %q, want prefix %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestStaffFallsBackToManageServer(t *testing.T) {
+	b, _, _ := newTestBot()
+	b.staffRoles = nil
+	admin := &discordgo.Member{User: &discordgo.User{ID: staffID}, Permissions: discordgo.PermissionManageServer}
+	if !b.isStaff(command("accept", admin)) {
+		t.Fatal("Manage Server member is not staff")
+	}
+	if b.isStaff(command("accept", plainMember("300"))) {
+		t.Fatal("plain member is staff")
+	}
+	if b.isStaff(&discordgo.Interaction{Type: discordgo.InteractionApplicationCommand, User: &discordgo.User{ID: staffID}}) {
+		t.Fatal("DM interaction is staff")
+	}
+}
+
+func TestVote(t *testing.T) {
+	b, s, st := newTestBot()
+	st.addApplicant(200, ds.StatusApplicant)
+	st.vote = ds.VoteResult{Stage: ds.StatusApplicant, Tally: ds.VoteTally{Approve: 2, Deny: 1}}
+	b.handleInteraction(command("vote", staffMember(), userOpt(applicantID), stringOpt("vote", "approve"), stringOpt("comment", "nice build")))
+
+	if got := s.reply(); got != "Vote recorded for <@200> (applicant): 2 approve, 1 deny, 0 abstain." {
+		t.Fatalf("reply %q", got)
+	}
+	if len(st.votes) != 1 || st.votes[0] != (recordedVote{appID: "app-200", reviewer: "staff:mod", comment: "nice build", vote: ds.VoteApprove}) {
+		t.Fatalf("votes %+v", st.votes)
+	}
+}
+
+func TestVoteNeedsStaffName(t *testing.T) {
+	b, s, st := newTestBot()
+	b.staffNames = nil
+	st.addApplicant(200, ds.StatusApplicant)
+	b.handleInteraction(command("vote", staffMember(), userOpt(applicantID), stringOpt("vote", "approve")))
+	if got := s.reply(); !strings.Contains(got, "not linked to a staff name") {
+		t.Fatalf("reply %q", got)
+	}
+	if len(st.votes) != 0 {
+		t.Fatalf("votes %+v", st.votes)
+	}
+}
+
+func TestVoteDecides(t *testing.T) {
+	b, s, st := newTestBot()
+	st.addApplicant(200, ds.StatusApplicant)
+	d := ds.DecisionInterview
+	st.vote = ds.VoteResult{Stage: ds.StatusApplicant, Tally: ds.VoteTally{Approve: 3}, Decision: &d,
+		Application: &ds.Application{ID: "app-200", Status: ds.StatusInterviewPending}}
+	b.handleInteraction(command("vote", staffMember(), userOpt(applicantID), stringOpt("vote", "approve")))
+	if got := s.reply(); !strings.HasSuffix(got, "The vote passed: <@200> is now **interview_pending**.") {
+		t.Fatalf("reply %q", got)
+	}
+}
+
+func TestVoteClosed(t *testing.T) {
+	b, s, st := newTestBot()
+	st.addApplicant(200, ds.StatusMember)
+	st.err = ds.ErrVotingClosed
+	b.handleInteraction(command("vote", staffMember(), userOpt(applicantID), stringOpt("vote", "deny")))
+	if got := s.reply(); got != "Cannot vote on <@200>: application is not open for voting" {
+		t.Fatalf("reply %q", got)
+	}
+}
@@ -7,9 +7,14 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+
+	ds "tysmp/main_backend/database_service"
+	"tysmp/main_backend/envconfig"
+	"tysmp/main_backend/minecraft"
 )
 
 // GuildUser represents a concise view of a Discord user in a guild with their role IDs.
@@ -28,7 +33,7 @@ type GuildUsersResponse struct {
 }
 
 // getGuildUsers fetches guild members and returns simplified user objects with roles.
-func getGuildUsers(ctx context.Context, s *discordgo.Session, guildID string) ([]GuildUser, error) {
+func getGuildUsers(ctx context.Context, s botSession, guildID string) ([]GuildUser, error) {
 	// Discord limits list members; use pagination.
 	var all []GuildUser
 	var after string
@@ -66,6 +71,32 @@ func getGuildUsers(ctx context.Context, s *discordgo.Session, guildID string) ([
 	return all, nil
 }
 
+// parseIDSet splits a comma separated list (snowflakes, names) into a set.
+func parseIDSet(raw string) map[string]bool {
+	out := map[string]bool{}
+	for _, id := range strings.Split(raw, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			out[id] = true
+		}
+	}
+	return out
+}
+
+// parseStaffNames reads STAFF_DISCORD_IDS in the form "alice:123,bob:456",
+// using the staff names of STAFF_API_KEYS, into a Discord id to name map.
+func parseStaffNames(raw string) map[string]string {
+	out := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		name, id, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		name, id = strings.TrimSpace(name), strings.TrimSpace(id)
+		if !ok || name == "" || id == "" {
+			continue
+		}
+		out[id] = name
+	}
+	return out
+}
+
 func main() {
 	token := os.Getenv("DISCORD_BOT_TOKEN")
 	guildID := os.Getenv("DISCORD_GUILD_ID")
@@ -76,6 +107,16 @@ func main() {
 	if token == "" || guildID == "" {
 		log.Fatal("❌ DISCORD_BOT_TOKEN and DISCORD_GUILD_ID must be set")
 	}
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		log.Fatal("❌ DATABASE_URL must be set")
+	}
+
+	db, err := ds.Connect(context.Background(), dsn, 4)
+	if err != nil {
+		log.Fatalf("❌ db connect: %v", err)
+	}
+	defer db.Close()
 
 	session, err := discordgo.New("Bot " + token)
 	if err != nil {
@@ -88,6 +129,86 @@ func main() {
 	}
 	log.Println("Discord bot session established ✨")
 
+	votePolicy, err := ds.ParseVotePolicy(os.Getenv("VOTE_QUORUM"), os.Getenv("VOTE_THRESHOLD"))
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	b := &bot{
+		session:    session,
+		store:      db,
+		guildID:    guildID,
+		formURL:    os.Getenv("APPLY_FORM_URL"),
+		staffRoles: parseIDSet(os.Getenv("STAFF_ROLE_IDS")),
+		staffNames: parseStaffNames(os.Getenv("STAFF_DISCORD_IDS")),
+		votePolicy: votePolicy,
+	}
+	session.AddHandler(func(_ *discordgo.Session, ic *discordgo.InteractionCreate) {
+		b.handleInteraction(ic.Interaction)
+	})
+	if err := b.registerCommands(session.State.User.ID); err != nil {
+		log.Fatalf("❌ failed to register slash commands: %v", err)
+	}
+	log.Println("slash commands registered")
+
+	// Background workers read the event outbox, each from its own cursor
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var consumers []eventConsumer
+
+	statusRoles, err := parseStatusRoles(os.Getenv("STATUS_ROLE_IDS"))
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if len(statusRoles) > 0 {
+		rs := &roleSyncer{session: session, store: db, guildID: guildID, roles: statusRoles}
+		consumers = append(consumers, eventConsumer{name: "discordbot:rolesync", handle: rs.handleEvent})
+		go rs.runReconcile(workerCtx, envconfig.Duration("ROLE_SYNC_INTERVAL", 15*time.Minute))
+		log.Printf("role sync enabled for %d statuses", len(statusRoles))
+	} else {
+		log.Println("role sync disabled (STATUS_ROLE_IDS not set)")
+	}
+
+	// Whitelist sync and ban enforcement share one console connection
+	var console *rconConsole
+	if addr := os.Getenv("RCON_ADDR"); addr != "" {
+		console = &rconConsole{dial: rconDialer(addr, os.Getenv("RCON_PASSWORD"))}
+		ignore := map[string]bool{}
+		for name := range parseIDSet(os.Getenv("WHITELIST_IGNORE")) {
+			ignore[strings.ToLower(name)] = true
+		}
+		ws := &whitelistSyncer{store: db, console: console, ignore: ignore}
+		consumers = append(consumers, eventConsumer{name: "discordbot:whitelist", handle: ws.handleEvent})
+		go ws.runReconcile(workerCtx, envconfig.Duration("WHITELIST_SYNC_INTERVAL", 10*time.Minute))
+		log.Printf("whitelist sync enabled against %s", addr)
+	} else {
+		log.Println("whitelist sync disabled (RCON_ADDR not set)")
+	}
+
+	if os.Getenv("BAN_ENFORCEMENT") != "off" {
+		be := &banEnforcer{session: session, store: db, guildID: guildID, console: console}
+		// offline-mode UUIDs cannot be looked up; there the stored name is the player
+		if os.Getenv("MINECRAFT_PROFILE_RESOLVER") != "offline" {
+			be.profiles = minecraft.NewCachedResolver(minecraft.NewMojangResolver(), time.Hour, 5*time.Minute, minecraft.DefaultCacheSize)
+		}
+		consumers = append(consumers, eventConsumer{name: "discordbot:bans", handle: be.handleEvent})
+		go be.run(workerCtx, envconfig.Duration("BAN_ENFORCEMENT_RETRY_INTERVAL", time.Minute))
+		log.Printf("ban enforcement enabled for %v", be.targets())
+	} else {
+		log.Println("ban enforcement disabled (BAN_ENFORCEMENT=off)")
+	}
+
+	// DM applicants on every status change, mentioning them in
+	// NOTIFY_FALLBACK_CHANNEL_ID when their DMs are closed
+	notifier := &applicantNotifier{session: session, store: db, fallbackChannel: os.Getenv("NOTIFY_FALLBACK_CHANNEL_ID")}
+	consumers = append(consumers, eventConsumer{name: "discordbot:notify", handle: notifier.handleEvent})
+
+	interviews := &interviewNotifier{session: session, store: db, lead: envconfig.Duration("INTERVIEW_REMINDER_LEAD", time.Hour)}
+	go interviews.run(workerCtx, envconfig.Duration("INTERVIEW_NOTIFY_INTERVAL", time.Minute))
+
+	if len(consumers) > 0 {
+		go runEventLoop(workerCtx, db, consumers...)
+	}
+
 	// Very small HTTP API: GET /users returns current guild users with roles
 	mux := http.NewServeMux()
 	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ds "tysmp/main_backend/database_service"
+	"tysmp/main_backend/minecraft"
+)
+
+// discordMaxTimeout is the longest timeout Discord accepts; longer bans use a
+// guild ban instead.
+const discordMaxTimeout = 28 * 24 * time.Hour
+
+// enforcementRetryPolicy retries a target for about two hours before it is
+// left failed for staff to retry.
+var enforcementRetryPolicy = ds.RetryPolicy{MaxAttempts: 8, Base: 30 * time.Second, Max: 30 * time.Minute}
+
+// enforcementStore is the slice of the database layer used by ban enforcement.
+type enforcementStore interface {
+	PlanBanEnforcement(ctx context.Context, userID string, banned bool, targets []string) error
+	RequeueSkippedEnforcements(ctx context.Context, userID string) error
+	GetActiveBan(ctx context.Context, userID string) (*ds.Ban, error)
+	ListDueEnforcements(ctx context.Context, limit int) ([]ds.BanEnforcement, error)
+	RecordEnforcement(ctx context.Context, e ds.BanEnforcement, res ds.EnforcementResult, policy ds.RetryPolicy) (string, error)
+}
+
+// banEnforcer carries bans out to the guild and, when RCON is configured, the
+// Minecraft server. Status changes only plan the work in ban_enforcements;
+// each target is then applied and retried on its own, so a server that is
+// down does not hold up the Discord ban.
+type banEnforcer struct {
+	session botSession
+	store   enforcementStore
+	guildID string
+	// console is nil when RCON is not configured
+	console *rconConsole
+	// profiles looks up a player's current name by UUID, since the console
+	// only bans by name; nil in offline mode, where the name is the identity
+	profiles minecraft.ProfileResolver
+
+	// mu keeps the event handler and the retry loop from applying the same row twice
+	mu sync.Mutex
+}
+
+func (b *banEnforcer) targets() []string {
+	if b.console == nil {
+		return []string{ds.EnforceDiscord}
+	}
+	return []string{ds.EnforceDiscord, ds.EnforceMinecraft}
+}
+
+// handleEvent plans enforcement for application status changes and bans, and
+// applies it right away. Failed targets are retried from the table, not the
+// outbox. A user linking a Minecraft account gets the bans that were skipped
+// for want of one queued again.
+func (b *banEnforcer) handleEvent(ctx context.Context, ev ds.AppEvent) error {
+	switch {
+	case ev.Table == "applications" && ev.UserID != nil:
+		banned := ev.Action != "DELETE" && ev.Status != nil && *ev.Status == ds.StatusBanned
+		if err := b.store.PlanBanEnforcement(ctx, *ev.UserID, banned, b.targets()); err != nil {
+			return fmt.Errorf("bans: plan for %s: %w", *ev.UserID, err)
+		}
+	case ev.Table == "bans" && ev.UserID != nil:
+		// users banned before applying have no application events
+		ban, err := b.store.GetActiveBan(ctx, *ev.UserID)
+		if err != nil {
+			return fmt.Errorf("bans: load ban of %s: %w", *ev.UserID, err)
+		}
+		if err := b.store.PlanBanEnforcement(ctx, *ev.UserID, ban != nil, b.targets()); err != nil {
+			return fmt.Errorf("bans: plan for %s: %w", *ev.UserID, err)
+		}
+	case ev.Table == "users" && ev.Action != "DELETE" && b.console != nil:
+		if err := b.store.RequeueSkippedEnforcements(ctx, ev.RowID); err != nil {
+			return fmt.Errorf("bans: requeue for %s: %w", ev.RowID, err)
+		}
+	default:
+		return nil
+	}
+	b.applyDue(ctx)
+	return nil
+}
+
+// applyDue works through every enforcement that is due.
+func (b *banEnforcer) applyDue(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	due, err := b.store.ListDueEnforcements(ctx, 50)
+	if err != nil {
+		log.Printf("bans: list due: %v", err)
+		return
+	}
+	for _, e := range due {
+		cctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		res := b.apply(cctx, e)
+		state, err := b.store.RecordEnforcement(cctx, e, res, enforcementRetryPolicy)
+		cancel()
+		switch {
+		case err != nil:
+			log.Printf("bans: record %s %s of %s: %v", e.Target, e.Action, e.UserID, err)
+		case state == ds.EnforcementFailed:
+			log.Printf("bans: giving up on %s %s of %s: %v", e.Target, e.Action, e.UserID, res.Err)
+		case res.Err != nil:
+			log.Printf("bans: %s %s of %s failed, will retry: %v", e.Target, e.Action, e.UserID, res.Err)
+		}
+	}
+}
+
+func (b *banEnforcer) apply(ctx context.Context, e ds.BanEnforcement) ds.EnforcementResult {
+	switch e.Target {
+	case ds.EnforceDiscord:
+		return b.applyDiscord(e)
+	case ds.EnforceMinecraft:
+		if b.console == nil {
+			return ds.EnforcementResult{Skipped: true, Detail: "RCON not configured"}
+		}
+		return b.applyMinecraft(ctx, e)
+	}
+	return ds.EnforcementResult{Err: fmt.Errorf("unknown target %q", e.Target)}
+}
+
+// banReason is the reason shown in the guild audit log and to the player.
+func banReason(e ds.BanEnforcement) string {
+	reason := "banned from TYSMP"
+	if e.BanReason != nil {
+		reason += ": " + *e.BanReason
+	}
+	// console commands are one line and Discord caps audit log reasons
+	reason = strings.Join(strings.Fields(reason), " ")
+	if len(reason) > 200 {
+		reason = reason[:200]
+	}
+	return reason
+}
+
+func (b *banEnforcer) applyDiscord(e ds.BanEnforcement) ds.EnforcementResult {
+	id := strconv.FormatInt(e.DiscordUserID, 10)
+	res := ds.EnforcementResult{Ref: id}
+
+	if e.Action == ds.EnforceUnban {
+		// lift whichever of the two was used; either may be gone already
+		if err := b.session.GuildBanDelete(b.guildID, id); err != nil && !isNotFound(err) {
+			res.Err = err
+			return res
+		}
+		if err := b.session.GuildMemberTimeout(b.guildID, id, nil); err != nil && !isNotFound(err) {
+			res.Err = err
+			return res
+		}
+		res.Detail = "guild ban and timeout removed"
+		return res
+	}
+
+	if until, ok := e.TimeoutUntil(time.Now(), discordMaxTimeout); ok {
+		err := b.session.GuildMemberTimeout(b.guildID, id, &until)
+		if isNotFound(err) {
+			return ds.EnforcementResult{Ref: id, Skipped: true, Detail: "not a guild member"}
+		}
+		res.Err = err
+		res.Detail = "timed out until " + until.UTC().Format(time.RFC3339)
+		return res
+	}
+	res.Err = b.session.GuildBanCreateWithReason(b.guildID, id, banReason(e), 0)
+	res.Detail = "guild ban"
+	return res
+}
+
+// playerName returns the name the console knows the player by now. Bans are
+// keyed on the UUID, so a name stored before a rename is replaced by the one
+// the profile service reports.
+func (b *banEnforcer) playerName(ctx context.Context, e ds.BanEnforcement) (string, error) {
+	if e.MinecraftUUID == nil || b.profiles == nil {
+		if e.MinecraftName == nil {
+			return "", nil
+		}
+		return *e.MinecraftName, nil
+	}
+	p, err := b.profiles.ByUUID(ctx, *e.MinecraftUUID)
+	switch {
+	case err == nil:
+		return p.Name, nil
+	case errors.Is(err, minecraft.ErrProfileNotFound):
+		// a deleted account cannot join; its last known name is all there is
+		if e.MinecraftName == nil {
+			return "", nil
+		}
+		return *e.MinecraftName, nil
+	}
+	return "", fmt.Errorf("resolve %s: %w", *e.MinecraftUUID, err)
+}
+
+func (b *banEnforcer) applyMinecraft(ctx context.Context, e ds.BanEnforcement) ds.EnforcementResult {
+	name, err := b.playerName(ctx, e)
+	if err != nil {
+		return ds.EnforcementResult{Err: err}
+	}
+	if !minecraft.ValidName(name) {
+		return ds.EnforcementResult{Skipped: true, Detail: "no linked Minecraft account"}
+	}
+	res := ds.EnforcementResult{Ref: name}
+	if e.MinecraftUUID != nil {
+		res.Detail = "uuid " + *e.MinecraftUUID + ", "
+	}
+
+	if e.Action == ds.EnforceUnban {
+		// the server keeps bans by UUID, so pardoning the current name also
+		// covers a player who was renamed while banned
+		out, err := b.console.command(ctx, "pardon "+name)
+		res.Err, res.Detail = err, res.Detail+strings.TrimSpace(stripFormatting(out))
+		return res
+	}
+	reason := banReason(e)
+	if _, err := b.console.command(ctx, "kick "+name+" "+reason); err != nil {
+		res.Err = err
+		return res
+	}
+	out, err := b.console.command(ctx, "ban "+name+" "+reason)
+	res.Err, res.Detail = err, res.Detail+strings.TrimSpace(stripFormatting(out))
+	return res
+}
+
+// run retries due enforcements every interval until ctx ends.
+func (b *banEnforcer) run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		b.applyDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
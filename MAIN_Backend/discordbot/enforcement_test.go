@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	ds "tysmp/main_backend/database_service"
+	"tysmp/main_backend/minecraft"
+)
+
+// fakeEnforcementStore is an in-memory enforcementStore. Due enforcements are
+// handed out once and their results recorded.
+type fakeEnforcementStore struct {
+	due       []ds.BanEnforcement
+	results   []ds.EnforcementResult
+	planned   map[string]bool
+	requeued  []string
+	activeBan *ds.Ban
+}
+
+func (f *fakeEnforcementStore) PlanBanEnforcement(ctx context.Context, userID string, banned bool, targets []string) error {
+	if f.planned == nil {
+		f.planned = map[string]bool{}
+	}
+	f.planned[userID] = banned
+	return nil
+}
+
+func (f *fakeEnforcementStore) RequeueSkippedEnforcements(ctx context.Context, userID string) error {
+	f.requeued = append(f.requeued, userID)
+	return nil
+}
+
+func (f *fakeEnforcementStore) GetActiveBan(ctx context.Context, userID string) (*ds.Ban, error) {
+	return f.activeBan, nil
+}
+
+func (f *fakeEnforcementStore) ListDueEnforcements(ctx context.Context, limit int) ([]ds.BanEnforcement, error) {
+	due := f.due
+	f.due = nil
+	return due, nil
+}
+
+func (f *fakeEnforcementStore) RecordEnforcement(ctx context.Context, e ds.BanEnforcement, res ds.EnforcementResult, policy ds.RetryPolicy) (string, error) {
+	f.results = append(f.results, res)
+	if res.Skipped {
+		return ds.EnforcementSkipped, nil
+	}
+	if res.Err != nil {
+		return ds.EnforcementPending, nil
+	}
+	return ds.EnforcementApplied, nil
+}
+
+// fakeProfiles resolves UUIDs from a map; err, when set, fails every lookup.
+type fakeProfiles struct {
+	names map[string]string
+	err   error
+}
+
+func (f fakeProfiles) ByName(ctx context.Context, name string) (minecraft.Profile, error) {
+	return minecraft.Profile{}, minecraft.ErrProfileNotFound
+}
+
+func (f fakeProfiles) ByUUID(ctx context.Context, uuid string) (minecraft.Profile, error) {
+	if f.err != nil {
+		return minecraft.Profile{}, f.err
+	}
+	name, ok := f.names[uuid]
+	if !ok {
+		return minecraft.Profile{}, minecraft.ErrProfileNotFound
+	}
+	return minecraft.Profile{UUID: uuid, Name: name}, nil
+}
+
+const playerUUID = "00000000-0000-0000-0000-000000000001"
+
+func minecraftBan(name string) ds.BanEnforcement {
+	uuid, reason := playerUUID, "griefing"
+	return ds.BanEnforcement{ID: 1, UserID: "u1", Target: ds.EnforceMinecraft, Action: ds.EnforceBan,
+		MinecraftName: &name, MinecraftUUID: &uuid, BanReason: &reason}
+}
+
+func TestMinecraftBanUsesCurrentName(t *testing.T) {
+	mc := newFakeMinecraft()
+	srv, console := startMinecraft(t, mc)
+	store := &fakeEnforcementStore{due: []ds.BanEnforcement{minecraftBan("Alice")}}
+	b := &banEnforcer{store: store, console: console, profiles: fakeProfiles{names: map[string]string{playerUUID: "Alicia"}}}
+
+	b.applyDue(context.Background())
+	if got := strings.Join(srv.Commands(), ";"); got != "kick Alicia banned from TYSMP: griefing;ban Alicia banned from TYSMP: griefing" {
+		t.Fatalf("commands %q", got)
+	}
+	if len(store.results) != 1 || store.results[0].Err != nil || store.results[0].Ref != "Alicia" {
+		t.Fatalf("results %+v", store.results)
+	}
+}
+
+func TestMinecraftBanWaitsForProfileLookup(t *testing.T) {
+	mc := newFakeMinecraft()
+	srv, console := startMinecraft(t, mc)
+	store := &fakeEnforcementStore{due: []ds.BanEnforcement{minecraftBan("Alice")}}
+	b := &banEnforcer{store: store, console: console, profiles: fakeProfiles{err: errors.New("503 from profile service")}}
+
+	b.applyDue(context.Background())
+	if n := len(srv.Commands()); n != 0 {
+		t.Fatalf("banned by a possibly stale name: %v", srv.Commands())
+	}
+	if len(store.results) != 1 || store.results[0].Err == nil || store.results[0].Skipped {
+		t.Fatalf("results %+v", store.results)
+	}
+}
+
+func TestLinkingAccountRequeuesSkippedBan(t *testing.T) {
+	mc := newFakeMinecraft()
+	_, console := startMinecraft(t, mc)
+	store := &fakeEnforcementStore{}
+	b := &banEnforcer{store: store, console: console}
+
+	if err := b.handleEvent(context.Background(), userEvent("u1")); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.requeued) != 1 || store.requeued[0] != "u1" {
+		t.Fatalf("requeued %v", store.requeued)
+	}
+}
+
+func TestBanWithoutApplicationIsPlanned(t *testing.T) {
+	store := &fakeEnforcementStore{activeBan: &ds.Ban{ID: "ban-1", UserID: "u1"}}
+	b := &banEnforcer{store: store}
+	userID := "u1"
+
+	if err := b.handleEvent(context.Background(), ds.AppEvent{Table: "bans", Action: "INSERT", RowID: "ban-1", UserID: &userID}); err != nil {
+		t.Fatal(err)
+	}
+	if banned, ok := store.planned["u1"]; !ok || !banned {
+		t.Fatalf("planned %v", store.planned)
+	}
+}
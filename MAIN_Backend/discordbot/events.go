@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+// eventPollInterval bounds how long a consumer waits without a wake-up, so
+// retries come due and a broken LISTEN connection only delays delivery.
+const eventPollInterval = 30 * time.Second
+
+// eventHandler reacts to a single app event. A returned error schedules a
+// retry; events may be delivered more than once, so handlers must be idempotent.
+type eventHandler func(ctx context.Context, ev ds.AppEvent) error
+
+// eventConsumer is a named outbox reader with its own cursor.
+type eventConsumer struct {
+	name   string
+	handle eventHandler
+}
+
+// runEventLoop delivers outbox events to every consumer. The consumers share
+// one app_events subscription, which only serves as a wake-up; each of them
+// reads the outbox from its own cursor and never blocks the others.
+func runEventLoop(ctx context.Context, db *ds.DB, consumers ...eventConsumer) {
+	wakes := make([]chan struct{}, len(consumers))
+	for i, c := range consumers {
+		wakes[i] = make(chan struct{}, 1)
+		go runConsumer(ctx, db, c, wakes[i])
+	}
+
+	backoff := time.Second
+	for {
+		wake, errs, err := db.ListenOutbox(ctx)
+		if err != nil {
+			log.Printf("app_events subscribe: %v", err)
+		} else {
+			backoff = time.Second
+			drainWakeups(wake, errs, wakes)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+func drainWakeups(wake <-chan struct{}, errs <-chan error, wakes []chan struct{}) {
+	for {
+		select {
+		case _, ok := <-wake:
+			if !ok {
+				return
+			}
+			for _, w := range wakes {
+				select {
+				case w <- struct{}{}:
+				default:
+				}
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			log.Printf("app_events: %v", err)
+		}
+	}
+}
+
+func runConsumer(ctx context.Context, db *ds.DB, c eventConsumer, wake <-chan struct{}) {
+	handle := func(ctx context.Context, ev ds.AppEvent) error {
+		err := c.handle(ctx, ev)
+		if err != nil {
+			log.Printf("%s: event %d: %v", c.name, ev.ID, err)
+		}
+		return err
+	}
+	t := time.NewTicker(eventPollInterval)
+	defer t.Stop()
+	for {
+		if err := db.DrainEvents(ctx, c.name, ds.DefaultRetryPolicy, handle); err != nil && ctx.Err() == nil {
+			log.Printf("%s: outbox: %v", c.name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+// interviewStore is the slice of the database layer used by the interview notifier.
+type interviewStore interface {
+	ListInterviewsToNotify(ctx context.Context) ([]ds.Interview, error)
+	ListInterviewsToRemind(ctx context.Context, lead time.Duration) ([]ds.Interview, error)
+	MarkInterviewNotified(ctx context.Context, interviewID string) error
+	MarkInterviewReminded(ctx context.Context, interviewID string) error
+}
+
+// interviewNotifier DMs the applicant and the interviewer when an interview is
+// booked, and again shortly before it starts.
+type interviewNotifier struct {
+	session botSession
+	store   interviewStore
+	// lead is how long before the start the reminder goes out
+	lead time.Duration
+}
+
+// discordTime renders t as a Discord timestamp, shown in each reader's time zone.
+func discordTime(t time.Time, style string) string {
+	return "<t:" + strconv.FormatInt(t.Unix(), 10) + ":" + style + ">"
+}
+
+// notify sends content to the applicant and, when known, the interviewer. It
+// reports whether the interview can be marked as handled: either the applicant
+// got the message or Discord refused it for good (DMs closed, user gone).
+func (n *interviewNotifier) notify(iv ds.Interview, applicantMsg string, interviewerMsg string) bool {
+	err := sendDM(n.session, strconv.FormatInt(iv.DiscordUserID, 10), applicantMsg)
+	if err != nil {
+		log.Printf("interviews: DM applicant of %s: %v", iv.ID, err)
+	}
+	if id := iv.Slot.InterviewerDiscordID; id != nil {
+		if err := sendDM(n.session, strconv.FormatInt(*id, 10), interviewerMsg); err != nil {
+			log.Printf("interviews: DM interviewer of %s: %v", iv.ID, err)
+		}
+	}
+	return err == nil || undeliverable(err)
+}
+
+// undeliverable reports whether a DM failed in a way retrying will not fix.
+func undeliverable(err error) bool {
+	var rerr *discordgo.RESTError
+	if !errors.As(err, &rerr) || rerr.Response == nil {
+		return false
+	}
+	return rerr.Response.StatusCode == http.StatusForbidden || rerr.Response.StatusCode == http.StatusNotFound
+}
+
+// tick sends every confirmation and reminder that is due.
+func (n *interviewNotifier) tick(ctx context.Context) error {
+	booked, err := n.store.ListInterviewsToNotify(ctx)
+	if err != nil {
+		return err
+	}
+	for _, iv := range booked {
+		start := iv.Slot.StartsAt
+		if !n.notify(iv,
+			fmt.Sprintf("📅 Your TYSMP interview is booked for %s (%s). You will get a reminder before it starts.", discordTime(start, "F"), discordTime(start, "R")),
+			fmt.Sprintf("📅 <@%d> booked your interview slot at %s.", iv.DiscordUserID, discordTime(start, "F")),
+		) {
+			continue
+		}
+		if err := n.store.MarkInterviewNotified(ctx, iv.ID); err != nil {
+			return err
+		}
+	}
+
+	due, err := n.store.ListInterviewsToRemind(ctx, n.lead)
+	if err != nil {
+		return err
+	}
+	for _, iv := range due {
+		start := iv.Slot.StartsAt
+		if !n.notify(iv,
+			fmt.Sprintf("⏰ Reminder: your TYSMP interview starts %s.", discordTime(start, "R")),
+			fmt.Sprintf("⏰ Reminder: your interview with <@%d> starts %s.", iv.DiscordUserID, discordTime(start, "R")),
+		) {
+			continue
+		}
+		if err := n.store.MarkInterviewReminded(ctx, iv.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run checks for due messages every interval until ctx ends.
+func (n *interviewNotifier) run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := n.tick(ctx); err != nil {
+			log.Printf("interviews: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+// notificationStore is the slice of the database layer used by the applicant notifier.
+type notificationStore interface {
+	PrepareNotification(ctx context.Context, ev ds.AppEvent) (*ds.Notification, error)
+	RecordNotification(ctx context.Context, id int64, state string, via string, sendErr error) error
+}
+
+// applicantNotifier DMs applicants the template for each status their
+// application reaches. When their DMs are closed it mentions them in
+// fallbackChannel instead, without the message itself since that channel is
+// not private.
+type applicantNotifier struct {
+	session botSession
+	store   notificationStore
+	// fallbackChannel is empty when there is no fallback
+	fallbackChannel string
+}
+
+func (n *applicantNotifier) handleEvent(ctx context.Context, ev ds.AppEvent) error {
+	note, err := n.store.PrepareNotification(ctx, ev)
+	if err != nil {
+		return fmt.Errorf("notify: prepare for event %d: %w", ev.ID, err)
+	}
+	if note == nil || note.State == ds.NotifySent || note.State == ds.NotifyFallback {
+		return nil
+	}
+
+	state, via := ds.NotifySent, ds.NotifyViaDM
+	dmErr := sendDM(n.session, strconv.FormatInt(note.DiscordUserID, 10), note.Message)
+	switch {
+	case dmErr == nil:
+	case !undeliverable(dmErr):
+		// the outbox redelivers the event and the DM is tried again
+		state, via = ds.NotifyPending, ""
+	case n.fallbackChannel == "":
+		state, via = ds.NotifyFailed, ""
+	default:
+		state, via = ds.NotifyFallback, ds.NotifyViaChannel
+		content := fmt.Sprintf("<@%d> there is news about your TYSMP application, but your DMs are closed. Allow DMs from server members or use /status to see it.", note.DiscordUserID)
+		if _, err := n.session.ChannelMessageSend(n.fallbackChannel, content); err != nil {
+			log.Printf("notify: fallback for notification %d: %v", note.ID, err)
+			state, via = ds.NotifyFailed, ""
+		}
+	}
+
+	if err := n.store.RecordNotification(ctx, note.ID, state, via, dmErr); err != nil {
+		return fmt.Errorf("notify: record notification %d: %w", note.ID, err)
+	}
+	if state == ds.NotifyPending {
+		return fmt.Errorf("notify: DM for notification %d: %w", note.ID, dmErr)
+	}
+	return nil
+}
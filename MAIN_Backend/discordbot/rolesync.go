@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+// roleStore is the slice of the database layer used by role sync.
+type roleStore interface {
+	GetUserByID(ctx context.Context, userID string) (*ds.User, error)
+	GetApplicationByUser(ctx context.Context, userID string) (*ds.Application, error)
+	ListUserStatuses(ctx context.Context) ([]ds.UserStatus, error)
+}
+
+// roleSyncer keeps guild roles in line with application statuses.
+type roleSyncer struct {
+	session botSession
+	store   roleStore
+	guildID string
+	// roles maps each status to the guild role granted for it
+	roles map[ds.Status]string
+}
+
+// parseStatusRoles reads STATUS_ROLE_IDS in the form "member:123,banned:456,denied:789".
+func parseStatusRoles(raw string) (map[ds.Status]string, error) {
+	out := map[ds.Status]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		status, roleID, ok := strings.Cut(pair, ":")
+		if !ok || roleID == "" {
+			return nil, errors.New("malformed STATUS_ROLE_IDS entry " + strconv.Quote(pair))
+		}
+		if !ds.Status(status).Valid() {
+			return nil, errors.New("unknown status " + strconv.Quote(status) + " in STATUS_ROLE_IDS")
+		}
+		out[ds.Status(status)] = roleID
+	}
+	return out, nil
+}
+
+// desiredRole returns the managed role a member should hold, or "" for none.
+func (r *roleSyncer) desiredRole(status *ds.Status) string {
+	if status == nil {
+		return ""
+	}
+	return r.roles[*status]
+}
+
+// syncMember adds the role for status and removes every other managed role.
+// current is the member's role list; pass nil to fetch it from Discord.
+func (r *roleSyncer) syncMember(discordID string, status *ds.Status, current []string) error {
+	if current == nil {
+		m, err := r.session.GuildMember(r.guildID, discordID)
+		if err != nil {
+			if isNotFound(err) {
+				// not in the guild (yet); reconcile will catch them when they join
+				return nil
+			}
+			return err
+		}
+		current = m.Roles
+	}
+	has := map[string]bool{}
+	for _, id := range current {
+		has[id] = true
+	}
+
+	want := r.desiredRole(status)
+	for _, roleID := range r.roles {
+		switch {
+		case roleID == want && !has[roleID]:
+			if err := r.session.GuildMemberRoleAdd(r.guildID, discordID, roleID); err != nil {
+				return err
+			}
+		case roleID != want && has[roleID]:
+			if err := r.session.GuildMemberRoleRemove(r.guildID, discordID, roleID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// handleEvent applies role changes for application status events. Events can
+// be redelivered out of order, so the status comes from the user's current
+// application rather than the event.
+func (r *roleSyncer) handleEvent(ctx context.Context, ev ds.AppEvent) error {
+	if ev.Table != "applications" || ev.UserID == nil {
+		return nil
+	}
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	user, err := r.store.GetUserByID(cctx, *ev.UserID)
+	if err != nil {
+		return fmt.Errorf("role sync: load user %s: %w", *ev.UserID, err)
+	}
+	if user == nil {
+		return nil
+	}
+	app, err := r.store.GetApplicationByUser(cctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("role sync: load application for %s: %w", user.ID, err)
+	}
+	var status *ds.Status
+	if app != nil {
+		status = &app.Status
+	}
+	if err := r.syncMember(strconv.FormatInt(user.DiscordUserID, 10), status, nil); err != nil {
+		return fmt.Errorf("role sync: member %d: %w", user.DiscordUserID, err)
+	}
+	return nil
+}
+
+// reconcile compares every guild member's roles with the database and repairs drift.
+func (r *roleSyncer) reconcile(ctx context.Context) error {
+	members, err := getGuildUsers(ctx, r.session, r.guildID)
+	if err != nil {
+		return err
+	}
+	statuses, err := r.store.ListUserStatuses(ctx)
+	if err != nil {
+		return err
+	}
+	byDiscord := make(map[string]*ds.Status, len(statuses))
+	for _, s := range statuses {
+		byDiscord[strconv.FormatInt(s.DiscordUserID, 10)] = s.Status
+	}
+
+	failed := 0
+	for _, m := range members {
+		if err := r.syncMember(m.ID, byDiscord[m.ID], m.Roles); err != nil {
+			failed++
+			log.Printf("role sync: reconcile member %s: %v", m.ID, err)
+		}
+	}
+	log.Printf("role sync: reconciled %d members (%d failed)", len(members), failed)
+	return nil
+}
+
+// runReconcile reconciles immediately and then every interval until ctx ends.
+func (r *roleSyncer) runReconcile(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := r.reconcile(ctx); err != nil {
+			log.Printf("role sync: reconcile: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// isNotFound reports whether a Discord REST error is a 404.
+func isNotFound(err error) bool {
+	var rerr *discordgo.RESTError
+	return errors.As(err, &rerr) && rerr.Response != nil && rerr.Response.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+func TestRoleSyncIgnoresStaleStatus(t *testing.T) {
+	session := newFakeSession()
+	session.members["42"] = plainMember("42")
+	store := newFakeWhitelistStore()
+	store.put("u1", "Alice", "", ds.StatusBanned).DiscordUserID = 42
+	r := &roleSyncer{session: session, store: store, guildID: "guild",
+		roles: map[ds.Status]string{ds.StatusMember: "member-role", ds.StatusBanned: "banned-role"}}
+
+	ctx := context.Background()
+	// a retried member event delivered after the ban
+	for _, status := range []ds.Status{ds.StatusBanned, ds.StatusMember} {
+		if err := r.handleEvent(ctx, statusEvent("u1", status)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, add := range session.roleAdds {
+		if add != "42:banned-role" {
+			t.Fatalf("role adds %v", session.roleAdds)
+		}
+	}
+	if len(session.roleAdds) == 0 {
+		t.Fatal("banned role never added")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// fakeSession is an in-memory botSession that records every call.
+type fakeSession struct {
+	mu sync.Mutex
+
+	members map[string]*discordgo.Member
+	// dmErr is returned when opening a DM with the user
+	dmErr map[string]error
+
+	responses []*discordgo.InteractionResponse
+	edits     []string
+	dms       map[string][]string
+	channel   map[string][]string
+	roleAdds  []string
+	roleDrops []string
+	bans      map[string]string
+	unbans    []string
+	timeouts  map[string]*time.Time
+	commands  []*discordgo.ApplicationCommand
+}
+
+var _ botSession = (*fakeSession)(nil)
+
+func newFakeSession() *fakeSession {
+	return &fakeSession{
+		members:  map[string]*discordgo.Member{},
+		dmErr:    map[string]error{},
+		dms:      map[string][]string{},
+		channel:  map[string][]string{},
+		bans:     map[string]string{},
+		timeouts: map[string]*time.Time{},
+	}
+}
+
+// restError builds the error discordgo returns for a failed request.
+func restError(status int) error {
+	return &discordgo.RESTError{Response: &http.Response{StatusCode: status}}
+}
+
+func (f *fakeSession) GuildMembers(guildID string, after string, limit int, options ...discordgo.RequestOption) ([]*discordgo.Member, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []*discordgo.Member
+	for _, m := range f.members {
+		if m.User.ID > after {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeSession) GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, ok := f.members[userID]
+	if !ok {
+		return nil, restError(http.StatusNotFound)
+	}
+	return m, nil
+}
+
+func (f *fakeSession) GuildMemberRoleAdd(guildID, userID, roleID string, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.roleAdds = append(f.roleAdds, userID+":"+roleID)
+	return nil
+}
+
+func (f *fakeSession) GuildMemberRoleRemove(guildID, userID, roleID string, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.roleDrops = append(f.roleDrops, userID+":"+roleID)
+	return nil
+}
+
+func (f *fakeSession) GuildMemberTimeout(guildID string, userID string, until *time.Time, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.timeouts[userID] = until
+	return nil
+}
+
+func (f *fakeSession) GuildBanCreateWithReason(guildID, userID, reason string, days int, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bans[userID] = reason
+	return nil
+}
+
+func (f *fakeSession) GuildBanDelete(guildID, userID string, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.bans[userID]; !ok {
+		return restError(http.StatusNotFound)
+	}
+	delete(f.bans, userID)
+	f.unbans = append(f.unbans, userID)
+	return nil
+}
+
+func (f *fakeSession) ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = commands
+	return commands, nil
+}
+
+func (f *fakeSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, resp)
+	return nil
+}
+
+func (f *fakeSession) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if newresp.Content == nil {
+		return nil, errors.New("edit without content")
+	}
+	f.edits = append(f.edits, *newresp.Content)
+	return &discordgo.Message{Content: *newresp.Content}, nil
+}
+
+func (f *fakeSession) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.dmErr[recipientID]; err != nil {
+		return nil, err
+	}
+	return &discordgo.Channel{ID: "dm:" + recipientID, Type: discordgo.ChannelTypeDM}, nil
+}
+
+func (f *fakeSession) ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(channelID) > 3 && channelID[:3] == "dm:" {
+		f.dms[channelID[3:]] = append(f.dms[channelID[3:]], content)
+	} else {
+		f.channel[channelID] = append(f.channel[channelID], content)
+	}
+	return &discordgo.Message{ChannelID: channelID, Content: content}, nil
+}
+
+// reply returns the last interaction reply.
+func (f *fakeSession) reply() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.edits) == 0 {
+		return ""
+	}
+	return f.edits[len(f.edits)-1]
+}
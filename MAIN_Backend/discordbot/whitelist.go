@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	ds "tysmp/main_backend/database_service"
+	"tysmp/main_backend/minecraft"
+	"tysmp/main_backend/rcon"
+)
+
+// commander runs a console command on the Minecraft server.
+type commander interface {
+	Command(cmd string) (string, error)
+	Close() error
+}
+
+// whitelistStore is the slice of the database layer used by whitelist sync.
+type whitelistStore interface {
+	GetUserByID(ctx context.Context, userID string) (*ds.User, error)
+	GetApplicationByUser(ctx context.Context, userID string) (*ds.Application, error)
+	ListUserStatuses(ctx context.Context) ([]ds.UserStatus, error)
+	GetWhitelistedName(ctx context.Context, minecraftUUID string) (string, error)
+	SetWhitelistedName(ctx context.Context, minecraftUUID string, name string) error
+}
+
+// whitelistSyncer keeps the server whitelist equal to the set of members.
+// The console only knows names, so names are what gets sent, but players are
+// tracked by Minecraft UUID to survive renames: the store remembers the name
+// each UUID was last whitelisted under so a rename can drop the old entry.
+type whitelistSyncer struct {
+	store   whitelistStore
+	console *rconConsole
+	// ignore holds lower-cased names reconcile must never remove (staff alts, ops)
+	ignore map[string]bool
+}
+
+// rconDialer returns a dial func for rconConsole backed by the rcon package.
+func rconDialer(addr string, password string) func(ctx context.Context) (commander, error) {
+	return func(ctx context.Context) (commander, error) {
+		return rcon.Dial(ctx, addr, password, 10*time.Second)
+	}
+}
+
+// rconConsole is a shared connection to the server console. Workers that
+// talk to the server go through one console so commands never interleave.
+type rconConsole struct {
+	dial func(ctx context.Context) (commander, error)
+
+	mu   sync.Mutex
+	conn commander
+}
+
+// command runs cmd, dialing lazily and dropping the connection on failure so
+// the next call reconnects.
+func (c *rconConsole) command(ctx context.Context, cmd string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		conn, err := c.dial(ctx)
+		if err != nil {
+			return "", err
+		}
+		c.conn = conn
+	}
+	out, err := c.conn.Command(cmd)
+	if err != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+	return out, err
+}
+
+func (w *whitelistSyncer) command(ctx context.Context, cmd string) (string, error) {
+	return w.console.command(ctx, cmd)
+}
+
+func (w *whitelistSyncer) add(ctx context.Context, name string) error {
+	if !minecraft.ValidName(name) {
+		log.Printf("whitelist: refusing invalid name %q", name)
+		return nil
+	}
+	_, err := w.command(ctx, "whitelist add "+name)
+	return err
+}
+
+func (w *whitelistSyncer) remove(ctx context.Context, name string) error {
+	if !minecraft.ValidName(name) {
+		log.Printf("whitelist: refusing invalid name %q", name)
+		return nil
+	}
+	_, err := w.command(ctx, "whitelist remove "+name)
+	return err
+}
+
+// apply whitelists members and removes everyone else. The UUID, when known,
+// is the player's identity: if it was whitelisted under another name before,
+// that stale entry is removed.
+func (w *whitelistSyncer) apply(ctx context.Context, user *ds.User, status *ds.Status) error {
+	name := *user.MinecraftName
+	if user.MinecraftUUID != nil {
+		prev, err := w.store.GetWhitelistedName(ctx, *user.MinecraftUUID)
+		if err != nil {
+			return err
+		}
+		if prev != "" && !strings.EqualFold(prev, name) {
+			if err := w.remove(ctx, prev); err != nil {
+				return err
+			}
+			if err := w.store.SetWhitelistedName(ctx, *user.MinecraftUUID, ""); err != nil {
+				return err
+			}
+		}
+	}
+	if status != nil && *status == ds.StatusMember {
+		if err := w.add(ctx, name); err != nil {
+			return err
+		}
+		if user.MinecraftUUID != nil {
+			return w.store.SetWhitelistedName(ctx, *user.MinecraftUUID, name)
+		}
+		return nil
+	}
+	if err := w.remove(ctx, name); err != nil {
+		return err
+	}
+	if user.MinecraftUUID != nil {
+		return w.store.SetWhitelistedName(ctx, *user.MinecraftUUID, "")
+	}
+	return nil
+}
+
+// handleEvent reacts to status changes and Minecraft name changes.
+func (w *whitelistSyncer) handleEvent(ctx context.Context, ev ds.AppEvent) error {
+	cctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	var userID string
+	switch {
+	case ev.Table == "applications" && ev.UserID != nil:
+		userID = *ev.UserID
+	case ev.Table == "users":
+		userID = ev.RowID
+	default:
+		return nil
+	}
+	user, err := w.store.GetUserByID(cctx, userID)
+	if err != nil {
+		return fmt.Errorf("whitelist: load user %s: %w", userID, err)
+	}
+	if user == nil || user.MinecraftName == nil {
+		return nil
+	}
+
+	// events can be redelivered out of order, so only trust the current status
+	app, err := w.store.GetApplicationByUser(cctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("whitelist: load application for %s: %w", user.ID, err)
+	}
+	var status *ds.Status
+	switch {
+	case app != nil:
+		status = &app.Status
+	case ev.Table == "users":
+		// a rename of someone who never applied changes nothing
+		return nil
+	}
+	if err := w.apply(cctx, user, status); err != nil {
+		return fmt.Errorf("whitelist: sync %s: %w", *user.MinecraftName, err)
+	}
+	return nil
+}
+
+// parseWhitelist extracts names from `whitelist list` output, e.g.
+// "There are 2 whitelisted player(s): Alice, Bob".
+func parseWhitelist(out string) []string {
+	_, list, ok := strings.Cut(stripFormatting(out), ":")
+	if !ok {
+		// "There are no whitelisted players"
+		return nil
+	}
+	return strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' })
+}
+
+// stripFormatting removes Minecraft "§x" colour codes from console output.
+func stripFormatting(s string) string {
+	var b strings.Builder
+	skip := false
+	for _, r := range s {
+		if skip {
+			skip = false
+			continue
+		}
+		if r == '§' {
+			skip = true
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// reconcile diffs the live whitelist against all members.
+func (w *whitelistSyncer) reconcile(ctx context.Context) error {
+	out, err := w.command(ctx, "whitelist list")
+	if err != nil {
+		return err
+	}
+	current := map[string]string{}
+	for _, n := range parseWhitelist(out) {
+		current[strings.ToLower(n)] = n
+	}
+
+	statuses, err := w.store.ListUserStatuses(ctx)
+	if err != nil {
+		return err
+	}
+	want := map[string]string{}
+	for _, s := range statuses {
+		if s.MinecraftName != nil && s.Status != nil && *s.Status == ds.StatusMember {
+			want[strings.ToLower(*s.MinecraftName)] = *s.MinecraftName
+			if s.MinecraftUUID != nil {
+				if err := w.store.SetWhitelistedName(ctx, *s.MinecraftUUID, *s.MinecraftName); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	added, removed := 0, 0
+	for key, name := range want {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		if err := w.add(ctx, name); err != nil {
+			return err
+		}
+		added++
+	}
+	for key, name := range current {
+		if _, ok := want[key]; ok || w.ignore[key] {
+			continue
+		}
+		if err := w.remove(ctx, name); err != nil {
+			return err
+		}
+		removed++
+	}
+	log.Printf("whitelist: reconciled (%d added, %d removed)", added, removed)
+	return nil
+}
+
+// runReconcile reconciles immediately and then every interval until ctx ends.
+func (w *whitelistSyncer) runReconcile(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := w.reconcile(ctx); err != nil {
+			log.Printf("whitelist: reconcile: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	ds "tysmp/main_backend/database_service"
+	"tysmp/main_backend/rcon/rcontest"
+)
+
+// fakeMinecraft answers the console commands the bot sends, keeping a
+// whitelist and a ban list the way a vanilla server would.
+type fakeMinecraft struct {
+	mu        sync.Mutex
+	whitelist map[string]string
+	banned    map[string]string
+}
+
+func newFakeMinecraft(names ...string) *fakeMinecraft {
+	m := &fakeMinecraft{whitelist: map[string]string{}, banned: map[string]string{}}
+	for _, n := range names {
+		m.whitelist[strings.ToLower(n)] = n
+	}
+	return m
+}
+
+func (m *fakeMinecraft) handle(cmd string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	verb, arg, _ := strings.Cut(cmd, " ")
+	name, reason, _ := strings.Cut(arg, " ")
+	switch verb {
+	case "whitelist":
+		sub, name, _ := strings.Cut(arg, " ")
+		switch sub {
+		case "add":
+			m.whitelist[strings.ToLower(name)] = name
+			return "Added " + name + " to the whitelist"
+		case "remove":
+			delete(m.whitelist, strings.ToLower(name))
+			return "Removed " + name + " from the whitelist"
+		case "list":
+			names := m.names(m.whitelist)
+			if len(names) == 0 {
+				return "There are no whitelisted players"
+			}
+			return "There are " + strconv.Itoa(len(names)) + " whitelisted player(s): " + strings.Join(names, ", ")
+		}
+	case "ban":
+		m.banned[strings.ToLower(name)] = reason
+		return "Banned " + name + ": " + reason
+	case "pardon":
+		delete(m.banned, strings.ToLower(name))
+		return "Unbanned " + name
+	case "kick":
+		return "Kicked " + name
+	}
+	return "Unknown or incomplete command"
+}
+
+func (m *fakeMinecraft) names(set map[string]string) []string {
+	var out []string
+	for _, n := range set {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// whitelisted returns the whitelist sorted by name.
+func (m *fakeMinecraft) whitelisted() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.names(m.whitelist)
+}
+
+// startMinecraft serves mc over RCON and returns a console connected to it.
+func startMinecraft(t *testing.T, mc *fakeMinecraft) (*rcontest.Server, *rconConsole) {
+	t.Helper()
+	srv := rcontest.NewServer("pw", mc.handle)
+	t.Cleanup(srv.Close)
+	return srv, &rconConsole{dial: rconDialer(srv.Addr, "pw")}
+}
+
+// fakeWhitelistStore is an in-memory whitelistStore.
+type fakeWhitelistStore struct {
+	users       map[string]*ds.User
+	apps        map[string]*ds.Application
+	whitelisted map[string]string
+}
+
+func newFakeWhitelistStore() *fakeWhitelistStore {
+	return &fakeWhitelistStore{users: map[string]*ds.User{}, apps: map[string]*ds.Application{}, whitelisted: map[string]string{}}
+}
+
+// put stores a user with a Minecraft account and an application in status.
+func (f *fakeWhitelistStore) put(userID string, name string, uuid string, status ds.Status) *ds.User {
+	u := &ds.User{ID: userID, MinecraftName: &name}
+	if uuid != "" {
+		u.MinecraftUUID = &uuid
+	}
+	f.users[userID] = u
+	f.apps[userID] = &ds.Application{ID: "app-" + userID, UserID: userID, Status: status}
+	return u
+}
+
+func (f *fakeWhitelistStore) GetUserByID(ctx context.Context, userID string) (*ds.User, error) {
+	return f.users[userID], nil
+}
+
+func (f *fakeWhitelistStore) GetApplicationByUser(ctx context.Context, userID string) (*ds.Application, error) {
+	return f.apps[userID], nil
+}
+
+func (f *fakeWhitelistStore) ListUserStatuses(ctx context.Context) ([]ds.UserStatus, error) {
+	var out []ds.UserStatus
+	for id, u := range f.users {
+		s := ds.UserStatus{UserID: id, MinecraftName: u.MinecraftName, MinecraftUUID: u.MinecraftUUID}
+		if app := f.apps[id]; app != nil {
+			s.Status = &app.Status
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func (f *fakeWhitelistStore) GetWhitelistedName(ctx context.Context, minecraftUUID string) (string, error) {
+	return f.whitelisted[minecraftUUID], nil
+}
+
+func (f *fakeWhitelistStore) SetWhitelistedName(ctx context.Context, minecraftUUID string, name string) error {
+	if name == "" {
+		delete(f.whitelisted, minecraftUUID)
+	} else {
+		f.whitelisted[minecraftUUID] = name
+	}
+	return nil
+}
+
+func statusEvent(userID string, status ds.Status) ds.AppEvent {
+	return ds.AppEvent{Table: "applications", Action: "UPDATE", RowID: "app-" + userID, UserID: &userID, Status: &status}
+}
+
+func userEvent(userID string) ds.AppEvent {
+	return ds.AppEvent{Table: "users", Action: "UPDATE", RowID: userID}
+}
+
+func TestWhitelistAddsMembers(t *testing.T) {
+	mc := newFakeMinecraft()
+	srv, console := startMinecraft(t, mc)
+	store := newFakeWhitelistStore()
+	store.put("u1", "Alice", "", ds.StatusMember)
+	w := &whitelistSyncer{store: store, console: console}
+
+	if err := w.handleEvent(context.Background(), statusEvent("u1", ds.StatusMember)); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(srv.Commands(), ";"); got != "whitelist add Alice" {
+		t.Fatalf("commands %q", got)
+	}
+	if got := mc.whitelisted(); len(got) != 1 || got[0] != "Alice" {
+		t.Fatalf("whitelist %v", got)
+	}
+}
+
+func TestWhitelistRemovesNonMembers(t *testing.T) {
+	for _, status := range []ds.Status{ds.StatusBanned, ds.StatusDenied, ds.StatusApplicant} {
+		t.Run(string(status), func(t *testing.T) {
+			mc := newFakeMinecraft("Alice")
+			_, console := startMinecraft(t, mc)
+			store := newFakeWhitelistStore()
+			store.put("u1", "Alice", "", status)
+			w := &whitelistSyncer{store: store, console: console}
+
+			if err := w.handleEvent(context.Background(), statusEvent("u1", status)); err != nil {
+				t.Fatal(err)
+			}
+			if got := mc.whitelisted(); len(got) != 0 {
+				t.Fatalf("whitelist %v", got)
+			}
+		})
+	}
+}
+
+func TestWhitelistIgnoresStaleStatus(t *testing.T) {
+	mc := newFakeMinecraft("Alice")
+	_, console := startMinecraft(t, mc)
+	store := newFakeWhitelistStore()
+	store.put("u1", "Alice", "", ds.StatusBanned)
+	w := &whitelistSyncer{store: store, console: console}
+
+	ctx := context.Background()
+	// a retried member event delivered after the ban
+	for _, status := range []ds.Status{ds.StatusBanned, ds.StatusMember} {
+		if err := w.handleEvent(ctx, statusEvent("u1", status)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := mc.whitelisted(); len(got) != 0 {
+		t.Fatalf("whitelist %v", got)
+	}
+}
+
+func TestWhitelistFollowsRename(t *testing.T) {
+	mc := newFakeMinecraft()
+	_, console := startMinecraft(t, mc)
+	store := newFakeWhitelistStore()
+	u := store.put("u1", "Alice", "00000000-0000-0000-0000-000000000001", ds.StatusMember)
+	w := &whitelistSyncer{store: store, console: console}
+
+	ctx := context.Background()
+	if err := w.handleEvent(ctx, statusEvent("u1", ds.StatusMember)); err != nil {
+		t.Fatal(err)
+	}
+	renamed := "Alicia"
+	u.MinecraftName = &renamed
+	if err := w.handleEvent(ctx, userEvent("u1")); err != nil {
+		t.Fatal(err)
+	}
+	if got := mc.whitelisted(); len(got) != 1 || got[0] != "Alicia" {
+		t.Fatalf("whitelist %v", got)
+	}
+}
+
+func TestWhitelistRenameAfterRestart(t *testing.T) {
+	const uuid = "00000000-0000-0000-0000-000000000001"
+	mc := newFakeMinecraft("Alice")
+	_, console := startMinecraft(t, mc)
+	store := newFakeWhitelistStore()
+	store.put("u1", "Alicia", uuid, ds.StatusMember)
+	// whitelisted as Alice by a previous run of the bot
+	store.whitelisted[uuid] = "Alice"
+	w := &whitelistSyncer{store: store, console: console}
+
+	if err := w.handleEvent(context.Background(), userEvent("u1")); err != nil {
+		t.Fatal(err)
+	}
+	if got := mc.whitelisted(); len(got) != 1 || got[0] != "Alicia" {
+		t.Fatalf("whitelist %v", got)
+	}
+	if store.whitelisted[uuid] != "Alicia" {
+		t.Fatalf("recorded %q", store.whitelisted[uuid])
+	}
+}
+
+func TestWhitelistRefusesInvalidNames(t *testing.T) {
+	mc := newFakeMinecraft()
+	srv, console := startMinecraft(t, mc)
+	store := newFakeWhitelistStore()
+	store.put("u1", "Alice;op Mallory", "", ds.StatusMember)
+	w := &whitelistSyncer{store: store, console: console}
+
+	if err := w.handleEvent(context.Background(), statusEvent("u1", ds.StatusMember)); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Commands()); n != 0 {
+		t.Fatalf("sent %v", srv.Commands())
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	mc := newFakeMinecraft("Alice", "Mallory", "StaffAlt")
+	srv, console := startMinecraft(t, mc)
+	srv.MaxBody = 16
+	store := newFakeWhitelistStore()
+	store.put("u1", "alice", "", ds.StatusMember)
+	store.put("u2", "Bob", "", ds.StatusMember)
+	store.put("u3", "Carol", "", ds.StatusDenied)
+	w := &whitelistSyncer{store: store, console: console, ignore: map[string]bool{"staffalt": true}}
+
+	if err := w.reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(mc.whitelisted(), ","); got != "Alice,Bob,StaffAlt" {
+		t.Fatalf("whitelist %s", got)
+	}
+}
+
+func TestConsoleRedialsAfterDisconnect(t *testing.T) {
+	mc := newFakeMinecraft()
+	srv, console := startMinecraft(t, mc)
+	ctx := context.Background()
+
+	if _, err := console.command(ctx, "whitelist list"); err != nil {
+		t.Fatal(err)
+	}
+	srv.CloseClientConnections()
+	if _, err := console.command(ctx, "whitelist list"); err == nil {
+		t.Fatal("command on a dropped connection succeeded")
+	}
+	if _, err := console.command(ctx, "whitelist add Alice"); err != nil {
+		t.Fatal(err)
+	}
+	if srv.Dials() != 2 {
+		t.Fatalf("dialed %d times, want 2", srv.Dials())
+	}
+}
+
+func TestParseWhitelist(t *testing.T) {
+	cases := map[string]string{
+		"There are no whitelisted players":                    "",
+		"There are 2 whitelisted player(s): Alice, Bob":       "Alice,Bob",
+		"There are 1 whitelisted player(s): §eAlice§r":        "Alice",
+		"There are 3 whitelisted players: A_1, b2,\nCarol_99": "A_1,b2,Carol_99",
+	}
+	for in, want := range cases {
+		if got := strings.Join(parseWhitelist(in), ","); got != want {
+			t.Errorf("parseWhitelist(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
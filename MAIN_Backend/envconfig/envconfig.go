@@ -0,0 +1,21 @@
+// Package envconfig reads settings shared by the API and the Discord bot from
+// the environment.
+package envconfig
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// Duration parses a Go duration from the environment, using def when unset or invalid.
+func Duration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		d, err := time.ParseDuration(v)
+		if err == nil && d > 0 {
+			return d
+		}
+		log.Printf("ignoring invalid %s=%q", key, v)
+	}
+	return def
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+// interviewBookingWindow is how far ahead applicants can see and book slots.
+const interviewBookingWindow = 30 * 24 * time.Hour
+
+// registerInterviewAdminRoutes wires interview scheduling onto mux (staff only).
+//
+//	GET  /admin/interview-slots               slots in ?from=&to= (RFC 3339), ?open=1 for bookable ones
+//	POST /admin/interview-slots               {"starts_at","ends_at","interviewer_discord_id"}; the caller interviews
+//	POST /admin/interview-slots/{id}/cancel   withdraw a slot and its booking
+//	POST /admin/interviews/{id}/outcome       {"outcome": "passed|failed|no_show", "notes": "..."}
+func registerInterviewAdminRoutes(mux *http.ServeMux, db *ds.DB, keys staffKeys) {
+	mux.HandleFunc("/admin/interview-slots", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		switch r.Method {
+		case http.MethodGet:
+			q := r.URL.Query()
+			from, to := time.Now(), time.Now().Add(interviewBookingWindow)
+			for _, p := range []struct {
+				key string
+				dst *time.Time
+			}{{"from", &from}, {"to", &to}} {
+				if v := q.Get(p.key); v != "" {
+					t, err := time.Parse(time.RFC3339, v)
+					if err != nil {
+						http.Error(w, "invalid "+p.key, http.StatusBadRequest)
+						return
+					}
+					*p.dst = t
+				}
+			}
+			slots, err := db.ListInterviewSlots(cctx, from, to, q.Get("open") != "")
+			if err != nil {
+				log.Printf("admin list interview slots: %v", err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if slots == nil {
+				slots = []ds.InterviewSlot{}
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"slots": slots})
+		case http.MethodPost:
+			var body ds.InterviewSlot
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			body.Interviewer = "staff:" + staff
+			slot, err := db.CreateInterviewSlot(cctx, body)
+			if err != nil {
+				writeInterviewError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, slot)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/admin/interview-slots/", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/interview-slots/"), "/"), "/")
+		if id == "" || action != "cancel" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !validUUID(id) {
+			http.Error(w, "invalid slot id", http.StatusBadRequest)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		if err := db.CancelInterviewSlot(cctx, id); err != nil {
+			writeInterviewError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	mux.HandleFunc("/admin/interviews/", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/interviews/"), "/"), "/")
+		if id == "" || action != "outcome" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !validUUID(id) {
+			http.Error(w, "invalid interview id", http.StatusBadRequest)
+			return
+		}
+		var body struct {
+			Outcome string `json:"outcome"`
+			Notes   string `json:"notes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		iv, app, err := db.RecordInterviewOutcome(cctx, "staff:"+staff, id, "staff:"+staff, body.Outcome, body.Notes)
+		if err != nil {
+			writeInterviewError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"interview": iv, "application": app})
+	}))
+}
+
+// registerInterviewRoutes wires the applicant's side of interview booking onto mux.
+//
+//	GET  /interview          the booked interview (or null) and the open slots
+//	POST /interview          {"slot_id": "..."}; replaces an existing booking
+//	POST /interview/cancel   give up the booked slot
+func registerInterviewRoutes(mux *http.ServeMux, db *ds.DB, sessions *sessionManager) {
+	mux.HandleFunc("/interview", sessions.require(func(w http.ResponseWriter, r *http.Request, user ds.User, _ ds.Session) {
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		switch r.Method {
+		case http.MethodGet:
+			iv, err := db.GetScheduledInterview(cctx, user.ID)
+			if err != nil {
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			app, err := db.GetApplicationByUser(cctx, user.ID)
+			if err != nil {
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			// only applicants waiting for an interview get to see the calendar
+			slots := []ds.InterviewSlot{}
+			if app != nil && app.Status == ds.StatusInterviewPending {
+				open, err := db.ListInterviewSlots(cctx, time.Now(), time.Now().Add(interviewBookingWindow), true)
+				if err != nil {
+					http.Error(w, "server error", http.StatusInternalServerError)
+					return
+				}
+				for _, s := range open {
+					// interviewer contact details stay internal
+					s.InterviewerDiscordID = nil
+					slots = append(slots, s)
+				}
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"interview": iv, "slots": slots})
+		case http.MethodPost:
+			var body struct {
+				SlotID string `json:"slot_id"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.SlotID == "" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			if !validUUID(body.SlotID) {
+				http.Error(w, "invalid slot_id", http.StatusBadRequest)
+				return
+			}
+			iv, err := db.BookInterview(cctx, "api:interview", user.ID, body.SlotID)
+			if err != nil {
+				writeInterviewError(w, err)
+				return
+			}
+			iv.Slot.InterviewerDiscordID = nil
+			writeJSON(w, http.StatusCreated, iv)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/interview/cancel", sessions.require(func(w http.ResponseWriter, r *http.Request, user ds.User, _ ds.Session) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		if err := db.CancelInterviewBooking(cctx, user.ID); err != nil {
+			writeInterviewError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+// writeInterviewError maps interview scheduling errors onto HTTP responses.
+func writeInterviewError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ds.ErrSlotNotFound), errors.Is(err, ds.ErrInterviewNotFound), errors.Is(err, ds.ErrApplicationNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ds.ErrSlotTaken), errors.Is(err, ds.ErrSlotInPast), errors.Is(err, ds.ErrNotAwaitingInterview),
+		errors.Is(err, ds.ErrInterviewFinished):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ds.ErrInvalidSlot), errors.Is(err, ds.ErrUnknownOutcome):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		writeStatusError(w, err)
+	}
+}
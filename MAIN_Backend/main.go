@@ -3,36 +3,107 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	ds "tysmp/main_backend/database_service"
+	"tysmp/main_backend/envconfig"
+	"tysmp/main_backend/minecraft"
 )
 
 type exchangeRequest struct {
 	Token string `json:"token"`
 }
-type exchangeResponse struct {
+type meResponse struct {
 	DiscordID string `json:"discord_id"`
 	Username  string `json:"username"`
-	NextToken string `json:"next_token"`
 	ExpiresAt string `json:"expires_at"`
 }
 
+func newMeResponse(u ds.User, s ds.Session) meResponse {
+	return meResponse{
+		DiscordID: strconv.FormatInt(u.DiscordUserID, 10),
+		Username:  u.DiscordUsername,
+		ExpiresAt: s.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+}
+
 type submitRequest struct {
-	Token             string `json:"token"`
-	Age               int16  `json:"age"`
-	MinecraftUsername string `json:"minecraft_username"`
-	FavouriteAboutMC  string `json:"favourite_about_minecraft"`
-	Understanding     string `json:"server_understanding"`
+	Age               int16          `json:"age"`
+	MinecraftUsername string         `json:"minecraft_username"`
+	FormVersion       int            `json:"form_version"`
+	Answers           map[string]any `json:"answers"`
+}
+type draftRequest struct {
+	Age               *int16         `json:"age"`
+	MinecraftUsername *string        `json:"minecraft_username"`
+	Answers           map[string]any `json:"answers"`
 }
+
 type submitResponse struct {
 	ApplicationID string `json:"application_id"`
 }
 
+// writeSubmitError maps the reasons a submission is refused onto HTTP
+// responses; a cooldown also says when the user may apply again.
+func writeSubmitError(w http.ResponseWriter, err error) {
+	var cerr *ds.CooldownError
+	switch {
+	case errors.As(err, &cerr):
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(cerr.Until).Seconds())+1))
+		writeJSON(w, http.StatusTooManyRequests, map[string]any{
+			"error":        cerr.Error(),
+			"can_apply_at": cerr.Until.UTC().Format(time.RFC3339),
+		})
+	case errors.Is(err, ds.ErrApplicationLocked), errors.Is(err, ds.ErrAlreadyBanned):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("submit: %v", err)
+		http.Error(w, "server error", http.StatusInternalServerError)
+	}
+}
+
+// pruneOutbox trims the event outbox hourly until ctx is done.
+func pruneOutbox(ctx context.Context, db *ds.DB, keep time.Duration) {
+	t := time.NewTicker(time.Hour)
+	defer t.Stop()
+	for {
+		cctx, cancel := context.WithTimeout(ctx, time.Minute)
+		n, err := db.PruneEventOutbox(cctx, keep)
+		cancel()
+		if err != nil {
+			log.Printf("outbox prune: %v", err)
+		} else if n > 0 {
+			log.Printf("outbox prune: %d events removed", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// newProfileResolverFromEnv picks the Minecraft account resolver:
+// MINECRAFT_PROFILE_RESOLVER=mojang (default) or offline for online-mode=false servers.
+func newProfileResolverFromEnv() minecraft.ProfileResolver {
+	switch os.Getenv("MINECRAFT_PROFILE_RESOLVER") {
+	case "offline":
+		log.Println("minecraft profiles resolved in offline mode")
+		return minecraft.OfflineResolver{}
+	case "", "mojang":
+		return minecraft.NewCachedResolver(minecraft.NewMojangResolver(), time.Hour, 5*time.Minute, minecraft.DefaultCacheSize)
+	default:
+		log.Fatalf("❌ unknown MINECRAFT_PROFILE_RESOLVER %q", os.Getenv("MINECRAFT_PROFILE_RESOLVER"))
+		return nil
+	}
+}
+
 func main() {
 	dsn := os.Getenv("DATABASE_URL")
 	if dsn == "" {
@@ -48,12 +119,31 @@ func main() {
 
 	mux := http.NewServeMux()
 
-	// Basic CORS for test frontend
+	profiles := newProfileResolverFromEnv()
+
+	sessions := newSessionManager(db, os.Getenv("SESSION_SECRET"), ds.SessionTTL{
+		Idle:     envconfig.Duration("SESSION_IDLE_TTL", 2*time.Hour),
+		Absolute: envconfig.Duration("SESSION_MAX_TTL", 7*24*time.Hour),
+	}, os.Getenv("SESSION_COOKIE_INSECURE") == "")
+
+	// Basic CORS for test frontend; origins listed in CORS_ALLOWED_ORIGINS may send the session cookie
+	allowedOrigins := map[string]bool{}
+	for _, o := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			allowedOrigins[o] = true
+		}
+	}
 	cors := func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			if origin := r.Header.Get("Origin"); allowedOrigins[origin] {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+				w.Header().Add("Vary", "Origin")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			}
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS")
 			if r.Method == http.MethodOptions {
 				w.WriteHeader(http.StatusNoContent)
 				return
@@ -62,8 +152,8 @@ func main() {
 		})
 	}
 
-	// POST /create-login-token -> for bot or testing to initiate token flow
-	mux.HandleFunc("/create-login-token", func(w http.ResponseWriter, r *http.Request) {
+	// POST /create-login-token -> for internal services to initiate token flow (API key with login_tokens:create)
+	mux.HandleFunc("/create-login-token", requireService(db, ds.ScopeLoginTokensCreate, func(w http.ResponseWriter, r *http.Request, client ds.APIClient) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
@@ -83,7 +173,7 @@ func main() {
 		}
 		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
-		_, tok, err := db.CreateOrRotateLoginToken(cctx, "api:create_token", idInt, body.Username)
+		_, tok, err := db.CreateOrRotateLoginToken(cctx, serviceActor(client), idInt, body.Username)
 		if err != nil {
 			http.Error(w, "server error", http.StatusInternalServerError)
 			return
@@ -93,12 +183,78 @@ func main() {
 			"token":      tok.Token,
 			"expires_at": tok.ExpiresAt.UTC().Format(time.RFC3339),
 		})
-	})
+	}))
+
+	// Follow member renames; offline-mode UUIDs cannot be looked up
+	if os.Getenv("MINECRAFT_PROFILE_RESOLVER") != "offline" {
+		names := &nameReconciler{db: db, profiles: profiles, batchSize: 100, pause: 250 * time.Millisecond}
+		go names.run(ctx, envconfig.Duration("MINECRAFT_NAME_SYNC_INTERVAL", 6*time.Hour))
+	}
+
+	// Outbox readers in this process share one app_events subscription
+	signal := newOutboxSignal()
+	go signal.run(ctx, db)
+
+	webhooks := newWebhookDispatcher(db)
+	webhookWake, _ := signal.subscribe()
+	go webhooks.run(ctx, webhookWake)
+
+	hub := newEventHub(db)
+	hubWake, _ := signal.subscribe()
+	go hub.run(ctx, hubWake)
+
+	// Lift temporary bans once they run out
+	go expireBans(ctx, db, envconfig.Duration("BAN_EXPIRY_INTERVAL", time.Minute))
+
+	// Drop outbox events every consumer has handled once they age out
+	go pruneOutbox(ctx, db, envconfig.Duration("OUTBOX_RETENTION", 7*24*time.Hour))
+
+	// Staff review API; disabled unless STAFF_API_KEYS is configured
+	votePolicy, err := ds.ParseVotePolicy(os.Getenv("VOTE_QUORUM"), os.Getenv("VOTE_THRESHOLD"))
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if keys := parseStaffKeys(os.Getenv("STAFF_API_KEYS")); len(keys) > 0 {
+		registerAdminRoutes(mux, db, keys, votePolicy)
+		registerAPIClientRoutes(mux, db, keys)
+		registerWebhookRoutes(mux, db, keys, webhooks)
+		registerEventStream(mux, db, keys, hub)
+		registerInterviewAdminRoutes(mux, db, keys)
+		registerBanAdminRoutes(mux, db, keys)
+		registerNotificationAdminRoutes(mux, db, keys)
+	} else {
+		log.Println("admin API disabled (STAFF_API_KEYS not set)")
+	}
 
 	// Serve test frontend for convenience
 	mux.Handle("/", http.FileServer(http.Dir("./test_frontend")))
 
-	// POST /exchange-token -> returns user discord info + new single-use token
+	// GET /form -> active application form for the frontend to render
+	mux.HandleFunc("/form", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		form, err := db.GetActiveForm(cctx)
+		if err != nil {
+			if errors.Is(err, ds.ErrNoActiveForm) {
+				http.Error(w, "no active form", http.StatusServiceUnavailable)
+				return
+			}
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"version":   form.Version,
+			"title":     form.Definition.Title,
+			"questions": form.Definition.Questions,
+		})
+	})
+
+	// POST /exchange-token -> burns the Discord-issued login token and starts a session cookie
 	mux.HandleFunc("/exchange-token", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -112,7 +268,7 @@ func main() {
 
 		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
-		user, tok, err := db.ExchangeToken(cctx, "api:exchange", req.Token)
+		user, sess, err := db.ExchangeTokenForSession(cctx, "api:exchange", req.Token, sessions.ttl, r.UserAgent())
 		if err != nil {
 			if err == ds.ErrInvalidOrExpiredToken {
 				http.Error(w, "invalid token", http.StatusUnauthorized)
@@ -121,17 +277,110 @@ func main() {
 			http.Error(w, "server error", http.StatusInternalServerError)
 			return
 		}
+		sessions.setCookie(w, sess)
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(exchangeResponse{
-			DiscordID: strconv.FormatInt(user.DiscordUserID, 10),
-			Username:  user.DiscordUsername,
-			NextToken: tok.Token,
-			ExpiresAt: tok.ExpiresAt.UTC().Format(time.RFC3339),
-		})
+		json.NewEncoder(w).Encode(newMeResponse(user, sess))
+	})
+
+	// Optional Discord OAuth2 login as an alternative to bot-issued tokens
+	if o := newDiscordOAuthFromEnv(); o != nil {
+		registerOAuthRoutes(mux, db, sessions, o)
+	} else {
+		log.Println("discord oauth login disabled (DISCORD_OAUTH_CLIENT_ID not set)")
+	}
+
+	// GET /me -> the logged in applicant
+	mux.HandleFunc("/me", sessions.require(func(w http.ResponseWriter, r *http.Request, user ds.User, sess ds.Session) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newMeResponse(user, sess))
+	}))
+
+	// POST /logout -> revokes the current session server side
+	mux.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if id, ok := sessions.sessionID(r); ok {
+			cctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			defer cancel()
+			if err := db.RevokeSession(cctx, id); err != nil {
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+		}
+		sessions.clearCookie(w)
+		w.WriteHeader(http.StatusNoContent)
 	})
 
-	// POST /submit-application -> consumes token and stores application + updates profile
-	mux.HandleFunc("/submit-application", func(w http.ResponseWriter, r *http.Request) {
+	// GET /draft -> the applicant's autosaved answers; PUT /draft -> merge partial answers
+	mux.HandleFunc("/draft", sessions.require(func(w http.ResponseWriter, r *http.Request, user ds.User, _ ds.Session) {
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		switch r.Method {
+		case http.MethodGet:
+			draft, err := db.GetDraft(cctx, user.ID)
+			if err != nil {
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if draft == nil {
+				draft = &ds.ApplicationDraft{UserID: user.ID, Answers: map[string]any{}}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(draft)
+		case http.MethodPut:
+			var req draftRequest
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			form, err := db.GetActiveForm(cctx)
+			if err != nil {
+				if errors.Is(err, ds.ErrNoActiveForm) {
+					http.Error(w, "no active form", http.StatusServiceUnavailable)
+					return
+				}
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			// only keep answers to questions the active form actually asks
+			known := map[string]bool{}
+			for _, q := range form.Definition.Questions {
+				known[q.ID] = true
+			}
+			answers := map[string]any{}
+			for k, v := range req.Answers {
+				if known[k] {
+					answers[k] = v
+				}
+			}
+			draft, err := db.SaveDraft(cctx, ds.ApplicationDraft{
+				UserID:        user.ID,
+				Answers:       answers,
+				Age:           req.Age,
+				MinecraftName: req.MinecraftUsername,
+				FormVersion:   &form.Version,
+			})
+			if err != nil {
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(draft)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	// POST /submit-application -> stores application + updates profile for the session user.
+	// Denied users may start a new attempt once REAPPLY_COOLDOWN has passed.
+	reapplyCooldown := envconfig.Duration("REAPPLY_COOLDOWN", 30*24*time.Hour)
+	mux.HandleFunc("/submit-application", sessions.require(func(w http.ResponseWriter, r *http.Request, user ds.User, _ ds.Session) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
@@ -142,49 +391,123 @@ func main() {
 			return
 		}
 
+		cctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+		defer cancel()
+
+		// Refuse early so a closed application does not get its profile changed
+		if err := db.CheckCanApply(cctx, user.ID, reapplyCooldown); err != nil {
+			writeSubmitError(w, err)
+			return
+		}
+
+		// Fill in anything the request leaves out from the autosaved draft
+		draft, err := db.GetDraft(cctx, user.ID)
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if draft != nil {
+			merged := draft.Answers
+			for k, v := range req.Answers {
+				merged[k] = v
+			}
+			req.Answers = merged
+			if req.Age == 0 && draft.Age != nil {
+				req.Age = *draft.Age
+			}
+			if req.MinecraftUsername == "" && draft.MinecraftName != nil {
+				req.MinecraftUsername = *draft.MinecraftName
+			}
+		}
+
 		// Basic validation
+		req.MinecraftUsername = strings.TrimSpace(req.MinecraftUsername)
 		if req.Age < 0 || req.Age > 120 || req.MinecraftUsername == "" {
 			http.Error(w, "invalid fields", http.StatusBadRequest)
 			return
 		}
+		if !minecraft.ValidName(req.MinecraftUsername) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": map[string]string{
+				"minecraft_username": "must be 3-16 letters, digits or underscores",
+			}})
+			return
+		}
 
-		cctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
-		defer cancel()
-		user, err := db.ConsumeToken(cctx, "api:submit", req.Token)
+		form, err := db.GetActiveForm(cctx)
 		if err != nil {
-			if err == ds.ErrInvalidOrExpiredToken {
-				http.Error(w, "invalid token", http.StatusUnauthorized)
+			if errors.Is(err, ds.ErrNoActiveForm) {
+				http.Error(w, "no active form", http.StatusServiceUnavailable)
 				return
 			}
 			http.Error(w, "server error", http.StatusInternalServerError)
 			return
 		}
+		if req.FormVersion != 0 && req.FormVersion != form.Version {
+			http.Error(w, "form has changed, please reload", http.StatusConflict)
+			return
+		}
+		answers, err := form.Definition.ValidateAnswers(req.Answers)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]any{"errors": err})
+			return
+		}
+
+		// Resolve the account so the UUID, not the name, identifies the player
+		profile, err := profiles.ByName(cctx, req.MinecraftUsername)
+		if err != nil {
+			if errors.Is(err, minecraft.ErrProfileNotFound) || errors.Is(err, minecraft.ErrInvalidName) {
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": map[string]string{
+					"minecraft_username": "no Minecraft account with that name",
+				}})
+				return
+			}
+			log.Printf("submit: resolve %q: %v", req.MinecraftUsername, err)
+			http.Error(w, "could not verify Minecraft account, try again later", http.StatusBadGateway)
+			return
+		}
 
-		// Update user profile (age + MC name)
-		_, err = db.UpdateUserProfile(cctx, "api:submit", user.ID, &req.Age, &req.MinecraftUsername)
+		// Update user profile (age + MC name/UUID)
+		_, err = db.UpdateUserProfile(cctx, "api:submit", user.ID, &req.Age, &profile.Name, &profile.UUID)
 		if err != nil {
+			if errors.Is(err, ds.ErrMinecraftAccountTaken) {
+				http.Error(w, "that Minecraft account is already linked to another applicant", http.StatusConflict)
+				return
+			}
+			if errors.Is(err, ds.ErrMinecraftNameTaken) {
+				http.Error(w, "that Minecraft name is still linked to another applicant; if the account was renamed recently, try again later", http.StatusConflict)
+				return
+			}
 			http.Error(w, "server error", http.StatusInternalServerError)
 			return
 		}
 
 		// Create or update application with answers
-		answers := map[string]any{
-			"favourite_about_minecraft": req.FavouriteAboutMC,
-			"server_understanding":      req.Understanding,
-		}
 		app, err := db.CreateOrUpdateApplication(cctx, "api:submit", ds.Application{
-			UserID:  user.ID,
-			Answers: answers,
-			Status:  ds.StatusApplicant,
-		})
+			UserID:      user.ID,
+			Answers:     answers,
+			Status:      ds.StatusApplicant,
+			FormVersion: &form.Version,
+		}, reapplyCooldown)
 		if err != nil {
-			http.Error(w, "server error", http.StatusInternalServerError)
+			writeSubmitError(w, err)
 			return
 		}
+		if err := db.DeleteDraft(cctx, user.ID); err != nil {
+			// the application is stored; a stale draft is only cosmetic
+			log.Printf("submit: clear draft for %s: %v", user.ID, err)
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(submitResponse{ApplicationID: app.ID})
-	})
+	}))
+
+	// GET/POST /interview, POST /interview/cancel -> interview booking for the session user
+	registerInterviewRoutes(mux, db, sessions)
+
+	// GET /ban, POST /ban/appeal -> ban details and appeals for the session user
+	registerBanRoutes(mux, db, sessions)
 
 	addr := ":8081"
 	if p := os.Getenv("PORT"); p != "" {
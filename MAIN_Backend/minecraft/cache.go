@@ -0,0 +1,131 @@
+package minecraft
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize bounds each index of a CachedResolver when no size is given.
+const DefaultCacheSize = 10000
+
+// CachedResolver memoises another resolver. Misses (ErrProfileNotFound) are
+// cached for a shorter time so newly created accounts show up quickly. Names
+// and UUIDs are each kept to a fixed number of entries, evicting the least
+// recently used.
+type CachedResolver struct {
+	inner       ProfileResolver
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu     sync.Mutex
+	byName *lru
+	byUUID *lru
+}
+
+type cacheEntry struct {
+	profile Profile
+	err     error
+	expires time.Time
+}
+
+// NewCachedResolver caches up to size names and size UUIDs
+// (DefaultCacheSize when size is not positive).
+func NewCachedResolver(inner ProfileResolver, ttl time.Duration, negativeTTL time.Duration, size int) *CachedResolver {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &CachedResolver{
+		inner:       inner,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		byName:      newLRU(size),
+		byUUID:      newLRU(size),
+	}
+}
+
+// lru is a fixed size map that evicts its least recently used key. It is not
+// safe for concurrent use; CachedResolver guards it with its mutex.
+type lru struct {
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry cacheEntry
+}
+
+func newLRU(size int) *lru {
+	return &lru{size: size, order: list.New(), items: map[string]*list.Element{}}
+}
+
+func (l *lru) get(key string) (cacheEntry, bool) {
+	el, ok := l.items[key]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	l.order.MoveToFront(el)
+	return el.Value.(*lruItem).entry, true
+}
+
+func (l *lru) put(key string, e cacheEntry) {
+	if el, ok := l.items[key]; ok {
+		el.Value.(*lruItem).entry = e
+		l.order.MoveToFront(el)
+		return
+	}
+	l.items[key] = l.order.PushFront(&lruItem{key: key, entry: e})
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruItem).key)
+	}
+}
+
+func (c *CachedResolver) ByName(ctx context.Context, name string) (Profile, error) {
+	return c.lookup(ctx, c.byName, strings.ToLower(name), func() (Profile, error) {
+		return c.inner.ByName(ctx, name)
+	})
+}
+
+func (c *CachedResolver) ByUUID(ctx context.Context, uuid string) (Profile, error) {
+	return c.lookup(ctx, c.byUUID, strings.ToLower(uuid), func() (Profile, error) {
+		return c.inner.ByUUID(ctx, uuid)
+	})
+}
+
+func (c *CachedResolver) lookup(_ context.Context, m *lru, key string, fetch func() (Profile, error)) (Profile, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if e, ok := m.get(key); ok && now.Before(e.expires) {
+		c.mu.Unlock()
+		return e.profile, e.err
+	}
+	c.mu.Unlock()
+
+	p, err := fetch()
+	switch {
+	case err == nil:
+		c.store(p, now.Add(c.ttl))
+	case errors.Is(err, ErrProfileNotFound):
+		c.mu.Lock()
+		m.put(key, cacheEntry{err: err, expires: now.Add(c.negativeTTL)})
+		c.mu.Unlock()
+	}
+	// transient errors are never cached
+	return p, err
+}
+
+// store records a found profile under both its name and UUID.
+func (c *CachedResolver) store(p Profile, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := cacheEntry{profile: p, expires: expires}
+	c.byName.put(strings.ToLower(p.Name), e)
+	c.byUUID.put(strings.ToLower(p.UUID), e)
+}
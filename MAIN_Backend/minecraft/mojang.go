@@ -0,0 +1,77 @@
+package minecraft
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MojangResolver resolves profiles against the public Mojang APIs.
+type MojangResolver struct {
+	Client *http.Client
+	// NameURL and UUIDURL are the lookup endpoints; overridable for testing.
+	NameURL string
+	UUIDURL string
+}
+
+func NewMojangResolver() *MojangResolver {
+	return &MojangResolver{
+		Client:  &http.Client{Timeout: 10 * time.Second},
+		NameURL: "https://api.mojang.com/users/profiles/minecraft/",
+		UUIDURL: "https://sessionserver.mojang.com/session/minecraft/profile/",
+	}
+}
+
+func (m *MojangResolver) ByName(ctx context.Context, name string) (Profile, error) {
+	if !ValidName(name) {
+		return Profile{}, ErrInvalidName
+	}
+	return m.get(ctx, m.NameURL+url.PathEscape(name))
+}
+
+func (m *MojangResolver) ByUUID(ctx context.Context, uuid string) (Profile, error) {
+	id, err := formatUUID(uuid)
+	if err != nil {
+		return Profile{}, err
+	}
+	return m.get(ctx, m.UUIDURL+strings.ReplaceAll(id, "-", ""))
+}
+
+func (m *MojangResolver) get(ctx context.Context, u string) (Profile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return Profile{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := m.Client.Do(req)
+	if err != nil {
+		return Profile{}, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent, http.StatusNotFound:
+		return Profile{}, ErrProfileNotFound
+	default:
+		return Profile{}, fmt.Errorf("mojang: %s", res.Status)
+	}
+
+	var body struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 64<<10)).Decode(&body); err != nil {
+		return Profile{}, fmt.Errorf("mojang: decode: %w", err)
+	}
+	id, err := formatUUID(body.ID)
+	if err != nil {
+		return Profile{}, fmt.Errorf("mojang: %w", err)
+	}
+	return Profile{UUID: id, Name: body.Name}, nil
+}
@@ -0,0 +1,31 @@
+package minecraft
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+)
+
+// OfflineResolver is for servers running with online-mode=false. Every valid
+// name exists and maps to the same UUID the server derives for it
+// (a v3 UUID of "OfflinePlayer:<name>"). Offline UUIDs encode the name, so
+// ByUUID cannot recover a rename and always reports ErrProfileNotFound.
+type OfflineResolver struct{}
+
+func (OfflineResolver) ByName(_ context.Context, name string) (Profile, error) {
+	if !ValidName(name) {
+		return Profile{}, ErrInvalidName
+	}
+	sum := md5.Sum([]byte("OfflinePlayer:" + name))
+	sum[6] = sum[6]&0x0f | 0x30 // version 3
+	sum[8] = sum[8]&0x3f | 0x80 // IETF variant
+	id, err := formatUUID(hex.EncodeToString(sum[:]))
+	if err != nil {
+		return Profile{}, err
+	}
+	return Profile{UUID: id, Name: name}, nil
+}
+
+func (OfflineResolver) ByUUID(context.Context, string) (Profile, error) {
+	return Profile{}, ErrProfileNotFound
+}
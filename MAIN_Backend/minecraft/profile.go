@@ -0,0 +1,49 @@
+// Package minecraft resolves Minecraft Java edition accounts (name <-> UUID).
+package minecraft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidName     = errors.New("invalid minecraft username")
+	ErrProfileNotFound = errors.New("minecraft profile not found")
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_]{3,16}$`)
+
+// ValidName reports whether name is a well-formed Java edition username.
+func ValidName(name string) bool {
+	return validName.MatchString(name)
+}
+
+// Profile is a Minecraft account: its stable UUID and current name.
+type Profile struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+// ProfileResolver looks up Minecraft accounts. Implementations return
+// ErrProfileNotFound when the account does not exist.
+type ProfileResolver interface {
+	ByName(ctx context.Context, name string) (Profile, error)
+	ByUUID(ctx context.Context, uuid string) (Profile, error)
+}
+
+// formatUUID turns Mojang's undashed hex id into the canonical 8-4-4-4-12 form.
+func formatUUID(id string) (string, error) {
+	id = strings.ToLower(strings.ReplaceAll(id, "-", ""))
+	if len(id) != 32 {
+		return "", fmt.Errorf("malformed uuid %q", id)
+	}
+	for _, r := range id {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return "", fmt.Errorf("malformed uuid %q", id)
+		}
+	}
+	return id[0:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:32], nil
+}
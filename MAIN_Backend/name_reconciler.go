@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	ds "tysmp/main_backend/database_service"
+	"tysmp/main_backend/minecraft"
+)
+
+const nameReconcilerActor = "system:name-reconciler"
+
+// nameConflictBackoff spaces out retries of a rename whose new name is still
+// held by another user. The conflict usually clears once the other user's
+// own rename is reconciled; until then retrying every run only adds noise.
+var nameConflictBackoff = ds.RetryPolicy{Base: 6 * time.Hour, Max: 48 * time.Hour}
+
+// nameReconciler keeps users.minecraft_name in step with the name Mojang
+// currently reports for each member's UUID. Updates go through
+// SetMinecraftName so renames are recorded and users_notify fires.
+type nameReconciler struct {
+	db        *ds.DB
+	profiles  minecraft.ProfileResolver
+	batchSize int
+	// pause is slept between lookups to stay well under the profile API rate limit
+	pause time.Duration
+
+	// conflicts holds users whose rename hit ErrMinecraftNameTaken, by user id
+	conflicts map[string]nameConflict
+}
+
+type nameConflict struct {
+	name     string
+	failures int
+	retryAt  time.Time
+}
+
+// run reconciles once immediately and then every interval until ctx is done.
+func (n *nameReconciler) run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		renamed, err := n.reconcileOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("name reconcile: %v", err)
+		} else if renamed > 0 {
+			log.Printf("name reconcile: %d renamed", renamed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// reconcileOnce walks all members in batches and returns how many were renamed.
+// Lookup failures for single players are logged and skipped.
+func (n *nameReconciler) reconcileOnce(ctx context.Context) (int, error) {
+	renamed := 0
+	after := ""
+	for {
+		batch, err := n.db.ListMemberMinecraftAccounts(ctx, after, n.batchSize)
+		if err != nil {
+			return renamed, err
+		}
+		for _, u := range batch {
+			if c, ok := n.conflicts[u.ID]; ok && time.Now().Before(c.retryAt) {
+				continue
+			}
+			changed, err := n.reconcileUser(ctx, u)
+			if err != nil {
+				if ctx.Err() != nil {
+					return renamed, ctx.Err()
+				}
+				log.Printf("name reconcile %s: %v", u.ID, err)
+			}
+			if changed {
+				renamed++
+			}
+			select {
+			case <-ctx.Done():
+				return renamed, ctx.Err()
+			case <-time.After(n.pause):
+			}
+		}
+		if len(batch) < n.batchSize {
+			return renamed, nil
+		}
+		after = batch[len(batch)-1].ID
+	}
+}
+
+func (n *nameReconciler) reconcileUser(ctx context.Context, u ds.User) (bool, error) {
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	p, err := n.profiles.ByUUID(cctx, *u.MinecraftUUID)
+	if err != nil {
+		if errors.Is(err, minecraft.ErrProfileNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if u.MinecraftName != nil && *u.MinecraftName == p.Name {
+		delete(n.conflicts, u.ID)
+		return false, nil
+	}
+	if _, err := n.db.SetMinecraftName(cctx, nameReconcilerActor, u.ID, &p.Name); err != nil {
+		if errors.Is(err, ds.ErrMinecraftNameTaken) {
+			n.conflict(u.ID, p.Name)
+			return false, nil
+		}
+		return false, err
+	}
+	delete(n.conflicts, u.ID)
+	return true, nil
+}
+
+// conflict backs off a user whose new name is taken, logging only when the
+// conflict is new or a retry failed again.
+func (n *nameReconciler) conflict(userID string, name string) {
+	if n.conflicts == nil {
+		n.conflicts = map[string]nameConflict{}
+	}
+	c := n.conflicts[userID]
+	if c.name != name {
+		c = nameConflict{name: name}
+	}
+	c.failures++
+	wait := nameConflictBackoff.Backoff(c.failures)
+	c.retryAt = time.Now().Add(wait)
+	n.conflicts[userID] = c
+	log.Printf("name reconcile %s: %q is held by another user (%d tries), retrying in %s", userID, name, c.failures, wait)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+// registerNotificationAdminRoutes wires applicant notification management onto
+// mux (staff only). The discordbot renders and sends the notifications.
+//
+//	GET  /admin/notification-templates   the message template of each status, with the placeholders
+//	POST /admin/notification-templates   {"status","template","enabled"} set a status's template
+//	GET  /admin/notifications            sent notifications, ?user_id=, ?state=pending|sent|fallback|failed
+func registerNotificationAdminRoutes(mux *http.ServeMux, db *ds.DB, keys staffKeys) {
+	mux.HandleFunc("/admin/notification-templates", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		switch r.Method {
+		case http.MethodGet:
+			templates, err := db.ListNotificationTemplates(cctx)
+			if err != nil {
+				log.Printf("admin list notification templates: %v", err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if templates == nil {
+				templates = []ds.NotificationTemplate{}
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"templates": templates, "placeholders": ds.NotificationPlaceholders})
+		case http.MethodPost:
+			var body struct {
+				ds.NotificationTemplate
+				Enabled *bool `json:"enabled"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			t := body.NotificationTemplate
+			t.Enabled = body.Enabled == nil || *body.Enabled
+			saved, err := db.SaveNotificationTemplate(cctx, "staff:"+staff, t)
+			if err != nil {
+				if errors.Is(err, ds.ErrInvalidTemplate) {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				log.Printf("admin save notification template: %v", err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, saved)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/admin/notifications", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		switch q.Get("state") {
+		case "", ds.NotifyPending, ds.NotifySent, ds.NotifyFallback, ds.NotifyFailed:
+		default:
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		}
+		if v := q.Get("user_id"); v != "" && !validUUID(v) {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+		limit, offset := pageParams(r)
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		list, err := db.ListNotifications(cctx, q.Get("user_id"), q.Get("state"), limit, offset)
+		if err != nil {
+			log.Printf("admin list notifications: %v", err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if list == nil {
+			list = []ds.Notification{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"notifications": list})
+	}))
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+const oauthStateCookie = "tysmp_oauth_state"
+
+// discordOAuth performs the OAuth2 authorization-code flow against Discord.
+// Every endpoint is configurable so it can point at a local fake provider.
+type discordOAuth struct {
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	authorizeURL string
+	tokenURL     string
+	userInfoURL  string
+	// successURL is where the browser lands after a successful login
+	successURL string
+	client     *http.Client
+}
+
+// discordIdentity is the subset of Discord's /users/@me response we need.
+type discordIdentity struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// newDiscordOAuthFromEnv returns nil when DISCORD_OAUTH_CLIENT_ID is not set.
+func newDiscordOAuthFromEnv() *discordOAuth {
+	o := &discordOAuth{
+		clientID:     os.Getenv("DISCORD_OAUTH_CLIENT_ID"),
+		clientSecret: os.Getenv("DISCORD_OAUTH_CLIENT_SECRET"),
+		redirectURL:  os.Getenv("DISCORD_OAUTH_REDIRECT_URL"),
+		authorizeURL: envOr("DISCORD_OAUTH_AUTHORIZE_URL", "https://discord.com/oauth2/authorize"),
+		tokenURL:     envOr("DISCORD_OAUTH_TOKEN_URL", "https://discord.com/api/oauth2/token"),
+		userInfoURL:  envOr("DISCORD_OAUTH_USERINFO_URL", "https://discord.com/api/users/@me"),
+		successURL:   envOr("DISCORD_OAUTH_SUCCESS_URL", "/"),
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+	if o.clientID == "" {
+		return nil
+	}
+	if o.clientSecret == "" || o.redirectURL == "" {
+		log.Fatal("❌ DISCORD_OAUTH_CLIENT_SECRET and DISCORD_OAUTH_REDIRECT_URL must be set with DISCORD_OAUTH_CLIENT_ID")
+	}
+	return o
+}
+
+func envOr(key string, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func (o *discordOAuth) authCodeURL(state string) string {
+	q := url.Values{
+		"client_id":     {o.clientID},
+		"redirect_uri":  {o.redirectURL},
+		"response_type": {"code"},
+		"scope":         {"identify"},
+		"state":         {state},
+		"prompt":        {"none"},
+	}
+	if strings.Contains(o.authorizeURL, "?") {
+		return o.authorizeURL + "&" + q.Encode()
+	}
+	return o.authorizeURL + "?" + q.Encode()
+}
+
+// exchange trades an authorization code for an access token.
+func (o *discordOAuth) exchange(ctx context.Context, code string) (string, error) {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {o.redirectURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(url.QueryEscape(o.clientID), url.QueryEscape(o.clientSecret))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+	}
+	if err := o.doJSON(req, &tok); err != nil {
+		return "", fmt.Errorf("token exchange: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", errors.New("token exchange: empty access_token")
+	}
+	return tok.AccessToken, nil
+}
+
+// fetchUser resolves the Discord account behind an access token.
+func (o *discordOAuth) fetchUser(ctx context.Context, accessToken string) (discordIdentity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.userInfoURL, nil)
+	if err != nil {
+		return discordIdentity{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var id discordIdentity
+	if err := o.doJSON(req, &id); err != nil {
+		return discordIdentity{}, fmt.Errorf("user info: %w", err)
+	}
+	if id.ID == "" || id.Username == "" {
+		return discordIdentity{}, errors.New("user info: missing id or username")
+	}
+	return id, nil
+}
+
+func (o *discordOAuth) doJSON(req *http.Request, out any) error {
+	res, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(out)
+}
+
+// oauthStore is the slice of the database layer the login callback uses.
+type oauthStore interface {
+	UpsertUser(ctx context.Context, actor string, u ds.User) (ds.User, error)
+	CreateSession(ctx context.Context, actor string, userID string, ttl ds.SessionTTL, userAgent string) (ds.Session, error)
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// registerOAuthRoutes wires the Discord login flow onto mux.
+//
+//	GET /auth/discord/login     redirects to the provider with a state cookie
+//	GET /auth/discord/callback  verifies state, upserts the user and starts a session
+func registerOAuthRoutes(mux *http.ServeMux, db oauthStore, sessions *sessionManager, o *discordOAuth) {
+	mux.HandleFunc("/auth/discord/login", func(w http.ResponseWriter, r *http.Request) {
+		state, err := randomState()
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     oauthStateCookie,
+			Value:    state,
+			Path:     "/auth/discord/",
+			MaxAge:   600,
+			HttpOnly: true,
+			Secure:   sessions.secure,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, o.authCodeURL(state), http.StatusFound)
+	})
+
+	mux.HandleFunc("/auth/discord/callback", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		c, err := r.Cookie(oauthStateCookie)
+		if err != nil || q.Get("state") == "" || subtle.ConstantTimeCompare([]byte(c.Value), []byte(q.Get("state"))) != 1 {
+			http.Error(w, "invalid oauth state", http.StatusBadRequest)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: "/auth/discord/", MaxAge: -1})
+		if e := q.Get("error"); e != "" {
+			http.Error(w, "login cancelled: "+e, http.StatusUnauthorized)
+			return
+		}
+		code := q.Get("code")
+		if code == "" {
+			http.Error(w, "missing code", http.StatusBadRequest)
+			return
+		}
+
+		cctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+		defer cancel()
+		access, err := o.exchange(cctx, code)
+		if err != nil {
+			log.Printf("oauth: %v", err)
+			http.Error(w, "login failed", http.StatusBadGateway)
+			return
+		}
+		ident, err := o.fetchUser(cctx, access)
+		if err != nil {
+			log.Printf("oauth: %v", err)
+			http.Error(w, "login failed", http.StatusBadGateway)
+			return
+		}
+		discordID, err := strconv.ParseInt(ident.ID, 10, 64)
+		if err != nil {
+			http.Error(w, "login failed", http.StatusBadGateway)
+			return
+		}
+
+		user, err := db.UpsertUser(cctx, "api:oauth", ds.User{DiscordUserID: discordID, DiscordUsername: ident.Username})
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		sess, err := db.CreateSession(cctx, "api:oauth", user.ID, sessions.ttl, r.UserAgent())
+		if err != nil {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		sessions.setCookie(w, sess)
+		http.Redirect(w, r, o.successURL, http.StatusFound)
+	})
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+// fakeProvider is a Discord OAuth2 provider serving the token and user info
+// endpoints. It only accepts the code it was given and the token it issued.
+type fakeProvider struct {
+	*httptest.Server
+	code  string
+	token string
+	user  discordIdentity
+	// tokenStatus, when set, fails the token exchange with that status
+	tokenStatus int
+
+	mu         sync.Mutex
+	exchanges  []url.Values
+	basicAuth  string
+	userLookup int
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	p := &fakeProvider{code: "code-1", token: "access-1", user: discordIdentity{ID: "123456789", Username: "alice"}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id, secret, _ := r.BasicAuth()
+		p.mu.Lock()
+		p.exchanges = append(p.exchanges, r.PostForm)
+		p.basicAuth = id + ":" + secret
+		p.mu.Unlock()
+		if p.tokenStatus != 0 {
+			http.Error(w, `{"error":"invalid_grant"}`, p.tokenStatus)
+			return
+		}
+		if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("code") != p.code || id != "client" || secret != "s3cret" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": p.token, "token_type": "Bearer", "expires_in": 604800})
+	})
+	mux.HandleFunc("/users/@me", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.userLookup++
+		p.mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer "+p.token {
+			http.Error(w, `{"message":"401: Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(p.user)
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *fakeProvider) oauth() *discordOAuth {
+	return &discordOAuth{
+		clientID:     "client",
+		clientSecret: "s3cret",
+		redirectURL:  "https://tysmp.example/auth/discord/callback",
+		authorizeURL: p.URL + "/authorize",
+		tokenURL:     p.URL + "/token",
+		userInfoURL:  p.URL + "/users/@me",
+		successURL:   "/apply",
+		client:       p.Client(),
+	}
+}
+
+// fakeOAuthStore records the users and sessions the callback creates.
+type fakeOAuthStore struct {
+	users    []ds.User
+	sessions []string
+}
+
+func (f *fakeOAuthStore) UpsertUser(ctx context.Context, actor string, u ds.User) (ds.User, error) {
+	u.ID = "user-1"
+	f.users = append(f.users, u)
+	return u, nil
+}
+
+func (f *fakeOAuthStore) CreateSession(ctx context.Context, actor string, userID string, ttl ds.SessionTTL, userAgent string) (ds.Session, error) {
+	f.sessions = append(f.sessions, userID)
+	return ds.Session{ID: "sess-1", UserID: userID, ExpiresAt: time.Now().Add(ttl.Idle)}, nil
+}
+
+func newOAuthMux(t *testing.T) (*http.ServeMux, *fakeProvider, *fakeOAuthStore, *sessionManager) {
+	p := newFakeProvider(t)
+	store := &fakeOAuthStore{}
+	sessions := &sessionManager{secret: []byte("test secret"), ttl: ds.SessionTTL{Idle: time.Hour, Absolute: 24 * time.Hour}}
+	mux := http.NewServeMux()
+	registerOAuthRoutes(mux, store, sessions, p.oauth())
+	return mux, p, store, sessions
+}
+
+// callback requests the callback with the query and, when non-empty, a state cookie.
+func callback(mux *http.ServeMux, query url.Values, cookieState string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/auth/discord/callback?"+query.Encode(), nil)
+	if cookieState != "" {
+		r.AddCookie(&http.Cookie{Name: oauthStateCookie, Value: cookieState})
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func cookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestOAuthLoginRedirects(t *testing.T) {
+	mux, p, _, _ := newOAuthMux(t)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/discord/login", nil))
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("status %d", w.Code)
+	}
+	state := cookie(w, oauthStateCookie)
+	if state == nil || state.Value == "" || !state.HttpOnly {
+		t.Fatalf("state cookie %+v", state)
+	}
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := loc.Query()
+	if !strings.HasPrefix(loc.String(), p.URL+"/authorize?") || q.Get("state") != state.Value ||
+		q.Get("client_id") != "client" || q.Get("redirect_uri") != "https://tysmp.example/auth/discord/callback" || q.Get("scope") != "identify" {
+		t.Fatalf("redirect %s", loc)
+	}
+}
+
+func TestOAuthCallbackStartsSession(t *testing.T) {
+	mux, p, store, sessions := newOAuthMux(t)
+	w := callback(mux, url.Values{"state": {"st"}, "code": {"code-1"}}, "st")
+
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/apply" {
+		t.Fatalf("status %d, location %q: %s", w.Code, w.Header().Get("Location"), w.Body)
+	}
+	if len(store.users) != 1 || store.users[0].DiscordUserID != 123456789 || store.users[0].DiscordUsername != "alice" {
+		t.Fatalf("users %+v", store.users)
+	}
+	if len(store.sessions) != 1 || store.sessions[0] != "user-1" {
+		t.Fatalf("sessions %v", store.sessions)
+	}
+	sc := cookie(w, sessionCookie)
+	if sc == nil || sc.Value != sessions.sign("sess-1") {
+		t.Fatalf("session cookie %+v", sc)
+	}
+	if st := cookie(w, oauthStateCookie); st == nil || st.MaxAge >= 0 {
+		t.Fatalf("state cookie not cleared: %+v", st)
+	}
+	if len(p.exchanges) != 1 || p.exchanges[0].Get("redirect_uri") != "https://tysmp.example/auth/discord/callback" || p.basicAuth != "client:s3cret" {
+		t.Fatalf("exchange %v with %q", p.exchanges, p.basicAuth)
+	}
+}
+
+func TestOAuthCallbackRejectsState(t *testing.T) {
+	cases := []struct {
+		name   string
+		query  url.Values
+		cookie string
+	}{
+		{"mismatch", url.Values{"state": {"forged"}, "code": {"code-1"}}, "st"},
+		{"no cookie", url.Values{"state": {"st"}, "code": {"code-1"}}, ""},
+		{"no state", url.Values{"code": {"code-1"}}, "st"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mux, p, store, _ := newOAuthMux(t)
+			w := callback(mux, c.query, c.cookie)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status %d", w.Code)
+			}
+			if len(p.exchanges) != 0 || len(store.sessions) != 0 {
+				t.Fatalf("exchanged %d codes, started %d sessions", len(p.exchanges), len(store.sessions))
+			}
+		})
+	}
+}
+
+func TestOAuthCallbackCancelled(t *testing.T) {
+	mux, p, _, _ := newOAuthMux(t)
+	w := callback(mux, url.Values{"state": {"st"}, "error": {"access_denied"}}, "st")
+	if w.Code != http.StatusUnauthorized || len(p.exchanges) != 0 {
+		t.Fatalf("status %d after %d exchanges", w.Code, len(p.exchanges))
+	}
+}
+
+func TestOAuthTokenExchangeFails(t *testing.T) {
+	mux, p, store, _ := newOAuthMux(t)
+	w := callback(mux, url.Values{"state": {"st"}, "code": {"stale"}}, "st")
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("status %d", w.Code)
+	}
+	if p.userLookup != 0 || len(store.sessions) != 0 {
+		t.Fatalf("looked up %d users, started %d sessions", p.userLookup, len(store.sessions))
+	}
+}
+
+func TestOAuthExchange(t *testing.T) {
+	p := newFakeProvider(t)
+	o := p.oauth()
+	ctx := context.Background()
+
+	tok, err := o.exchange(ctx, "code-1")
+	if err != nil || tok != "access-1" {
+		t.Fatalf("exchange = %q, %v", tok, err)
+	}
+	id, err := o.fetchUser(ctx, tok)
+	if err != nil || id != p.user {
+		t.Fatalf("fetchUser = %+v, %v", id, err)
+	}
+	if _, err := o.fetchUser(ctx, "wrong"); err == nil {
+		t.Fatal("fetchUser accepted a token the provider never issued")
+	}
+
+	p.tokenStatus = http.StatusInternalServerError
+	if _, err := o.exchange(ctx, "code-1"); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("exchange error %v", err)
+	}
+}
+
+func TestOAuthExchangeEmptyToken(t *testing.T) {
+	p := newFakeProvider(t)
+	p.token = ""
+	if _, err := p.oauth().exchange(context.Background(), "code-1"); err == nil {
+		t.Fatal("exchange accepted an empty access token")
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+// outboxSignal shares one app_events subscription between the outbox readers
+// of this process. Subscribers only learn that something new may be in the
+// outbox; they read it from their own cursor.
+type outboxSignal struct {
+	mu   sync.Mutex
+	subs map[chan struct{}]struct{}
+}
+
+func newOutboxSignal() *outboxSignal {
+	return &outboxSignal{subs: map[chan struct{}]struct{}{}}
+}
+
+// subscribe returns a wake-up channel and a func to stop receiving on it.
+// Wake-ups coalesce: a slow subscriber sees at most one pending signal.
+func (s *outboxSignal) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+	return ch, func() {
+		s.mu.Lock()
+		delete(s.subs, ch)
+		s.mu.Unlock()
+	}
+}
+
+func (s *outboxSignal) broadcast() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run listens on app_events until ctx is done, reconnecting with backoff.
+func (s *outboxSignal) run(ctx context.Context, db *ds.DB) {
+	backoff := time.Second
+	for {
+		wake, errs, err := db.ListenOutbox(ctx)
+		if err != nil {
+			log.Printf("app_events subscribe: %v", err)
+		} else {
+			backoff = time.Second
+			for wake != nil || errs != nil {
+				select {
+				case _, ok := <-wake:
+					if !ok {
+						wake = nil
+						continue
+					}
+					s.broadcast()
+				case err, ok := <-errs:
+					if !ok {
+						errs = nil
+						continue
+					}
+					log.Printf("app_events: %v", err)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package rcon implements a client for the Source RCON protocol as spoken by
+// Minecraft servers (enable-rcon=true in server.properties).
+package rcon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	typeResponseValue int32 = 0
+	typeExecCommand   int32 = 2
+	typeAuthResponse  int32 = 2
+	typeAuth          int32 = 3
+
+	// Minecraft rejects client payloads larger than this.
+	maxCommandLen = 1446
+	// Upper bound on a single packet we are willing to read.
+	maxPacketSize = 4096 + 10
+)
+
+var (
+	ErrAuthFailed      = errors.New("rcon: authentication failed")
+	ErrCommandTooLong  = errors.New("rcon: command too long")
+	ErrMalformedPacket = errors.New("rcon: malformed packet")
+)
+
+// Client is a single authenticated RCON connection. It is safe for concurrent
+// use; commands are serialised over the connection.
+type Client struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	r       *bufio.Reader
+	nextID  int32
+	timeout time.Duration
+}
+
+// Dial connects to addr and authenticates with password. timeout bounds every
+// individual command round trip as well as the initial handshake.
+func Dial(ctx context.Context, addr string, password string, timeout time.Duration) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn: conn, r: bufio.NewReader(conn), timeout: timeout}
+	if err := c.auth(password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) auth(password string) error {
+	c.deadline()
+	id := c.id()
+	if err := writePacket(c.conn, id, typeAuth, password); err != nil {
+		return err
+	}
+	for {
+		pid, ptype, _, err := readPacket(c.r)
+		if err != nil {
+			return err
+		}
+		// some servers send an empty RESPONSE_VALUE before the auth result
+		if ptype != typeAuthResponse {
+			continue
+		}
+		if pid == -1 || pid != id {
+			return ErrAuthFailed
+		}
+		return nil
+	}
+}
+
+// Command runs cmd on the server and returns its output. Responses split
+// across several packets are reassembled by sending a marker packet after
+// the command and reading until the server echoes it back.
+func (c *Client) Command(cmd string) (string, error) {
+	if len(cmd) > maxCommandLen {
+		return "", ErrCommandTooLong
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deadline()
+	id := c.id()
+	marker := c.id()
+	if err := writePacket(c.conn, id, typeExecCommand, cmd); err != nil {
+		return "", err
+	}
+	if err := writePacket(c.conn, marker, typeResponseValue, ""); err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	for {
+		pid, _, body, err := readPacket(c.r)
+		if err != nil {
+			return "", err
+		}
+		switch pid {
+		case id:
+			out.WriteString(body)
+		case marker:
+			return out.String(), nil
+		default:
+			return "", fmt.Errorf("rcon: unexpected response id %d", pid)
+		}
+	}
+}
+
+func (c *Client) id() int32 {
+	c.nextID++
+	if c.nextID <= 0 {
+		c.nextID = 1
+	}
+	return c.nextID
+}
+
+func (c *Client) deadline() {
+	if c.timeout > 0 {
+		_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+}
+
+// writePacket encodes: int32 size | int32 id | int32 type | body | 0x00 0x00
+func writePacket(w io.Writer, id int32, typ int32, body string) error {
+	buf := make([]byte, 12+len(body)+2)
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(int32(8+len(body)+2)))
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(id))
+	binary.LittleEndian.PutUint32(buf[8:12], uint32(typ))
+	copy(buf[12:], body)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readPacket(r io.Reader) (int32, int32, string, error) {
+	var hdr [12]byte
+	if _, err := io.ReadFull(r, hdr[:4]); err != nil {
+		return 0, 0, "", err
+	}
+	size := int32(binary.LittleEndian.Uint32(hdr[0:4]))
+	if size < 10 || size > maxPacketSize {
+		return 0, 0, "", ErrMalformedPacket
+	}
+	if _, err := io.ReadFull(r, hdr[4:12]); err != nil {
+		return 0, 0, "", err
+	}
+	rest := make([]byte, size-8)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, 0, "", err
+	}
+	id := int32(binary.LittleEndian.Uint32(hdr[4:8]))
+	typ := int32(binary.LittleEndian.Uint32(hdr[8:12]))
+	return id, typ, string(bytes.TrimRight(rest, "\x00")), nil
+}
@@ -0,0 +1,90 @@
+package rcon_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"tysmp/main_backend/rcon"
+	"tysmp/main_backend/rcon/rcontest"
+)
+
+func echo(cmd string) string { return "ran " + cmd }
+
+func dial(t *testing.T, srv *rcontest.Server, password string) (*rcon.Client, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return rcon.Dial(ctx, srv.Addr, password, 5*time.Second)
+}
+
+func TestCommand(t *testing.T) {
+	srv := rcontest.NewServer("secret", echo)
+	defer srv.Close()
+
+	c, err := dial(t, srv, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, cmd := range []string{"list", "whitelist add Alice"} {
+		out, err := c.Command(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != "ran "+cmd {
+			t.Fatalf("%s: got %q", cmd, out)
+		}
+	}
+	if got := strings.Join(srv.Commands(), ";"); got != "list;whitelist add Alice" {
+		t.Fatalf("server saw %q", got)
+	}
+}
+
+func TestWrongPassword(t *testing.T) {
+	srv := rcontest.NewServer("secret", echo)
+	defer srv.Close()
+
+	if _, err := dial(t, srv, "guess"); !errors.Is(err, rcon.ErrAuthFailed) {
+		t.Fatalf("got %v, want ErrAuthFailed", err)
+	}
+}
+
+func TestMultiPacketResponse(t *testing.T) {
+	long := strings.Repeat("Alice, Bob, ", 100)
+	srv := rcontest.NewServer("secret", func(string) string { return long })
+	srv.MaxBody = 64
+	defer srv.Close()
+
+	c, err := dial(t, srv, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	out, err := c.Command("whitelist list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != long {
+		t.Fatalf("got %d bytes, want %d", len(out), len(long))
+	}
+}
+
+func TestCommandTooLong(t *testing.T) {
+	srv := rcontest.NewServer("secret", echo)
+	defer srv.Close()
+
+	c, err := dial(t, srv, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Command(strings.Repeat("x", 2000)); !errors.Is(err, rcon.ErrCommandTooLong) {
+		t.Fatalf("got %v, want ErrCommandTooLong", err)
+	}
+	if n := len(srv.Commands()); n != 0 {
+		t.Fatalf("server saw %d commands", n)
+	}
+}
@@ -0,0 +1,189 @@
+// Package rcontest provides an in-process RCON server for tests, in the
+// spirit of net/http/httptest.
+package rcontest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+const (
+	typeResponseValue int32 = 0
+	typeExecCommand   int32 = 2
+	typeAuthResponse  int32 = 2
+	typeAuth          int32 = 3
+)
+
+// Handler answers one console command.
+type Handler func(cmd string) string
+
+// Server is a Source RCON server listening on a loopback port. Commands are
+// answered by the handler in the order they arrive, across all connections.
+type Server struct {
+	// Addr is the host:port to pass to rcon.Dial.
+	Addr string
+	// MaxBody splits responses longer than this over several packets, the way
+	// Minecraft splits long output; zero sends each response in one packet.
+	MaxBody int
+
+	password string
+	handler  Handler
+	ln       net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	conns    map[net.Conn]bool
+	commands []string
+	dials    int
+}
+
+// NewServer starts a server that accepts password and answers commands with h.
+func NewServer(password string, h Handler) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("rcontest: listen: " + err.Error())
+	}
+	s := &Server{Addr: ln.Addr().String(), password: password, handler: h, ln: ln, conns: map[net.Conn]bool{}}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Commands returns every command received so far.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// Dials returns how many connections were accepted.
+func (s *Server) Dials() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dials
+}
+
+// CloseClientConnections drops every open connection, as a server restart would.
+func (s *Server) CloseClientConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+// Close stops the server and waits for its connections to finish.
+func (s *Server) Close() {
+	s.ln.Close()
+	s.CloseClientConnections()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.dials++
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	authed := false
+	for {
+		id, typ, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch {
+		case typ == typeAuth:
+			authed = body == s.password
+			if !authed {
+				id = -1
+			}
+			if writePacket(conn, id, typeAuthResponse, "") != nil {
+				return
+			}
+		case !authed:
+			return
+		case typ == typeExecCommand:
+			s.mu.Lock()
+			s.commands = append(s.commands, body)
+			out := s.handler(body)
+			s.mu.Unlock()
+			if s.respond(conn, id, out) != nil {
+				return
+			}
+		default:
+			// the client's end-of-response marker is echoed back as is
+			if writePacket(conn, id, typeResponseValue, "") != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) respond(w io.Writer, id int32, out string) error {
+	for {
+		chunk := out
+		if s.MaxBody > 0 && len(chunk) > s.MaxBody {
+			chunk = out[:s.MaxBody]
+		}
+		if err := writePacket(w, id, typeResponseValue, chunk); err != nil {
+			return err
+		}
+		out = out[len(chunk):]
+		if out == "" {
+			return nil
+		}
+	}
+}
+
+func writePacket(w io.Writer, id int32, typ int32, body string) error {
+	buf := make([]byte, 12+len(body)+2)
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(int32(8+len(body)+2)))
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(id))
+	binary.LittleEndian.PutUint32(buf[8:12], uint32(typ))
+	copy(buf[12:], body)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readPacket(r io.Reader) (int32, int32, string, error) {
+	var hdr [12]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, 0, "", err
+	}
+	size := int32(binary.LittleEndian.Uint32(hdr[0:4]))
+	if size < 10 || size > 4096+10 {
+		return 0, 0, "", errors.New("rcontest: malformed packet")
+	}
+	rest := make([]byte, size-8)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, 0, "", err
+	}
+	id := int32(binary.LittleEndian.Uint32(hdr[4:8]))
+	typ := int32(binary.LittleEndian.Uint32(hdr[8:12]))
+	return id, typ, string(bytes.TrimRight(rest, "\x00")), nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+// requireService authenticates service-to-service calls by API key, sent as
+// "Authorization: Bearer tysmp_..." or "X-API-Key", and checks scope.
+// The handler receives the client so its identity can be used as audit actor.
+func requireService(db *ds.DB, scope string, next func(w http.ResponseWriter, r *http.Request, client ds.APIClient)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			key = bearer
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		cctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		client, err := db.AuthenticateAPIKey(cctx, key)
+		if err != nil {
+			if errors.Is(err, ds.ErrInvalidAPIKey) {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			log.Printf("api key auth: %v", err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		if !client.HasScope(scope) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r, client)
+	}
+}
+
+// serviceActor is the audit actor for calls made by an API client.
+func serviceActor(c ds.APIClient) string {
+	return "service:" + c.Name
+}
+
+// registerAPIClientRoutes exposes API client management to staff.
+//
+//	GET  /admin/api-clients               clients with key metadata
+//	POST /admin/api-clients               {"name": "...", "scopes": [...]} -> client + raw key
+//	POST /admin/api-clients/{id}/rotate   {"grace_seconds": 3600} -> new raw key (old keys last 24h by default)
+//	POST /admin/api-clients/{id}/disable
+//	POST /admin/api-keys/{id}/revoke
+func registerAPIClientRoutes(mux *http.ServeMux, db *ds.DB, keys staffKeys) {
+	mux.HandleFunc("/admin/api-clients", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		switch r.Method {
+		case http.MethodGet:
+			clients, err := db.ListAPIClients(cctx)
+			if err != nil {
+				log.Printf("admin list api clients: %v", err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if clients == nil {
+				clients = []ds.APIClient{}
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"clients": clients})
+		case http.MethodPost:
+			var body struct {
+				Name   string   `json:"name"`
+				Scopes []string `json:"scopes"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || strings.TrimSpace(body.Name) == "" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			client, raw, err := db.CreateAPIClient(cctx, "staff:"+staff, body.Name, body.Scopes)
+			if err != nil {
+				if errors.Is(err, ds.ErrUnknownScope) {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				if errors.Is(err, ds.ErrAPIClientExists) {
+					http.Error(w, err.Error(), http.StatusConflict)
+					return
+				}
+				log.Printf("admin create api client: %v", err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusCreated, map[string]any{"client": client, "key": raw})
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/admin/api-clients/", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/api-clients/"), "/"), "/")
+		if !validUUID(id) {
+			http.Error(w, "invalid client id", http.StatusBadRequest)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		switch action {
+		case "rotate":
+			var body struct {
+				GraceSeconds int `json:"grace_seconds"`
+			}
+			// the body is optional; without it the default grace applies
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			key, raw, err := db.RotateAPIKey(cctx, "staff:"+staff, id, time.Duration(body.GraceSeconds)*time.Second)
+			if err != nil {
+				if errors.Is(err, ds.ErrAPIClientNotFound) {
+					http.NotFound(w, r)
+					return
+				}
+				log.Printf("admin rotate api key %s: %v", id, err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusCreated, map[string]any{"api_key": key, "key": raw})
+		case "disable":
+			if err := db.DisableAPIClient(cctx, id); err != nil {
+				if errors.Is(err, ds.ErrAPIClientNotFound) {
+					http.NotFound(w, r)
+					return
+				}
+				log.Printf("admin disable api client %s: %v", id, err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	mux.HandleFunc("/admin/api-keys/", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/api-keys/"), "/"), "/")
+		if r.Method != http.MethodPost || action != "revoke" {
+			http.NotFound(w, r)
+			return
+		}
+		if !validUUID(id) {
+			http.Error(w, "invalid key id", http.StatusBadRequest)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		if err := db.RevokeAPIKey(cctx, id); err != nil {
+			if errors.Is(err, ds.ErrAPIKeyNotFound) {
+				http.NotFound(w, r)
+				return
+			}
+			log.Printf("admin revoke api key %s: %v", id, err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+const sessionCookie = "tysmp_session"
+
+// sessionManager issues and verifies signed session cookies. The cookie holds
+// "<session id>.<hmac>" so forged ids are rejected before touching the database;
+// validity itself (expiry, revocation) is always checked server side.
+type sessionManager struct {
+	db     *ds.DB
+	secret []byte
+	ttl    ds.SessionTTL
+	secure bool
+}
+
+// newSessionManager uses SESSION_SECRET when set; otherwise a random secret is
+// generated and sessions will not survive a restart.
+func newSessionManager(db *ds.DB, secret string, ttl ds.SessionTTL, secure bool) *sessionManager {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatalf("❌ session secret: %v", err)
+		}
+		log.Println("SESSION_SECRET not set; using an ephemeral secret")
+	}
+	return &sessionManager{db: db, secret: key, ttl: ttl, secure: secure}
+}
+
+func (m *sessionManager) sign(id string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(id))
+	return id + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sessionID extracts and verifies the session id from the request cookie.
+func (m *sessionManager) sessionID(r *http.Request) (string, bool) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", false
+	}
+	id, _, ok := strings.Cut(c.Value, ".")
+	if !ok || !hmac.Equal([]byte(m.sign(id)), []byte(c.Value)) {
+		return "", false
+	}
+	return id, true
+}
+
+// setCookie writes the session cookie expiring together with the session.
+func (m *sessionManager) setCookie(w http.ResponseWriter, s ds.Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    m.sign(s.ID),
+		Path:     "/",
+		Expires:  s.ExpiresAt,
+		HttpOnly: true,
+		Secure:   m.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (m *sessionManager) clearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   m.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// require authenticates an applicant request by session cookie, slides the
+// session expiry and refreshes the cookie before calling next.
+func (m *sessionManager) require(next func(w http.ResponseWriter, r *http.Request, user ds.User, s ds.Session)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := m.sessionID(r)
+		if !ok {
+			http.Error(w, "not logged in", http.StatusUnauthorized)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		s, user, err := m.db.TouchSession(cctx, id, m.ttl.Idle)
+		if err != nil {
+			if errors.Is(err, ds.ErrSessionInvalid) {
+				m.clearCookie(w)
+				http.Error(w, "session expired", http.StatusUnauthorized)
+				return
+			}
+			log.Printf("session touch: %v", err)
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		m.setCookie(w, s)
+		next(w, r, user, s)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+const (
+	sseHeartbeat    = 15 * time.Second
+	sseClientBuffer = 64
+	sseReplayPage   = 500
+)
+
+// eventFilter restricts a stream to some tables and statuses; empty sets match all.
+type eventFilter struct {
+	tables   map[string]bool
+	statuses map[ds.Status]bool
+}
+
+func parseEventFilter(q map[string][]string) (eventFilter, error) {
+	f := eventFilter{tables: map[string]bool{}, statuses: map[ds.Status]bool{}}
+	for _, v := range q["table"] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				f.tables[t] = true
+			}
+		}
+	}
+	for _, v := range q["status"] {
+		for _, s := range strings.Split(v, ",") {
+			st := ds.Status(strings.TrimSpace(s))
+			if st == "" {
+				continue
+			}
+			if !st.Valid() {
+				return f, errors.New("invalid status")
+			}
+			f.statuses[st] = true
+		}
+	}
+	return f, nil
+}
+
+func (f eventFilter) matches(ev ds.AppEvent) bool {
+	if len(f.tables) > 0 && !f.tables[ev.Table] {
+		return false
+	}
+	if len(f.statuses) > 0 && (ev.Status == nil || !f.statuses[*ev.Status]) {
+		return false
+	}
+	return true
+}
+
+// sseClient is one connected stream. The hub closes ch when the client falls
+// a full buffer behind; the client then reconnects and resumes by Last-Event-ID.
+type sseClient struct {
+	filter eventFilter
+	ch     chan ds.AppEvent
+}
+
+// eventHub reads the outbox once for the whole process and fans events out to
+// every connected stream without ever blocking on one of them.
+type eventHub struct {
+	db *ds.DB
+
+	mu      sync.Mutex
+	head    int64
+	clients map[*sseClient]struct{}
+}
+
+func newEventHub(db *ds.DB) *eventHub {
+	return &eventHub{db: db, clients: map[*sseClient]struct{}{}}
+}
+
+// subscribe registers a client and returns the last outbox id the hub has
+// published; everything after it will arrive on the client's channel.
+func (h *eventHub) subscribe(f eventFilter) (*sseClient, int64) {
+	c := &sseClient{filter: f, ch: make(chan ds.AppEvent, sseClientBuffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[c] = struct{}{}
+	return c, h.head
+}
+
+func (h *eventHub) unsubscribe(c *sseClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		close(c.ch)
+	}
+}
+
+func (h *eventHub) publish(ev ds.AppEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.head = ev.ID
+	for c := range h.clients {
+		if !c.filter.matches(ev) {
+			continue
+		}
+		select {
+		case c.ch <- ev:
+		default:
+			// too slow; drop it rather than stall everyone else
+			delete(h.clients, c)
+			close(c.ch)
+		}
+	}
+}
+
+// run follows the outbox from its current end until ctx is done.
+func (h *eventHub) run(ctx context.Context, wake <-chan struct{}) {
+	for {
+		head, err := h.db.LatestOutboxID(ctx)
+		if err == nil {
+			h.mu.Lock()
+			h.head = head
+			h.mu.Unlock()
+			break
+		}
+		log.Printf("event hub: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+
+	t := time.NewTicker(10 * time.Second)
+	defer t.Stop()
+	for {
+		if err := h.catchUp(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("event hub: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-t.C:
+		}
+	}
+}
+
+func (h *eventHub) catchUp(ctx context.Context) error {
+	for {
+		h.mu.Lock()
+		after := h.head
+		h.mu.Unlock()
+
+		batch, err := h.db.ListOutboxEvents(ctx, after, sseReplayPage)
+		if err != nil {
+			return err
+		}
+		for _, e := range batch {
+			ev, err := e.Decode()
+			if err != nil {
+				log.Printf("event hub: %v", err)
+				h.mu.Lock()
+				h.head = e.ID
+				h.mu.Unlock()
+				continue
+			}
+			h.publish(ev)
+		}
+		if len(batch) < sseReplayPage {
+			return nil
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, ev ds.AppEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, strings.ToLower(ev.Table+"."+ev.Action), data)
+	return err
+}
+
+// registerEventStream wires the live event stream onto mux (staff only).
+//
+//	GET /admin/events?table=applications&status=member,banned
+//
+// Each event is sent with its outbox id, so a reconnecting client that sends
+// Last-Event-ID first receives what it missed from the outbox.
+func registerEventStream(mux *http.ServeMux, db *ds.DB, keys staffKeys, hub *eventHub) {
+	mux.HandleFunc("/admin/events", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		filter, err := parseEventFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resume := int64(-1)
+		if v := r.Header.Get("Last-Event-ID"); v != "" {
+			if resume, err = strconv.ParseInt(v, 10, 64); err != nil || resume < 0 {
+				http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// subscribe before replaying so nothing falls between the two
+		c, head := hub.subscribe(filter)
+		defer hub.unsubscribe(c)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "retry: 3000\n\n")
+		flusher.Flush()
+
+	replay:
+		for after := resume; after >= 0 && after < head; {
+			cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+			batch, err := db.ListOutboxEvents(cctx, after, sseReplayPage)
+			cancel()
+			if err != nil {
+				log.Printf("event stream replay: %v", err)
+				return
+			}
+			for _, e := range batch {
+				if e.ID > head {
+					// the rest arrives live
+					break replay
+				}
+				after = e.ID
+				ev, err := e.Decode()
+				if err != nil || !filter.matches(ev) {
+					continue
+				}
+				if err := writeSSE(w, ev); err != nil {
+					return
+				}
+			}
+			if len(batch) < sseReplayPage {
+				break
+			}
+		}
+		flusher.Flush()
+
+		t := time.NewTicker(sseHeartbeat)
+		defer t.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case ev, ok := <-c.ch:
+				if !ok {
+					// dropped for falling behind; the client reconnects and resumes
+					return
+				}
+				if err := writeSSE(w, ev); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-t.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}))
+}
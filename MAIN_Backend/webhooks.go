@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	ds "tysmp/main_backend/database_service"
+)
+
+const webhookConsumer = "api:webhooks"
+
+// Deliveries are claimed in batches and sent one after another, so the lease
+// must outlast a whole batch of timed-out sends or another replica could claim
+// and resend the tail of the batch.
+const (
+	webhookBatch       = 20
+	webhookSendTimeout = 15 * time.Second
+	webhookLease       = webhookBatch*webhookSendTimeout + time.Minute
+)
+
+// webhookRetryPolicy spreads retries over roughly a day before giving up.
+var webhookRetryPolicy = ds.RetryPolicy{MaxAttempts: 12, Base: 30 * time.Second, Max: 4 * time.Hour}
+
+// webhookPayload is the JSON body POSTed to endpoints.
+type webhookPayload struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// webhookDispatcher turns outbox events into webhook deliveries and sends the
+// deliveries that are due. Receivers verify X-TYSMP-Signature, which is
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>" keyed by the secret>".
+type webhookDispatcher struct {
+	db     *ds.DB
+	client *http.Client
+}
+
+func newWebhookDispatcher(db *ds.DB) *webhookDispatcher {
+	return &webhookDispatcher{db: db, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// run fans out new events and sends due deliveries whenever the outbox
+// signals, and at least every few seconds for retries coming due.
+func (d *webhookDispatcher) run(ctx context.Context, wake <-chan struct{}) {
+	t := time.NewTicker(5 * time.Second)
+	defer t.Stop()
+	for {
+		if err := d.db.DrainEvents(ctx, webhookConsumer, ds.DefaultRetryPolicy, d.enqueue); err != nil && ctx.Err() == nil {
+			log.Printf("webhooks: outbox: %v", err)
+		}
+		d.deliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-t.C:
+		}
+	}
+}
+
+func (d *webhookDispatcher) enqueue(ctx context.Context, ev ds.AppEvent) error {
+	typ := strings.ToLower(ev.Table + "." + ev.Action)
+	body, err := json.Marshal(webhookPayload{
+		ID:        "evt_" + strconv.FormatInt(ev.ID, 10),
+		Type:      typ,
+		CreatedAt: ev.At,
+		Data:      ev,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = d.db.EnqueueWebhookEvent(ctx, ev, typ, body)
+	return err
+}
+
+func (d *webhookDispatcher) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		batch, err := d.db.ClaimWebhookDeliveries(ctx, webhookBatch, webhookLease)
+		if err != nil {
+			log.Printf("webhooks: claim: %v", err)
+			return
+		}
+		if len(batch) == 0 {
+			return
+		}
+		for _, c := range batch {
+			a := d.send(ctx, c.URL, c.Secret, c.WebhookDelivery)
+			del, err := d.db.RecordWebhookAttempt(ctx, c.ID, a, webhookRetryPolicy)
+			if err != nil {
+				log.Printf("webhooks: record delivery %d: %v", c.ID, err)
+				continue
+			}
+			if del.State == ds.WebhookFailed {
+				log.Printf("webhooks: delivery %d to %s gave up after %d attempts: %s", del.ID, c.URL, del.Attempts, a.Err)
+			}
+		}
+	}
+}
+
+// send POSTs a delivery once. Any non-2xx response counts as a failure.
+func (d *webhookDispatcher) send(ctx context.Context, url string, secret string, del ds.WebhookDelivery) ds.WebhookAttempt {
+	cctx, cancel := context.WithTimeout(ctx, webhookSendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(cctx, http.MethodPost, url, bytes.NewReader(del.Payload))
+	if err != nil {
+		return ds.WebhookAttempt{Err: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TYSMP-Webhooks/1")
+	req.Header.Set("X-TYSMP-Event", del.EventType)
+	req.Header.Set("X-TYSMP-Delivery", strconv.FormatInt(del.ID, 10))
+	req.Header.Set("X-TYSMP-Signature", signWebhook(secret, time.Now(), del.Payload))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return ds.WebhookAttempt{Err: err.Error()}
+	}
+	defer res.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	status, body := res.StatusCode, string(raw)
+	a := ds.WebhookAttempt{Status: &status, Body: &body}
+	if status < 200 || status > 299 {
+		a.Err = res.Status
+	}
+	return a
+}
+
+func signWebhook(secret string, at time.Time, body []byte) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// registerWebhookRoutes wires webhook management onto mux (staff only).
+//
+//	GET  /admin/webhooks                   all endpoints
+//	POST /admin/webhooks                   {"url","description","tables","actions","statuses"}; returns the secret once
+//	GET  /admin/webhooks/{id}/deliveries   delivery log
+//	POST /admin/webhooks/{id}/test         send a ping synchronously and return the delivery
+//	POST /admin/webhooks/{id}/disable      stop deliveries, pending retries included
+func registerWebhookRoutes(mux *http.ServeMux, db *ds.DB, keys staffKeys, d *webhookDispatcher) {
+	mux.HandleFunc("/admin/webhooks", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		cctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		switch r.Method {
+		case http.MethodGet:
+			hooks, err := db.ListWebhooks(cctx)
+			if err != nil {
+				log.Printf("admin list webhooks: %v", err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if hooks == nil {
+				hooks = []ds.WebhookEndpoint{}
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"webhooks": hooks})
+		case http.MethodPost:
+			var body ds.WebhookEndpoint
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			hook, secret, err := db.CreateWebhook(cctx, "staff:"+staff, body)
+			if err != nil {
+				if errors.Is(err, ds.ErrInvalidWebhook) || errors.Is(err, ds.ErrInvalidFilter) {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				log.Printf("admin create webhook: %v", err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusCreated, map[string]any{"webhook": hook, "secret": secret})
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/admin/webhooks/", requireStaff(keys, func(w http.ResponseWriter, r *http.Request, staff string) {
+		id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/webhooks/"), "/"), "/")
+		if id == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if !validUUID(id) {
+			http.Error(w, "invalid webhook id", http.StatusBadRequest)
+			return
+		}
+		cctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		switch action {
+		case "deliveries":
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			limit, offset := pageParams(r)
+			dels, err := db.ListWebhookDeliveries(cctx, id, limit, offset)
+			if err != nil {
+				if errors.Is(err, ds.ErrWebhookNotFound) {
+					http.NotFound(w, r)
+					return
+				}
+				log.Printf("admin webhook deliveries %s: %v", id, err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if dels == nil {
+				dels = []ds.WebhookDelivery{}
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"deliveries": dels})
+		case "test":
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			body, _ := json.Marshal(webhookPayload{
+				ID:        "ping_" + strconv.FormatInt(time.Now().UnixNano(), 36),
+				Type:      "ping",
+				CreatedAt: time.Now().UTC(),
+				Data:      map[string]string{"requested_by": "staff:" + staff},
+			})
+			del, err := db.EnqueueWebhookTest(cctx, id, "ping", body)
+			if err == nil {
+				var url, secret string
+				if url, secret, err = db.GetWebhookSecret(cctx, id); err == nil {
+					// a ping is never retried; the caller sees the outcome directly
+					del, err = db.RecordWebhookAttempt(cctx, del.ID, d.send(cctx, url, secret, del), ds.RetryPolicy{})
+				}
+			}
+			if err != nil {
+				if errors.Is(err, ds.ErrWebhookNotFound) {
+					http.NotFound(w, r)
+					return
+				}
+				log.Printf("admin test webhook %s: %v", id, err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, del)
+		case "disable":
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if err := db.DisableWebhook(cctx, id); err != nil {
+				if errors.Is(err, ds.ErrWebhookNotFound) {
+					http.NotFound(w, r)
+					return
+				}
+				log.Printf("admin disable webhook %s: %v", id, err)
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}